@@ -3,7 +3,6 @@ package auth
 
 import (
 	"net/http"
-	"strings"
 )
 
 // Authenticator stub
@@ -11,11 +10,29 @@ type Authenticator interface {
 	Type() string
 	User() string
 	Password() string
-	// Challenge is the values from "WWW-Authenticate" header
+	// Challenge is the values from "WWW-Authenticate" header (or "Proxy-Authenticate" for a proxy)
 	Challenge([]string) Authenticator
 	Authenticate(*http.Request)
 }
 
+// FallibleAuthenticator is an [Authenticator] that can fail, e.g. when an access token cannot
+// be obtained. A request that cannot be authenticated should not be sent, so TryAuthenticate
+// reports the failure; Authenticate ignores it.
+type FallibleAuthenticator interface {
+	Authenticator
+	TryAuthenticate(*http.Request) error
+}
+
+// AuthenticateRequest authenticates the request, using TryAuthenticate if the authenticator
+// is a [FallibleAuthenticator].
+func AuthenticateRequest(a Authenticator, req *http.Request) error {
+	if fa, ok := a.(FallibleAuthenticator); ok {
+		return fa.TryAuthenticate(req)
+	}
+	a.Authenticate(req)
+	return nil
+}
+
 var Anonymous Authenticator = &noAuth{}
 
 func Deferred(user string, pw string) Authenticator {
@@ -48,14 +65,12 @@ func (n *noAuth) Password() string {
 	return n.pw
 }
 
+// Challenge chooses an authenticator for the "WWW-Authenticate" challenges using the
+// registered schemes (see [Register]), provided that the user is known.
 func (n *noAuth) Challenge(ss []string) Authenticator {
 	if n.user != "" {
-		for _, s := range ss {
-			if strings.HasPrefix(s, "Digest") {
-				return Digest(n.user, n.pw).Challenge(ss)
-			} else if strings.HasPrefix(s, "Basic") {
-				return Basic(n.user, n.pw).Challenge(ss)
-			}
+		if a := Choose(ParseChallenges(ss), n.user, n.pw); a != nil {
+			return a
 		}
 	}
 	return n
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var _ FallibleAuthenticator = &BearerAuth{}
+
+// TokenSource supplies access tokens for [Bearer] authentication.
+type TokenSource interface {
+	// Token gets a valid access token, obtaining a new one if necessary.
+	Token(ctx context.Context) (string, error)
+
+	// Invalidate discards the token, if it is the current one, so that a new one will
+	// be obtained next time.
+	Invalidate(token string)
+}
+
+// Bearer provides bearer token authentication using tokens from a token source,
+// such as [OAuth2ClientCredentials] or [StaticToken].
+// See https://tools.ietf.org/html/rfc6750
+//
+// If the server rejects the token using a challenge with error="invalid_token", the token
+// is discarded so that the request can be retried with a new one. If a token cannot be
+// obtained, TryAuthenticate fails, so the request is not sent.
+func Bearer(src TokenSource) *BearerAuth {
+	return &BearerAuth{src: src}
+}
+
+// BearerAuth structure holds the token source.
+type BearerAuth struct {
+	src TokenSource
+
+	mu    sync.Mutex // guards the following
+	token string     // the token most recently used
+}
+
+// Type identifies the Bearer authenticator.
+func (b *BearerAuth) Type() string {
+	return "Bearer"
+}
+
+// User is blank for bearer authentication.
+func (b *BearerAuth) User() string {
+	return ""
+}
+
+// Password is blank for bearer authentication.
+func (b *BearerAuth) Password() string {
+	return ""
+}
+
+// Authenticate the current request. See [BearerAuth.TryAuthenticate].
+func (b *BearerAuth) Authenticate(req *http.Request) {
+	_ = b.TryAuthenticate(req)
+}
+
+// TryAuthenticate authenticates the current request, returning an error if a token cannot
+// be obtained, in which case the Authorization header is not set.
+func (b *BearerAuth) TryAuthenticate(req *http.Request) error {
+	token, err := b.src.Token(req.Context())
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.token = token
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Challenge handles the "WWW-Authenticate" challenges, all of which must use the Bearer
+// scheme. If the token was rejected as invalid, it is discarded.
+func (b *BearerAuth) Challenge(ss []string) Authenticator {
+	for _, s := range ss {
+		if !strings.HasPrefix(s, "Bearer") {
+			panic("incorrect auth challenge: only 'Bearer' expected here")
+		}
+	}
+
+	if InvalidToken(ss) {
+		b.mu.Lock()
+		token := b.token
+		b.mu.Unlock()
+		b.src.Invalidate(token)
+	}
+
+	return b
+}
+
+// InvalidToken is true if any of the Bearer challenges has error="invalid_token",
+// meaning that the access token expired, was revoked or is otherwise invalid.
+func InvalidToken(ss []string) bool {
+	for _, s := range ss {
+		if len(s) >= 6 && strings.EqualFold(s[:6], "bearer") &&
+			parseAuthParams(s[6:])["error"] == "invalid_token" {
+			return true
+		}
+	}
+	return false
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// StaticToken is a token source that always supplies the same token.
+type StaticToken string
+
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+func (t StaticToken) Invalidate(string) {}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestBearer_Authorize(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+
+	Bearer(StaticToken("mF_9.B5f-4.1JqM")).Authenticate(req)
+
+	expect.String(req.Header.Get("Authorization")).ToBe(t, "Bearer mF_9.B5f-4.1JqM")
+}
+
+type countingSource struct {
+	n           int
+	invalidated []string
+	err         error
+}
+
+func (cs *countingSource) Token(context.Context) (string, error) {
+	cs.n++
+	return "t" + string(rune('0'+cs.n)), cs.err
+}
+
+func (cs *countingSource) Invalidate(token string) {
+	cs.invalidated = append(cs.invalidated, token)
+}
+
+func TestBearer_Challenge_invalid_token(t *testing.T) {
+	src := &countingSource{}
+	bearer := Bearer(src)
+
+	bearer.Authenticate(httptest.NewRequest("GET", "/", nil))
+	bearer.Challenge([]string{`Bearer realm="example"`})
+	expect.Slice(src.invalidated).ToBeEmpty(t)
+
+	bearer.Challenge([]string{`Bearer realm="example", error="invalid_token", error_description="The access token expired"`})
+	expect.Slice(src.invalidated).ToBe(t, "t1")
+}
+
+func TestBearer_token_error(t *testing.T) {
+	src := &countingSource{err: errors.New("unreachable")}
+	bearer := Bearer(src)
+	req := httptest.NewRequest("GET", "/", nil)
+
+	err := bearer.TryAuthenticate(req)
+
+	expect.Error(err).ToContain(t, "unreachable")
+	expect.String(req.Header.Get("Authorization")).ToBe(t, "")
+}
+
+func TestInvalidToken(t *testing.T) {
+	expect.Bool(InvalidToken([]string{`Bearer error="invalid_token"`})).ToBeTrue(t)
+	expect.Bool(InvalidToken([]string{`Bearer error="insufficient_scope"`})).ToBeFalse(t)
+	expect.Bool(InvalidToken([]string{`Bearer`})).ToBeFalse(t)
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"strings"
+)
+
+// Challenge is one authentication challenge from a "WWW-Authenticate" or "Proxy-Authenticate"
+// header. See RFC-9110 section 11.
+type Challenge struct {
+	// Scheme is the authentication scheme, as sent by the server, e.g. "Digest".
+	Scheme string
+
+	// Token68 holds the token68 form of challenge, if used, e.g. `Negotiate YIIB...==`.
+	Token68 string
+
+	// Params holds the auth-params, if used. Names are lowercase; quoted values are unquoted.
+	Params map[string]string
+
+	// Raw is the text of the challenge, starting with the scheme.
+	Raw string
+}
+
+// Is tests whether the challenge uses a scheme; this is case-insensitive.
+func (c Challenge) Is(scheme string) bool {
+	return strings.EqualFold(c.Scheme, scheme)
+}
+
+// ParseChallenges parses all the challenges in the values of a "WWW-Authenticate" or
+// "Proxy-Authenticate" header. Each value may contain several comma-separated challenges,
+// and parameter values may contain quoted commas. Malformed text is skipped.
+func ParseChallenges(values []string) []Challenge {
+	var challenges []Challenge
+	for _, v := range values {
+		p := &challengeParser{s: v}
+		for {
+			c, ok := p.challenge()
+			if !ok {
+				break
+			}
+			challenges = append(challenges, c)
+		}
+	}
+	return challenges
+}
+
+// ChallengesFor gets the text of the challenges that use a particular scheme, in the form
+// expected by [Authenticator.Challenge]. The scheme name is normalised to the case given.
+func ChallengesFor(challenges []Challenge, scheme string) []string {
+	var found []string
+	for _, c := range challenges {
+		if c.Is(scheme) {
+			found = append(found, scheme+c.Raw[len(c.Scheme):])
+		}
+	}
+	return found
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// challengeParser implements the grammar in RFC-9110 section 11.2:
+//
+//	challenge   = auth-scheme [ 1*SP ( token68 / #auth-param ) ]
+//	auth-param  = token BWS "=" BWS ( token / quoted-string )
+//	token68     = 1*( ALPHA / DIGIT / "-" / "." / "_" / "~" / "+" / "/" ) *"="
+type challengeParser struct {
+	s string
+	i int
+}
+
+func (p *challengeParser) challenge() (Challenge, bool) {
+	for {
+		p.skip(whitespace + ",")
+		if p.i >= len(p.s) {
+			return Challenge{}, false
+		}
+
+		start := p.i
+		scheme := p.token()
+		if scheme == "" {
+			p.i++ // skip a malformed character
+			continue
+		}
+
+		c := Challenge{Scheme: scheme}
+		end := p.i
+		p.skip(whitespace)
+
+		if tok68, ok := p.token68(); ok {
+			c.Token68 = tok68
+			end = p.i
+		} else {
+			for {
+				mark := p.i
+				name, value, ok := p.param()
+				if !ok {
+					p.i = mark // the start of the next challenge
+					break
+				}
+				if c.Params == nil {
+					c.Params = make(map[string]string)
+				}
+				c.Params[strings.ToLower(name)] = value
+				end = p.i
+				p.skip(whitespace)
+				if p.i < len(p.s) && p.s[p.i] == ',' {
+					p.i++
+					p.skip(whitespace)
+				}
+			}
+		}
+
+		c.Raw = p.s[start:end]
+		return c, true
+	}
+}
+
+// token68 scans a token68 that is followed by a comma or the end.
+func (p *challengeParser) token68() (string, bool) {
+	start := p.i
+	for p.i < len(p.s) && isToken68Char(p.s[p.i]) {
+		p.i++
+	}
+	if p.i == start {
+		return "", false
+	}
+	for p.i < len(p.s) && p.s[p.i] == '=' {
+		p.i++
+	}
+	tok := p.s[start:p.i]
+
+	p.skip(whitespace)
+	if p.i < len(p.s) && p.s[p.i] != ',' {
+		p.i = start
+		return "", false
+	}
+	return tok, true
+}
+
+// param scans an auth-param.
+func (p *challengeParser) param() (name, value string, ok bool) {
+	name = p.token()
+	if name == "" {
+		return "", "", false
+	}
+	p.skip(whitespace)
+	if p.i >= len(p.s) || p.s[p.i] != '=' {
+		return "", "", false
+	}
+	p.i++
+	p.skip(whitespace)
+
+	if p.i < len(p.s) && p.s[p.i] == '"' {
+		return name, p.quotedString(), true
+	}
+
+	value = p.token()
+	return name, value, value != ""
+}
+
+func (p *challengeParser) quotedString() string {
+	var b strings.Builder
+	for p.i++; p.i < len(p.s); p.i++ {
+		switch c := p.s[p.i]; c {
+		case '\\':
+			if p.i+1 < len(p.s) {
+				p.i++
+				b.WriteByte(p.s[p.i])
+			}
+		case '"':
+			p.i++
+			return b.String()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String() // unterminated
+}
+
+func (p *challengeParser) token() string {
+	start := p.i
+	for p.i < len(p.s) && isTokenChar(p.s[p.i]) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// whitespace includes line breaks, which are tolerated although not allowed in header values.
+const whitespace = " \t\r\n"
+
+func (p *challengeParser) skip(chars string) {
+	for p.i < len(p.s) && strings.IndexByte(chars, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func isTokenChar(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+		strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+func isToken68Char(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+		strings.IndexByte("-._~+/", c) >= 0
+}
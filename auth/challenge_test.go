@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestParseChallenges(t *testing.T) {
+	cs := ParseChallenges([]string{
+		`Newauth realm="apps", type=1, title="Login to \"apps\", please", Basic realm="simple"`,
+		`Negotiate YIIB+w/=, bearer`,
+		`Digest  realm = "a,b" , nonce="x"`,
+		`, Basic`,
+	})
+
+	expect.Slice(cs).ToHaveLength(t, 6)
+
+	expect.String(cs[0].Scheme).ToBe(t, "Newauth")
+	expect.Map(cs[0].Params).ToBe(t, map[string]string{"realm": "apps", "type": "1", "title": `Login to "apps", please`})
+	expect.String(cs[0].Raw).ToBe(t, `Newauth realm="apps", type=1, title="Login to \"apps\", please"`)
+
+	expect.String(cs[1].Scheme).ToBe(t, "Basic")
+	expect.Map(cs[1].Params).ToBe(t, map[string]string{"realm": "simple"})
+
+	expect.String(cs[2].Scheme).ToBe(t, "Negotiate")
+	expect.String(cs[2].Token68).ToBe(t, "YIIB+w/=")
+	expect.Map(cs[2].Params).ToHaveLength(t, 0)
+
+	expect.String(cs[3].Scheme).ToBe(t, "bearer")
+	expect.String(cs[3].Raw).ToBe(t, "bearer")
+
+	expect.String(cs[4].Scheme).ToBe(t, "Digest")
+	expect.Map(cs[4].Params).ToBe(t, map[string]string{"realm": "a,b", "nonce": "x"})
+
+	expect.String(cs[5].Raw).ToBe(t, "Basic")
+}
+
+func TestChallengesFor(t *testing.T) {
+	cs := ParseChallenges([]string{`BEARER realm="a", Basic realm="b"`, `bearer error="invalid_token"`})
+
+	expect.Slice(ChallengesFor(cs, "Bearer")).ToBe(t, `Bearer realm="a"`, `Bearer error="invalid_token"`)
+	expect.Slice(ChallengesFor(cs, "Digest")).ToHaveLength(t, 0)
+}
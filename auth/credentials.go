@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// ProtectionSpace identifies where a user's credentials apply: the origin host and, once the
+// server has issued a challenge, the authentication scheme and realm (see RFC-9110 section 11.5).
+type ProtectionSpace struct {
+	Scheme string // e.g. "Basic"; blank when not known
+	Host   string // "host" or "host:port"
+	Realm  string // blank when not known
+}
+
+// CredentialsProvider finds the user's credentials for a protection space. Providers allow
+// one client to authenticate to several hosts, each with its own credentials.
+type CredentialsProvider interface {
+	Credentials(space ProtectionSpace) (user, password string, ok bool)
+}
+
+// CredentialsFunc is a function that implements [CredentialsProvider].
+type CredentialsFunc func(space ProtectionSpace) (user, password string, ok bool)
+
+// Credentials implements [CredentialsProvider].
+func (fn CredentialsFunc) Credentials(space ProtectionSpace) (user, password string, ok bool) {
+	return fn(space)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Credentials holds a user name and password.
+type Credentials struct {
+	User     string
+	Password string
+}
+
+// CredentialsMap is an in-memory [CredentialsProvider]. A key need not be complete: a blank
+// Scheme or Realm matches any, and a Host without a port matches any port. A blank Host matches
+// any host, so take care not to send such credentials to servers that should not see them.
+// When several keys match, the most specific is used. Names are not case-sensitive.
+type CredentialsMap map[ProtectionSpace]Credentials
+
+// Credentials implements [CredentialsProvider].
+func (m CredentialsMap) Credentials(space ProtectionSpace) (user, password string, ok bool) {
+	best := -1
+	for key, cred := range m {
+		score := key.match(space)
+		if score > best {
+			best = score
+			user, password, ok = cred.User, cred.Password, true
+		}
+	}
+	return user, password, ok
+}
+
+// match gets the specificity of the key if it matches the space, or -1 if not.
+func (key ProtectionSpace) match(space ProtectionSpace) int {
+	score := 0
+
+	switch {
+	case key.Host == "":
+	case strings.EqualFold(key.Host, space.Host):
+		score += 8
+	case !strings.Contains(key.Host, ":") && strings.EqualFold(key.Host, hostname(space.Host)):
+		score += 4
+	default:
+		return -1
+	}
+
+	switch {
+	case key.Realm == "":
+	case key.Realm == space.Realm: // realms are case-sensitive
+		score += 2
+	default:
+		return -1
+	}
+
+	switch {
+	case key.Scheme == "":
+	case strings.EqualFold(key.Scheme, space.Scheme):
+		score += 1
+	default:
+		return -1
+	}
+
+	return score
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Environment provides credentials from environment variables named after the host, i.e.
+// "<prefix>_<HOST>_USER" and "<prefix>_<HOST>_PASSWORD". The host name is upper-cased and its
+// punctuation changed to underscores, and the port is ignored. For example, with the prefix
+// "HTTPCLIENT", the credentials for "api.example.com" are read from HTTPCLIENT_API_EXAMPLE_COM_USER
+// and HTTPCLIENT_API_EXAMPLE_COM_PASSWORD. The variables are read whenever credentials are needed.
+func Environment(prefix string) CredentialsProvider {
+	return CredentialsFunc(func(space ProtectionSpace) (string, string, bool) {
+		name := prefix + "_" + strings.Map(func(r rune) rune {
+			if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+				return r
+			}
+			return '_'
+		}, strings.ToUpper(hostname(space.Host)))
+
+		user, ok := os.LookupEnv(name + "_USER")
+		if !ok {
+			return "", "", false
+		}
+		return user, os.Getenv(name + "_PASSWORD"), true
+	})
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// FirstOf combines several providers; the credentials are obtained from the first that has any.
+func FirstOf(providers ...CredentialsProvider) CredentialsProvider {
+	return CredentialsFunc(func(space ProtectionSpace) (string, string, bool) {
+		for _, p := range providers {
+			if user, password, ok := p.Credentials(space); ok {
+				return user, password, true
+			}
+		}
+		return "", "", false
+	})
+}
+
+// hostname removes any port from a host.
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestCredentialsMap(t *testing.T) {
+	m := CredentialsMap{
+		{Host: "example.com"}:                                   {User: "a", Password: "1"},
+		{Host: "example.com:8080"}:                              {User: "b", Password: "2"},
+		{Host: "example.com", Realm: "admin"}:                   {User: "c", Password: "3"},
+		{Scheme: "digest", Host: "example.com", Realm: "admin"}: {User: "d", Password: "4"},
+	}
+
+	cases := []struct {
+		space      ProtectionSpace
+		user, pass string
+		ok         bool
+	}{
+		{ProtectionSpace{Host: "example.com"}, "a", "1", true},
+		{ProtectionSpace{Host: "EXAMPLE.com:443", Scheme: "Basic"}, "a", "1", true},
+		{ProtectionSpace{Host: "example.com:8080", Realm: "users"}, "b", "2", true},
+		{ProtectionSpace{Host: "example.com", Scheme: "Basic", Realm: "admin"}, "c", "3", true},
+		{ProtectionSpace{Host: "example.com", Scheme: "Digest", Realm: "admin"}, "d", "4", true},
+		{ProtectionSpace{Host: "example.com", Scheme: "Digest", Realm: "Admin"}, "a", "1", true},
+		{ProtectionSpace{Host: "sub.example.com"}, "", "", false},
+		{ProtectionSpace{Host: "other.com:8080"}, "", "", false},
+	}
+
+	for i, c := range cases {
+		user, pass, ok := m.Credentials(c.space)
+		expect.Bool(ok).Info(i).ToBe(t, c.ok)
+		expect.String(user).Info(i).ToBe(t, c.user)
+		expect.String(pass).Info(i).ToBe(t, c.pass)
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	t.Setenv("TEST_API_EXAMPLE_COM_USER", "fred")
+	t.Setenv("TEST_API_EXAMPLE_COM_PASSWORD", "s3cret")
+
+	user, pass, ok := Environment("TEST").Credentials(ProtectionSpace{Host: "api.example.com:8443"})
+	expect.Bool(ok).ToBeTrue(t)
+	expect.String(user).ToBe(t, "fred")
+	expect.String(pass).ToBe(t, "s3cret")
+
+	_, _, ok = Environment("TEST").Credentials(ProtectionSpace{Host: "example.com"})
+	expect.Bool(ok).ToBeFalse(t)
+}
+
+func TestFirstOf(t *testing.T) {
+	p := FirstOf(
+		CredentialsMap{{Host: "a.com"}: {User: "a"}},
+		CredentialsMap{{Host: "a.com"}: {User: "x"}, {Host: "b.com"}: {User: "b"}},
+	)
+
+	user, _, _ := p.Credentials(ProtectionSpace{Host: "a.com"})
+	expect.String(user).ToBe(t, "a")
+
+	user, _, _ = p.Credentials(ProtectionSpace{Host: "b.com"})
+	expect.String(user).ToBe(t, "b")
+
+	_, _, ok := p.Credentials(ProtectionSpace{Host: "c.com"})
+	expect.Bool(ok).ToBeFalse(t)
+}
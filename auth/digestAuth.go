@@ -3,19 +3,28 @@ package auth
 import (
 	md5pkg "crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"unicode"
+
+	bodypkg "github.com/rickb777/httpclient/body"
 )
 
-var _ Authenticator = &DigestAuth{}
+var _ FallibleAuthenticator = &DigestAuth{}
 
 // Digest implements HTTP digest authentication.
 // See https://tools.ietf.org/html/rfc7616
-// TODO finish this: it only supports MD5 and the tests are inadequate
+//
+// The MD5, SHA-256 and SHA-512-256 algorithms are supported, as are their "-sess" variants.
+// When the server offers several challenges, the strongest algorithm is chosen. With "auth-int",
+// if the request entity cannot be read, TryAuthenticate fails, so the request is not sent.
 func Digest(user string, pw string) *DigestAuth {
 	return &DigestAuth{
 		user:        user,
@@ -26,9 +35,38 @@ func Digest(user string, pw string) *DigestAuth {
 
 // DigestAuth structure holds our credentials.
 type DigestAuth struct {
-	user        string
-	pw          string
+	user string
+	pw   string
+
+	mu          sync.Mutex // guards the following
 	digestParts map[string]string
+	nonceCount  uint32
+	sessCnonce  string
+}
+
+// digestAlgorithms lists the supported algorithms, weakest first.
+var digestAlgorithms = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{name: "MD5", hash: md5pkg.New},
+	{name: "SHA-256", hash: sha256.New},
+	{name: "SHA-512-256", hash: sha512.New512_256},
+}
+
+// digestStrength ranks an algorithm, ignoring any "-sess" suffix. It is -1 if unsupported.
+func digestStrength(algorithm string) int {
+	base := strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS")
+	for i, a := range digestAlgorithms {
+		if a.name == base {
+			return i
+		}
+	}
+	return -1
+}
+
+func digestHash(algorithm string) func() hash.Hash {
+	return digestAlgorithms[max(digestStrength(algorithm), 0)].hash
 }
 
 // Type identifies the Digest authenticator.
@@ -46,41 +84,89 @@ func (d *DigestAuth) Password() string {
 	return d.pw
 }
 
-// Authenticate the current request.
+// Authenticate the current request. See [DigestAuth.TryAuthenticate].
 func (d *DigestAuth) Authenticate(req *http.Request) {
-	d.digestParts["username"] = d.user
-	d.digestParts["password"] = d.pw
-	req.Header.Set("Authorization", getDigestAuthentication(req, d.digestParts))
+	_ = d.TryAuthenticate(req)
+}
+
+// TryAuthenticate authenticates the current request. Each request uses the next nonce count.
+// It returns an error if the request entity cannot be read for "auth-int", in which case no
+// header is set.
+func (d *DigestAuth) TryAuthenticate(req *http.Request) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.nonceCount++
+	authorization, err := d.getDigestAuthentication(req)
+	if err != nil {
+		d.nonceCount-- // this nonce count was not used
+		return err
+	}
+
+	req.Header.Set("Authorization", authorization)
+	return nil
 }
 
+// Challenge chooses the strongest supported algorithm from the "WWW-Authenticate" challenges,
+// all of which must use the Digest scheme. It panics if none of the algorithms are supported.
+//
+// It returns a new authenticator holding the same credentials; the receiver is not altered.
+// So authenticators for different origins or realms do not share nonces or nonce counts.
 func (d *DigestAuth) Challenge(ss []string) Authenticator {
+	best, bestStrength := "", -1
 	for _, s := range ss {
 		if !strings.HasPrefix(s, "Digest") {
 			panic("incorrect auth challenge: only 'Digest' expected here")
 		}
-	}
-	// TODO insert SHA-256, SHA-512-256
-	// we support MD5
-	for _, s := range ss {
-		if strings.Contains(s, "algorithm=MD5") {
-			return d.DigestParts(strings.TrimSpace(s[6:]))
+
+		strength := 0 // the algorithm defaults to MD5
+		if algorithm, exists := parseAuthParams(s[6:])["algorithm"]; exists {
+			strength = digestStrength(algorithm)
 		}
-	}
-	// we also default to MD5
-	for _, s := range ss {
-		if !strings.Contains(s, "algorithm") {
-			return d.DigestParts(strings.TrimSpace(s[6:]))
+
+		if strength > bestStrength {
+			best, bestStrength = s, strength
 		}
 	}
-	panic("unsupported Digest algorithm")
+
+	if bestStrength < 0 {
+		panic("unsupported Digest algorithm")
+	}
+
+	return Digest(d.user, d.pw).DigestParts(strings.TrimSpace(best[6:]))
 }
 
+// Supports is true if the challenge uses a supported algorithm.
+func (d *DigestAuth) Supports(c Challenge) bool {
+	algorithm, exists := c.Params["algorithm"]
+	return !exists || digestStrength(algorithm) >= 0
+}
+
+// DigestParts sets the parameters from a "WWW-Authenticate" challenge, excluding the leading
+// "Digest" scheme name. The nonce count restarts.
 func (d *DigestAuth) DigestParts(wwwAuthenticateHeader string) Authenticator {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	d.digestParts = map[string]string{"algorithm": "MD5"} // contains our default algorithm
-	wwwAuthenticateHeader = strings.TrimRightFunc(wwwAuthenticateHeader, unicode.IsSpace)
+	d.nonceCount = 0
+	d.sessCnonce = ""
 
-	// unwanted headers: domain, stale, charset, userhash
-	wantedHeaders := []string{"nonce", "realm", "qop", "opaque", "algorithm", "entityBody"}
+	wantedHeaders := []string{"nonce", "realm", "qop", "opaque", "algorithm", "userhash", "charset", "stale", "domain"}
+
+	for key, value := range parseAuthParams(wwwAuthenticateHeader) {
+		for _, w := range wantedHeaders {
+			if key == w {
+				d.digestParts[w] = value
+			}
+		}
+	}
+	return d
+}
+
+func parseAuthParams(wwwAuthenticateHeader string) map[string]string {
+	params := make(map[string]string)
+	wwwAuthenticateHeader = strings.TrimSpace(wwwAuthenticateHeader)
 
 	for len(wwwAuthenticateHeader) > 0 {
 		// We have to step through the header string token-by-token because commas can appear
@@ -88,22 +174,22 @@ func (d *DigestAuth) DigestParts(wwwAuthenticateHeader string) Authenticator {
 		// separators. Those inside are part of their value.
 		parts := strings.SplitN(wwwAuthenticateHeader, "=", 2)
 		if len(parts) < 2 {
-			return d
+			return params
 		}
 
 		var key, value string
-		key, wwwAuthenticateHeader = strings.TrimSpace(parts[0]), strings.TrimLeftFunc(parts[1], unicode.IsSpace)
+		key, wwwAuthenticateHeader = strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimLeftFunc(parts[1], unicode.IsSpace)
 		if strings.HasPrefix(wwwAuthenticateHeader, `"`) {
-			end := strings.IndexByte(wwwAuthenticateHeader[1:], '"') + 1
-			value = wwwAuthenticateHeader[1:end]
-			wwwAuthenticateHeader = wwwAuthenticateHeader[end+1:]
+			end := closingQuote(wwwAuthenticateHeader)
+			value = strings.ReplaceAll(wwwAuthenticateHeader[1:end], `\"`, `"`)
+			wwwAuthenticateHeader = wwwAuthenticateHeader[min(end+1, len(wwwAuthenticateHeader)):]
 		} else {
 			end := strings.IndexByte(wwwAuthenticateHeader, ',')
 			if end > 1 {
-				value = wwwAuthenticateHeader[0:end]
+				value = strings.TrimSpace(wwwAuthenticateHeader[0:end])
 				wwwAuthenticateHeader = wwwAuthenticateHeader[end:]
 			} else {
-				value = wwwAuthenticateHeader
+				value = strings.TrimSpace(wwwAuthenticateHeader)
 				wwwAuthenticateHeader = ""
 			}
 		}
@@ -112,105 +198,189 @@ func (d *DigestAuth) DigestParts(wwwAuthenticateHeader string) Authenticator {
 			wwwAuthenticateHeader = strings.TrimLeftFunc(wwwAuthenticateHeader[1:], unicode.IsSpace)
 		}
 
-		for _, w := range wantedHeaders {
-			if key == w {
-				d.digestParts[w] = value
-			}
-		}
+		params[key] = value
 	}
-	return d
+	return params
 }
 
-func md5(text string) string {
-	hasher := md5pkg.New()
-	hasher.Write([]byte(text))
-	return hex.EncodeToString(hasher.Sum(nil))
+// closingQuote finds the index of the closing double-quote in a string that starts with an
+// opening one, skipping escaped quotes. If there isn't one, the length of the string is returned.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return len(s)
 }
 
+//-------------------------------------------------------------------------------------------------
+
 var getCnonce = func() string {
 	b := make([]byte, 8)
 	io.ReadFull(rand.Reader, b)
 	return fmt.Sprintf("%x", b)[:16]
 }
 
-func getDigestAuthentication(req *http.Request, d map[string]string) string {
-	// These are the correct ha1 and ha2 for qop=auth. We should probably check for other types of qop.
-
+// getDigestAuthentication computes the Authorization header; d.mu must be held.
+func (d *DigestAuth) getDigestAuthentication(req *http.Request) (string, error) {
 	var (
+		p          = d.digestParts
+		algorithm  = p["algorithm"]
+		h          = hasher(digestHash(algorithm))
+		nonceCount = fmt.Sprintf("%08x", d.nonceCount)
+		cnonce     = getCnonce()
+		userhash   = strings.EqualFold(p["userhash"], "true")
 		ha1        string
 		ha2        string
-		nonceCount = "00000001"
-		cnonce     = getCnonce()
 		response   string
 	)
 
-	// 'ha1' value depends on value of "algorithm" field
-	switch d["algorithm"] {
-	case "MD5", "":
-		ha1 = md5(d["username"] + ":" + d["realm"] + ":" + d["password"])
-	case "MD5-sess":
-		ha1 = md5(fmt.Sprintf("%s:%v:%s",
-			md5(d["username"]+":"+d["realm"]+":"+d["password"]),
-			nonceCount,
-			cnonce))
-	}
-
 	// 'ha2' value depends on value of "qop" field
-	qops := strings.Split(d["qop"], ",")
-	chosenQop := ""
-	switch strings.TrimSpace(qops[0]) {
+	uri := req.URL.RequestURI()
+	chosenQop := chooseQop(req, p["qop"])
+	switch chosenQop {
 	case "auth", "":
-		ha2 = md5(req.Method + ":" + req.URL.Path)
-		chosenQop = "auth"
+		ha2 = h(req.Method + ":" + uri)
 	case "auth-int":
-		entityBody := d["entityBody"]
-		ha2 = md5(req.Method + ":" + req.URL.Path + ":" + md5(entityBody))
-		chosenQop = "auth-int"
+		eh, err := entityHash(req, digestHash(algorithm))
+		if err != nil {
+			return "", err
+		}
+		ha2 = h(req.Method + ":" + uri + ":" + eh)
+	}
+
+	// 'ha1' value depends on value of "algorithm" field
+	ha1 = h(d.user + ":" + p["realm"] + ":" + d.pw)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		// the session key is calculated once per nonce, using the first client nonce
+		if d.sessCnonce == "" {
+			d.sessCnonce = cnonce
+		}
+		cnonce = d.sessCnonce
+		ha1 = h(ha1 + ":" + p["nonce"] + ":" + cnonce)
 	}
 
 	// 'response' value depends on value of "qop" field
 	switch chosenQop {
 	case "":
-		response = md5(
-			fmt.Sprintf("%s:%s:%s",
-				ha1,
-				d["nonce"],
-				ha2,
-			),
-		)
+		response = h(fmt.Sprintf("%s:%s:%s", ha1, p["nonce"], ha2))
 	case "auth", "auth-int":
-		response = md5(
-			fmt.Sprintf("%s:%s:%v:%s:%s:%s",
-				ha1,
-				d["nonce"],
-				nonceCount,
-				cnonce,
-				chosenQop,
-				ha2,
-			),
-		)
+		response = h(fmt.Sprintf("%s:%s:%s:%s:%s:%s", ha1, p["nonce"], nonceCount, cnonce, chosenQop, ha2))
 	}
 
 	var authentication strings.Builder
-	fmt.Fprintf(&authentication, `Digest username="%s", realm="%s", uri="%s", algorithm=%s`,
-		d["username"],
-		d["realm"],
-		req.URL.Path,
-		d["algorithm"])
-
-	fmt.Fprintf(&authentication, `, nonce="%s", nc=%v, cnonce="%s", response="%s"`,
-		d["nonce"],
-		nonceCount,
-		cnonce,
-		response)
+	switch {
+	case userhash:
+		fmt.Fprintf(&authentication, `Digest username="%s"`, h(d.user+":"+p["realm"]))
+	case needsExtendedNotation(d.user):
+		fmt.Fprintf(&authentication, `Digest username*=UTF-8''%s`, extValueEscape(d.user))
+	default:
+		fmt.Fprintf(&authentication, `Digest username="%s"`, d.user)
+	}
+
+	fmt.Fprintf(&authentication, `, realm="%s", uri="%s", algorithm=%s`,
+		p["realm"],
+		uri,
+		algorithm)
+
+	fmt.Fprintf(&authentication, `, nonce="%s"`, p["nonce"])
+
+	if chosenQop != "" {
+		fmt.Fprintf(&authentication, `, nc=%s, cnonce="%s"`, nonceCount, cnonce)
+	}
+
+	fmt.Fprintf(&authentication, `, response="%s"`, response)
 
 	if chosenQop != "" {
 		fmt.Fprintf(&authentication, `, qop=%s`, chosenQop)
 	}
 
-	if d["opaque"] != "" {
-		fmt.Fprintf(&authentication, `, opaque="%s"`, d["opaque"])
+	if p["opaque"] != "" {
+		fmt.Fprintf(&authentication, `, opaque="%s"`, p["opaque"])
+	}
+
+	if userhash {
+		authentication.WriteString(`, userhash=true`)
+	}
+
+	return authentication.String(), nil
+}
+
+func hasher(newHash func() hash.Hash) func(string) string {
+	return func(text string) string {
+		hasher := newHash()
+		hasher.Write([]byte(text))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+}
+
+// chooseQop picks the first quality of protection offered by the server. "auth-int" is
+// passed over in favour of "auth" if the request entity cannot be re-read.
+func chooseQop(req *http.Request, offered string) string {
+	if offered == "" {
+		return ""
+	}
+
+	authInt := false
+	for _, q := range strings.Split(offered, ",") {
+		switch strings.TrimSpace(q) {
+		case "auth":
+			return "auth"
+		case "auth-int":
+			if canReadEntity(req) {
+				return "auth-int"
+			}
+			authInt = true
+		}
+	}
+
+	if authInt {
+		return "auth-int" // the entity will be buffered
 	}
+	return ""
+}
 
-	return authentication.String()
+func canReadEntity(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// entityHash computes the hash of the request entity, as needed for "auth-int".
+func entityHash(req *http.Request, newHash func() hash.Hash) (string, error) {
+	b, err := bodypkg.RequestEntity(req)
+	if err != nil {
+		return "", err
+	}
+	hasher := newHash()
+	hasher.Write(b)
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// needsExtendedNotation is true if the username cannot be sent as a quoted string
+// (see RFC-7616 section 3.4.4).
+func needsExtendedNotation(user string) bool {
+	for _, r := range user {
+		if r > unicode.MaxASCII || r == '"' || r == '\\' || unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// extValueEscape percent-encodes a string for use in an ext-value (see RFC-8187 section 3.2.1).
+func extValueEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+			strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
 }
@@ -1,44 +1,187 @@
 package auth
 
 import (
+	"io"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/rickb777/expect"
+	"github.com/rickb777/httpclient/internal/mytesting"
 )
 
 // see https://datatracker.ietf.org/doc/html/rfc7616#section-3.9
 
-func TestDigest_Authorize(t *testing.T) {
-	req := httptest.NewRequest("GET", "/dir/index.html", nil)
-	getCnonce = func() string { return "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ" }
-
-	const c1 = `Digest
+const (
+	rfcSHA256 = `Digest
 		realm="http-auth@example.org",
 		qop="auth, auth-int",
 		algorithm=SHA-256,
 		nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
 		opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`
 
-	const c2 = `Digest
+	rfcMD5 = `Digest
 		realm="http-auth@example.org",
 		qop="auth, auth-int",
 		algorithm=MD5,
 		nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
 		opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`
+)
+
+func TestDigest_Authorize(t *testing.T) {
+	req := httptest.NewRequest("GET", "/dir/index.html", nil)
+	getCnonce = func() string { return "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ" }
 
 	digest := Deferred("Mufasa", "Circle of Life")
-	digest.Challenge([]string{c1, c2}).Authenticate(req)
+	digest.Challenge([]string{rfcMD5, rfcSHA256}).Authenticate(req)
 
 	expect.String(req.Header.Get("Authorization")).ToBe(t,
 		`Digest username="Mufasa", `+
 			`realm="http-auth@example.org", `+
 			`uri="/dir/index.html", `+
-			`algorithm=MD5, `+
+			`algorithm=SHA-256, `+
 			`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", `+
 			`nc=00000001, `+
 			`cnonce="f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", `+
-			`response="8ca523f5e9506fed4657c9700eebdbec", `+
+			`response="753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1", `+
 			`qop=auth, `+
 			`opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
 }
+
+func TestDigest_Authorize_MD5(t *testing.T) {
+	req := httptest.NewRequest("GET", "/dir/index.html", nil)
+	getCnonce = func() string { return "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ" }
+
+	digest := Deferred("Mufasa", "Circle of Life")
+	digest.Challenge([]string{rfcMD5}).Authenticate(req)
+
+	expect.String(req.Header.Get("Authorization")).ToContain(t, `algorithm=MD5, `)
+	expect.String(req.Header.Get("Authorization")).ToContain(t, `response="8ca523f5e9506fed4657c9700eebdbec"`)
+}
+
+func TestDigest_userhash(t *testing.T) {
+	req := httptest.NewRequest("GET", "/doe.json", nil)
+	getCnonce = func() string { return "NTg6RKcb9boFIAS3KrFK9BGeh+iDa/sm6jUMp2wds69v" }
+
+	digest := Digest("Jäsøn Doe", "Secret, or not?")
+	digest.Challenge([]string{`Digest realm="api@example.org", qop="auth", algorithm=SHA-512-256, ` +
+		`nonce="5TsQWLVdgBdmrQ0XsxbDODV+57QdFR34I9HAbC/RVvkK", opaque="HRPCssKJSGjCrkzDg8OhwpzCiGPChXYjwrI2QmXDnsOS", ` +
+		`charset=UTF-8, userhash=true`}).Authenticate(req)
+
+	a := req.Header.Get("Authorization")
+	expect.String(a).ToContain(t, `Digest username="793263caabb707a56211940d90411ea4a575adeccb7e360aeb624ed06ece9b0b", `)
+	expect.String(a).ToContain(t, `algorithm=SHA-512-256, `)
+	expect.String(a).ToContain(t, `, userhash=true`)
+}
+
+func TestDigest_extended_username(t *testing.T) {
+	req := httptest.NewRequest("GET", "/doe.json", nil)
+
+	digest := Digest("Jäsøn Doe", "Secret, or not?")
+	digest.Challenge([]string{`Digest realm="api@example.org", qop="auth", algorithm=SHA-256, nonce="abc", charset=UTF-8`}).Authenticate(req)
+
+	expect.String(req.Header.Get("Authorization")).ToContain(t, `Digest username*=UTF-8''J%C3%A4s%C3%B8n%20Doe, `)
+}
+
+func TestDigest_nonce_count_increments(t *testing.T) {
+	digest := Digest("Mufasa", "Circle of Life").Challenge([]string{rfcSHA256})
+
+	for _, nc := range []string{"nc=00000001", "nc=00000002", "nc=00000003"} {
+		req := httptest.NewRequest("GET", "/dir/index.html", nil)
+		digest.Authenticate(req)
+		expect.String(req.Header.Get("Authorization")).ToContain(t, nc)
+	}
+
+	// a new challenge restarts the count, without altering the original
+	renewed := digest.Challenge([]string{rfcSHA256})
+	req := httptest.NewRequest("GET", "/dir/index.html", nil)
+	renewed.Authenticate(req)
+	expect.String(req.Header.Get("Authorization")).ToContain(t, "nc=00000001")
+
+	req = httptest.NewRequest("GET", "/dir/index.html", nil)
+	digest.Authenticate(req)
+	expect.String(req.Header.Get("Authorization")).ToContain(t, "nc=00000004")
+}
+
+func TestDigest_auth_int_body_read_failure(t *testing.T) {
+	digest := Digest("Mufasa", "Circle of Life").DigestParts(`realm="r", qop="auth-int", nonce="n1"`).(*DigestAuth)
+
+	req, _ := http.NewRequest("PUT", "/dir/index.html", io.NopCloser(iotest.ErrReader(io.ErrClosedPipe)))
+	err := digest.TryAuthenticate(req)
+
+	expect.Error(err).ToBe(t, io.ErrClosedPipe)
+	expect.String(req.Header.Get("Authorization")).ToBe(t, "")
+
+	req = httptest.NewRequest("PUT", "/dir/index.html", strings.NewReader("hello"))
+	expect.Error(digest.TryAuthenticate(req)).Not().ToHaveOccurred(t)
+	expect.String(req.Header.Get("Authorization")).ToContain(t, "nc=00000001")
+}
+
+func TestDigest_without_qop(t *testing.T) {
+	req := httptest.NewRequest("GET", "/dir/index.html", nil)
+
+	digest := Digest("Mufasa", "Circle of Life")
+	digest.Challenge([]string{`Digest realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093"`}).Authenticate(req)
+
+	a := req.Header.Get("Authorization")
+	expect.String(a).ToContain(t, `nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", response="`)
+	expect.String(a).Not().ToContain(t, "nc=")
+	expect.String(a).Not().ToContain(t, "qop=")
+}
+
+func TestDigest_unsupported_algorithm(t *testing.T) {
+	defer func() {
+		expect.Any(recover()).ToBe(t, "unsupported Digest algorithm")
+	}()
+
+	Digest("Mufasa", "Circle of Life").Challenge([]string{`Digest realm="x", nonce="y", algorithm=SHA-1`})
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func TestDigest_interop(t *testing.T) {
+	cases := map[string]*mytesting.DigestServer{
+		"MD5":              {Algorithms: []string{"MD5"}, Qop: "auth"},
+		"MD5-sess":         {Algorithms: []string{"MD5-sess"}, Qop: "auth"},
+		"SHA-256":          {Algorithms: []string{"SHA-256"}, Qop: "auth"},
+		"SHA-256-sess":     {Algorithms: []string{"SHA-256-sess"}, Qop: "auth"},
+		"SHA-512-256":      {Algorithms: []string{"MD5", "SHA-512-256", "SHA-256"}, Qop: "auth"},
+		"SHA-512-256-sess": {Algorithms: []string{"SHA-512-256-sess"}, Qop: "auth"},
+		"auth-int":         {Algorithms: []string{"SHA-256"}, Qop: "auth-int"},
+		"userhash":         {Algorithms: []string{"SHA-256"}, Qop: "auth", UserHash: true},
+		"no qop":           {Algorithms: []string{"MD5"}},
+	}
+
+	for name, ds := range cases {
+		ds.Realm = "test@example.org"
+		ds.Users = map[string]string{"Mufasa": "Circle of Life"}
+		svr := httptest.NewServer(ds)
+
+		var digest Authenticator = Deferred("Mufasa", "Circle of Life")
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest("PUT", svr.URL+"/dir/index.html?q=1", strings.NewReader("some content"))
+			digest.Authenticate(req)
+			res, err := http.DefaultClient.Do(req)
+			expect.Error(err).I(name).Not().ToHaveOccurred(t)
+
+			if res.StatusCode == http.StatusUnauthorized {
+				digest = digest.Challenge(res.Header.Values("Www-Authenticate"))
+				req, _ = http.NewRequest("PUT", svr.URL+"/dir/index.html?q=1", strings.NewReader("some content"))
+				digest.Authenticate(req)
+				res, err = http.DefaultClient.Do(req)
+				expect.Error(err).I(name).Not().ToHaveOccurred(t)
+			}
+
+			expect.Number(res.StatusCode).I(name).ToBe(t, http.StatusNoContent)
+		}
+
+		expect.Slice(ds.Authorized).I(name).ToHaveLength(t, 3)
+		if ds.Qop != "" {
+			expect.String(ds.Authorized[2]["nc"]).I(name).ToBe(t, "00000003")
+		}
+		expect.Number(ds.Challenges).I(name).ToBe(t, 1)
+		svr.Close()
+	}
+}
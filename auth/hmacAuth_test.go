@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestHMAC_github_style(t *testing.T) {
+	// https://docs.github.com/en/webhooks/using-webhooks/validating-webhook-deliveries
+	h := HMAC(HMACConfig{
+		Secret:          []byte("It's a Secret to Everybody"),
+		Template:        "{{.Body}}",
+		SignatureHeader: "X-Hub-Signature-256",
+		SignaturePrefix: "sha256=",
+	})
+
+	req := httptest.NewRequest("POST", "http://example.com/hook", strings.NewReader("Hello, World!"))
+	err := h.TryAuthenticate(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(req.Header.Get("X-Hub-Signature-256")).ToBe(t, "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17")
+	expect.String(req.Header.Get("X-Timestamp")).ToBe(t, "")
+
+	// the entity was buffered so that it can still be sent
+	b, _ := io.ReadAll(req.Body)
+	expect.String(string(b)).ToBe(t, "Hello, World!")
+}
+
+func TestHMAC_stripe_style(t *testing.T) {
+	setNow(t, time.Unix(1700000000, 0))
+	h := HMAC(HMACConfig{Secret: []byte("whsec_test"), Template: "{{.Timestamp}}.{{.Body}}"})
+
+	req := httptest.NewRequest("POST", "http://example.com/hook", strings.NewReader(`{"id":1}`))
+	h.Authenticate(req)
+
+	expect.String(req.Header.Get("X-Timestamp")).ToBe(t, "1700000000")
+	expect.String(req.Header.Get("X-Signature")).ToBe(t, "2f441ba4b3b2d50d28a9ab9d9fd8880376ecd1eb5d0435401553f5d8d0a5dcf8")
+}
+
+func TestHMAC_sha512_base64_with_nonce(t *testing.T) {
+	setNow(t, time.Unix(1700000000, 0))
+	h := HMAC(HMACConfig{
+		KeyID:           "key1",
+		Secret:          []byte("k"),
+		Hash:            crypto.SHA512,
+		Template:        "{{.Method}}\n{{.Path}}\n{{.Timestamp}}\n{{.Nonce}}\n{{.BodyHash}}",
+		TimestampLayout: time.RFC3339,
+		Nonce:           func() string { return "n1" },
+		Encoding:        base64.StdEncoding.EncodeToString,
+	})
+
+	req := httptest.NewRequest("POST", "http://example.com/hook", strings.NewReader(`{"id":1}`))
+	h.Authenticate(req)
+
+	expect.String(h.User()).ToBe(t, "key1")
+	expect.String(req.Header.Get("X-Key-Id")).ToBe(t, "key1")
+	expect.String(req.Header.Get("X-Nonce")).ToBe(t, "n1")
+	expect.String(req.Header.Get("X-Timestamp")).ToBe(t, "2023-11-14T22:13:20Z")
+	expect.String(req.Header.Get("X-Signature")).ToBe(t, "6reRJt5Uy0O7OxV0bc8UwkO8oh4ggWVSf0D0Z6xA7PZ/7bI5SYCmclnV867t4RMNbuBnND68qxU72bQdDF5VoA==")
+}
+
+func TestHMAC_round_trip(t *testing.T) {
+	cfg := HMACConfig{
+		KeyID:    "key1",
+		Secret:   []byte("s3cret"),
+		Template: DefaultHMACTemplate + "\n{{.Query}}\n{{.Header \"Content-Type\"}}",
+		Nonce:    RandomNonce,
+	}
+
+	var verified error
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		verified = HMAC(cfg).Verify(req, time.Minute)
+		b, _ := io.ReadAll(req.Body)
+		w.Write(b)
+	}))
+	defer svr.Close()
+
+	req, _ := http.NewRequest("PUT", svr.URL+"/a%20b?x=1", strings.NewReader("hello"))
+	req.Header.Set("Content-Type", "text/plain")
+	HMAC(cfg).Authenticate(req)
+	expect.String(req.Header.Get("X-Nonce")).ToHaveLength(t, 32)
+
+	res, err := http.DefaultClient.Do(req)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	b, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	expect.Error(verified).Not().ToHaveOccurred(t)
+	expect.String(string(b)).ToBe(t, "hello")
+}
+
+func TestHMAC_sign_failure(t *testing.T) {
+	h := HMAC(HMACConfig{Secret: []byte("s3cret"), Template: "{{.Missing}}"})
+
+	req := httptest.NewRequest("POST", "/hook", strings.NewReader("hello"))
+	err := h.TryAuthenticate(req)
+
+	expect.Error(err).ToContain(t, "Missing")
+	expect.String(req.Header.Get("X-Signature")).ToBe(t, "")
+}
+
+func TestHMAC_Verify_failures(t *testing.T) {
+	setNow(t, time.Unix(1700000000, 0))
+	h := HMAC(HMACConfig{KeyID: "key1", Secret: []byte("s3cret")})
+
+	signed := func() *http.Request {
+		req := httptest.NewRequest("POST", "/hook", strings.NewReader("hello"))
+		h.Authenticate(req)
+		return req
+	}
+
+	expect.Error(h.Verify(signed(), time.Minute)).Not().ToHaveOccurred(t)
+
+	req := httptest.NewRequest("POST", "/hook", strings.NewReader("hello"))
+	expect.Any(h.Verify(req, time.Minute)).ToBe(t, ErrHMACMissing)
+
+	req = signed()
+	req.Body = io.NopCloser(strings.NewReader("hello!"))
+	req.GetBody = nil
+	expect.Any(h.Verify(req, time.Minute)).ToBe(t, ErrHMACInvalid)
+
+	req = signed()
+	req.Header.Set("X-Key-Id", "key2")
+	expect.Any(h.Verify(req, time.Minute)).ToBe(t, ErrHMACInvalid)
+
+	expect.Any(HMAC(HMACConfig{KeyID: "key1", Secret: []byte("other")}).Verify(signed(), 0)).ToBe(t, ErrHMACInvalid)
+
+	req = signed()
+	setNow(t, time.Unix(1700000061, 0))
+	expect.Any(h.Verify(req, time.Minute)).ToBe(t, ErrHMACTimestamp)
+	expect.Error(h.Verify(req, 0)).Not().ToHaveOccurred(t)
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/rickb777/httpclient/httpsig"
+)
+
+var _ FallibleAuthenticator = &MessageSignatureAuth{}
+
+// MessageSignature signs requests using HTTP message signatures.
+// See https://www.rfc-editor.org/rfc/rfc9421
+//
+// When "content-digest" is covered, the Content-Digest header (RFC-9530) is computed
+// from the buffered request entity. If a request cannot be signed, TryAuthenticate fails,
+// so the request is not sent.
+func MessageSignature(signer *httpsig.Signer) *MessageSignatureAuth {
+	return &MessageSignatureAuth{signer: signer}
+}
+
+// MessageSignatureAuth structure holds the signer.
+type MessageSignatureAuth struct {
+	signer *httpsig.Signer
+}
+
+// Type identifies the message signature authenticator.
+func (m *MessageSignatureAuth) Type() string {
+	return "Signature"
+}
+
+// User holds the key identifier.
+func (m *MessageSignatureAuth) User() string {
+	return m.signer.KeyID()
+}
+
+// Password is blank for message signatures.
+func (m *MessageSignatureAuth) Password() string {
+	return ""
+}
+
+// Challenge has no effect because message signatures are not negotiated using
+// authentication challenges.
+func (m *MessageSignatureAuth) Challenge([]string) Authenticator {
+	return m
+}
+
+// Authenticate signs the current request. See [MessageSignatureAuth.TryAuthenticate].
+func (m *MessageSignatureAuth) Authenticate(req *http.Request) {
+	_ = m.TryAuthenticate(req)
+}
+
+// TryAuthenticate signs the current request, returning an error if it cannot be signed.
+func (m *MessageSignatureAuth) TryAuthenticate(req *http.Request) error {
+	return m.signer.Sign(req)
+}
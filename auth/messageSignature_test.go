@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rickb777/expect"
+	bodypkg "github.com/rickb777/httpclient/body"
+	"github.com/rickb777/httpclient/httpsig"
+)
+
+func TestMessageSignature_Authenticate(t *testing.T) {
+	key := httpsig.HMACKey("secret")
+	b := bodypkg.NewBodyString(`{"hello": "world"}`)
+	req, _ := http.NewRequest("POST", "https://example.com/foo", b)
+
+	ms := MessageSignature(httpsig.NewSigner(httpsig.Config{Key: key, KeyID: "my-key"}))
+	err := ms.TryAuthenticate(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(ms.User()).ToBe(t, "my-key")
+	expect.String(req.Header.Get("Content-Digest")).ToBe(t, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:")
+	expect.String(req.Header.Get("Signature-Input")).ToContain(t, `sig1=("@method" "@target-uri" "@authority" "content-digest");created=`)
+
+	v := &httpsig.Verifier{Keys: httpsig.StaticKeys(map[string]httpsig.VerifyingKey{"my-key": key})}
+	expect.Error(v.VerifyRequest(req)).Not().ToHaveOccurred(t)
+}
+
+func TestMessageSignature_error(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://example.com/foo", nil)
+
+	ms := MessageSignature(httpsig.NewSigner(httpsig.Config{Key: httpsig.HMACKey("secret"), Components: []string{"x-missing"}}))
+	err := ms.TryAuthenticate(req)
+
+	expect.Error(err).ToHaveOccurred(t)
+	expect.String(req.Header.Get("Signature")).ToBe(t, "")
+	expect.Any(ms.Challenge([]string{"Basic"})).ToBe(t, Authenticator(ms))
+}
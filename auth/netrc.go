@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Netrc provides credentials from a ".netrc" file, as used by curl, ftp, git and others.
+// If the file name is blank, the file named by the NETRC environment variable is used,
+// otherwise "~/.netrc" ("~/_netrc" on Windows); in this case, it is not an error if the
+// file does not exist.
+//
+// Each "machine" entry is matched against the host, with or without its port; the first
+// matching entry is used. Realms are not supported by the file format, so the credentials
+// apply to every realm on the host. The "default" entry is ignored because it would supply
+// credentials to any host; see [NetrcWithDefault].
+func Netrc(name string) (CredentialsProvider, error) {
+	return loadNetrc(name, false)
+}
+
+// NetrcWithDefault is like [Netrc] except that the "default" entry, if any, is used for
+// hosts that have no "machine" entry. Beware that those credentials are then sent to
+// every host that challenges for them.
+func NetrcWithDefault(name string) (CredentialsProvider, error) {
+	return loadNetrc(name, true)
+}
+
+func loadNetrc(name string, useDefault bool) (CredentialsProvider, error) {
+	optional := false
+	if name == "" {
+		name, optional = os.Getenv("NETRC"), true
+		if name == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return netrc{}, nil
+			}
+			name = filepath.Join(home, netrcName())
+		}
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return netrc{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := parseNetrc(f)
+	if !useDefault {
+		entries = slices.DeleteFunc(entries, func(e netrcEntry) bool { return e.machine == "" })
+	}
+	return entries, err
+}
+
+func netrcName() string {
+	if runtime.GOOS == "windows" {
+		return "_netrc"
+	}
+	return ".netrc"
+}
+
+type netrcEntry struct {
+	machine  string // blank for the default entry
+	login    string
+	password string
+}
+
+type netrc []netrcEntry
+
+// Credentials implements [CredentialsProvider].
+func (n netrc) Credentials(space ProtectionSpace) (user, password string, ok bool) {
+	for _, e := range n {
+		if e.machine != "" && (strings.EqualFold(e.machine, space.Host) || strings.EqualFold(e.machine, hostname(space.Host))) {
+			return e.login, e.password, true
+		}
+	}
+	for _, e := range n {
+		if e.machine == "" {
+			return e.login, e.password, true
+		}
+	}
+	return "", "", false
+}
+
+// parseNetrc reads the entries. Tokens are separated by white space and may be quoted.
+// Macro definitions ("macdef") are skipped, up to the next blank line.
+func parseNetrc(r io.Reader) (netrc, error) {
+	var entries netrc
+	var current *netrcEntry
+
+	scanner := bufio.NewScanner(r)
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		tokens := netrcTokens(line)
+		for i := 0; i < len(tokens); i++ {
+			value := func() string {
+				if i+1 < len(tokens) {
+					i++
+					return tokens[i]
+				}
+				return ""
+			}
+
+			switch tokens[i] {
+			case "machine":
+				entries = append(entries, netrcEntry{machine: value()})
+				current = &entries[len(entries)-1]
+			case "default":
+				entries = append(entries, netrcEntry{})
+				current = &entries[len(entries)-1]
+			case "login":
+				if v := value(); current != nil {
+					current.login = v
+				}
+			case "password":
+				if v := value(); current != nil {
+					current.password = v
+				}
+			case "account":
+				value()
+			case "macdef":
+				value()
+				inMacro = true
+				i = len(tokens)
+			default:
+				if strings.HasPrefix(tokens[i], "#") {
+					i = len(tokens) // a comment
+				}
+			}
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// netrcTokens splits a line into tokens, which may be quoted using double quotes and
+// may contain backslash escapes.
+func netrcTokens(line string) []string {
+	var tokens []string
+	var b strings.Builder
+	inToken, quoted := false, false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			i++
+			b.WriteByte(line[i])
+			inToken = true
+		case c == '"':
+			quoted = !quoted
+			inToken = true
+		case !quoted && (c == ' ' || c == '\t' || c == '\r'):
+			if inToken {
+				tokens = append(tokens, b.String())
+				b.Reset()
+				inToken = false
+			}
+		default:
+			b.WriteByte(c)
+			inToken = true
+		}
+	}
+
+	if inToken {
+		tokens = append(tokens, b.String())
+	}
+	return tokens
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+const testNetrc = `# a comment
+machine api.example.com login fred password "pass word"
+machine example.com:8080
+	login bob
+	password p\"w
+
+macdef init
+cd /pub
+machine evil.example.com login mallory password x
+
+machine ftp.example.com account acct login carol password pw3
+default login anonymous password user@example.com
+`
+
+func TestNetrc_parse(t *testing.T) {
+	n, err := parseNetrc(strings.NewReader(testNetrc))
+	expect.Error(err).Not().ToHaveOccurred(t)
+
+	cases := []struct {
+		host, user, pass string
+	}{
+		{"api.example.com", "fred", "pass word"},
+		{"API.example.com:443", "fred", "pass word"},
+		{"example.com:8080", "bob", `p"w`},
+		{"ftp.example.com", "carol", "pw3"},
+		{"example.com", "anonymous", "user@example.com"},
+		{"evil.example.com", "anonymous", "user@example.com"}, // within the macro
+	}
+
+	for _, c := range cases {
+		user, pass, ok := n.Credentials(ProtectionSpace{Host: c.host})
+		expect.Bool(ok).Info(c.host).ToBeTrue(t)
+		expect.String(user).Info(c.host).ToBe(t, c.user)
+		expect.String(pass).Info(c.host).ToBe(t, c.pass)
+	}
+}
+
+func TestNetrc_files(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "netrc")
+	expect.Error(os.WriteFile(name, []byte("machine example.com login fred password pw"), 0600)).Not().ToHaveOccurred(t)
+
+	t.Setenv("NETRC", name)
+	n, err := Netrc("")
+	expect.Error(err).Not().ToHaveOccurred(t)
+	user, _, ok := n.Credentials(ProtectionSpace{Host: "example.com"})
+	expect.Bool(ok).ToBeTrue(t)
+	expect.String(user).ToBe(t, "fred")
+
+	// the default file is optional
+	t.Setenv("NETRC", filepath.Join(dir, "missing"))
+	n, err = Netrc("")
+	expect.Error(err).Not().ToHaveOccurred(t)
+	_, _, ok = n.Credentials(ProtectionSpace{Host: "example.com"})
+	expect.Bool(ok).ToBeFalse(t)
+
+	// but a named file is not
+	_, err = Netrc(filepath.Join(dir, "missing"))
+	expect.Error(err).ToHaveOccurred(t)
+}
+
+func TestNetrc_default_entry(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "netrc")
+	expect.Error(os.WriteFile(name, []byte(testNetrc), 0600)).Not().ToHaveOccurred(t)
+
+	n, err := Netrc(name)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	user, _, ok := n.Credentials(ProtectionSpace{Host: "api.example.com"})
+	expect.Bool(ok).ToBeTrue(t)
+	expect.String(user).ToBe(t, "fred")
+	_, _, ok = n.Credentials(ProtectionSpace{Host: "other.example.org"})
+	expect.Bool(ok).ToBeFalse(t)
+
+	n, err = NetrcWithDefault(name)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	user, _, ok = n.Credentials(ProtectionSpace{Host: "other.example.org"})
+	expect.Bool(ok).ToBeTrue(t)
+	expect.String(user).ToBe(t, "anonymous")
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rickb777/httpclient"
+)
+
+// now provides the current time. It can be altered for testing.
+var now = time.Now
+
+// OAuth2Config configures an OAuth 2.0 token source (see RFC-6749).
+type OAuth2Config struct {
+	// TokenURL is the token endpoint of the authorization server.
+	TokenURL string
+
+	// ClientID and ClientSecret identify the client. These are sent using HTTP Basic
+	// authentication unless SecretInBody is true.
+	ClientID     string
+	ClientSecret string
+	SecretInBody bool
+
+	// Scopes optionally lists the requested scopes.
+	Scopes []string
+
+	// RefreshToken, if not blank, is used to obtain the first access token with the refresh-token
+	// grant. Refresh tokens issued by the server are used subsequently.
+	RefreshToken string
+
+	// EndpointParams holds additional parameters for token requests, e.g. "audience".
+	EndpointParams url.Values
+
+	// ExpiryDelta is how long before expiry a token is renewed. Zero means 30 seconds.
+	ExpiryDelta time.Duration
+
+	// HttpClient is used for token requests. If nil, httpclient.DefaultClient is used.
+	HttpClient httpclient.HttpClient
+}
+
+// OAuth2Source obtains access tokens from an OAuth 2.0 token endpoint using the
+// client-credentials grant (RFC-6749 section 4.4), or the refresh-token grant (section 6) when a
+// refresh token is available. Tokens are cached until shortly before they expire.
+//
+// It is safe for concurrent use; when a token needs renewing, only one request is sent to
+// the token endpoint and other goroutines wait for its result.
+type OAuth2Source struct {
+	cfg OAuth2Config
+
+	mu           sync.Mutex // guards the following
+	accessToken  string
+	refreshToken string
+	expiry       time.Time // zero if the token does not expire
+}
+
+// OAuth2ClientCredentials creates a token source using the client-credentials grant.
+func OAuth2ClientCredentials(cfg OAuth2Config) *OAuth2Source {
+	if cfg.HttpClient == nil {
+		cfg.HttpClient = httpclient.DefaultClient
+	}
+	if cfg.ExpiryDelta <= 0 {
+		cfg.ExpiryDelta = 30 * time.Second
+	}
+	return &OAuth2Source{cfg: cfg, refreshToken: cfg.RefreshToken}
+}
+
+// Token gets the current access token, obtaining a new one if it has expired.
+func (s *OAuth2Source) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && (s.expiry.IsZero() || now().Before(s.expiry.Add(-s.cfg.ExpiryDelta))) {
+		return s.accessToken, nil
+	}
+
+	if s.refreshToken != "" {
+		err := s.fetch(ctx, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {s.refreshToken}})
+		if err == nil {
+			return s.accessToken, nil
+		}
+		s.refreshToken = "" // expired or revoked; fall back to the client credentials
+	}
+
+	err := s.fetch(ctx, url.Values{"grant_type": {"client_credentials"}})
+	return s.accessToken, err
+}
+
+// Invalidate discards the access token if it is the current one.
+func (s *OAuth2Source) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token == s.accessToken {
+		s.accessToken = ""
+	}
+}
+
+// tokenResponse is the successful response (RFC-6749 section 5.1) or error response (section 5.2).
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// TokenError is returned when the token endpoint rejects a token request.
+type TokenError struct {
+	StatusCode  int
+	Code        string // e.g. "invalid_client"
+	Description string
+}
+
+func (e *TokenError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth2: %d %s: %s", e.StatusCode, e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth2: %d %s", e.StatusCode, e.Code)
+}
+
+// fetch sends a token request; s.mu must be held.
+func (s *OAuth2Source) fetch(ctx context.Context, form url.Values) error {
+	s.accessToken = ""
+
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	for k, vs := range s.cfg.EndpointParams {
+		form[k] = vs
+	}
+	if s.cfg.SecretInBody {
+		form.Set("client_id", s.cfg.ClientID)
+		form.Set("client_secret", s.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !s.cfg.SecretInBody {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+
+	requested := now()
+	res, err := s.cfg.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var tr tokenResponse
+	decodeErr := json.NewDecoder(res.Body).Decode(&tr)
+
+	if res.StatusCode != http.StatusOK || tr.Error != "" {
+		return &TokenError{StatusCode: res.StatusCode, Code: tr.Error, Description: tr.ErrorDescription}
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("oauth2: invalid token response: %w", decodeErr)
+	}
+	if tr.AccessToken == "" {
+		return fmt.Errorf("oauth2: token response has no access_token")
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return fmt.Errorf("oauth2: unsupported token type %q", tr.TokenType)
+	}
+
+	s.accessToken = tr.AccessToken
+	s.expiry = time.Time{}
+	if tr.ExpiresIn > 0 {
+		s.expiry = requested.Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	if tr.RefreshToken != "" {
+		s.refreshToken = tr.RefreshToken
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+type tokenServer struct {
+	issued  atomic.Int32
+	grants  []string
+	mu      sync.Mutex
+	reject  bool
+	refresh bool
+}
+
+func (ts *tokenServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	_ = req.ParseForm()
+
+	ts.mu.Lock()
+	ts.grants = append(ts.grants, req.PostForm.Get("grant_type"))
+	ts.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, secret, _ := req.BasicAuth()
+	if ts.reject || id != "my-client" || secret != "s3cret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"invalid_client","error_description":"unknown client"}`)
+		return
+	}
+
+	if req.PostForm.Get("grant_type") == "refresh_token" && req.PostForm.Get("refresh_token") != "rt1" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant"}`)
+		return
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	n := ts.issued.Add(1)
+	if ts.refresh {
+		fmt.Fprintf(w, `{"access_token":"tok%d","token_type":"Bearer","expires_in":3600,"refresh_token":"rt1","scope":"%s"}`, n, req.PostForm.Get("scope"))
+	} else {
+		fmt.Fprintf(w, `{"access_token":"tok%d","token_type":"bearer","expires_in":3600}`, n)
+	}
+}
+
+func setNow(t *testing.T, tm time.Time) {
+	now = func() time.Time { return tm }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func TestOAuth2ClientCredentials_caches_token(t *testing.T) {
+	ts := &tokenServer{}
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	t0 := time.Now()
+	setNow(t, t0)
+	src := OAuth2ClientCredentials(OAuth2Config{TokenURL: svr.URL, ClientID: "my-client", ClientSecret: "s3cret", Scopes: []string{"a", "b"}})
+
+	tok1, err := src.Token(context.Background())
+	expect.Error(err).Not().ToHaveOccurred(t)
+	tok2, err := src.Token(context.Background())
+	expect.Error(err).Not().ToHaveOccurred(t)
+
+	expect.String(tok1).ToBe(t, "tok1")
+	expect.String(tok2).ToBe(t, "tok1")
+
+	// shortly before expiry, a new token is obtained
+	setNow(t, t0.Add(time.Hour-10*time.Second))
+	tok3, err := src.Token(context.Background())
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(tok3).ToBe(t, "tok2")
+	expect.Slice(ts.grants).ToBe(t, "client_credentials", "client_credentials")
+}
+
+func TestOAuth2ClientCredentials_refresh_token_grant(t *testing.T) {
+	ts := &tokenServer{refresh: true}
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	src := OAuth2ClientCredentials(OAuth2Config{TokenURL: svr.URL, ClientID: "my-client", ClientSecret: "s3cret", RefreshToken: "stale"})
+
+	tok, err := src.Token(context.Background())
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(tok).ToBe(t, "tok1")
+
+	src.Invalidate("other")
+	src.Invalidate(tok)
+
+	tok, err = src.Token(context.Background())
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(tok).ToBe(t, "tok2")
+
+	// the stale refresh token is rejected, then the issued refresh token is used
+	expect.Slice(ts.grants).ToBe(t, "refresh_token", "client_credentials", "refresh_token")
+}
+
+func TestOAuth2ClientCredentials_concurrent_refresh(t *testing.T) {
+	ts := &tokenServer{}
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	src := OAuth2ClientCredentials(OAuth2Config{TokenURL: svr.URL, ClientID: "my-client", ClientSecret: "s3cret"})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Go(func() {
+			tok, err := src.Token(context.Background())
+			expect.Error(err).Not().ToHaveOccurred(t)
+			expect.String(tok).ToBe(t, "tok1")
+		})
+	}
+	wg.Wait()
+
+	expect.Number(ts.issued.Load()).ToBe(t, int32(1))
+}
+
+func TestOAuth2ClientCredentials_error(t *testing.T) {
+	ts := &tokenServer{reject: true}
+	svr := httptest.NewServer(ts)
+	defer svr.Close()
+
+	src := OAuth2ClientCredentials(OAuth2Config{TokenURL: svr.URL, ClientID: "my-client", ClientSecret: "wrong"})
+
+	_, err := src.Token(context.Background())
+
+	var te *TokenError
+	expect.Bool(errors.As(err, &te)).ToBeTrue(t)
+	expect.Number(te.StatusCode).ToBe(t, http.StatusUnauthorized)
+	expect.String(te.Code).ToBe(t, "invalid_client")
+	expect.String(err.Error()).ToBe(t, "oauth2: 401 invalid_client: unknown client")
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"net/http"
+)
+
+// AuthenticateProxy authenticates the request to a proxy. The authenticator computes its
+// "Authorization" header as usual, which is sent as "Proxy-Authorization" instead (see
+// RFC-9110 section 11.7). So the same authenticators, e.g. [Basic] and [Digest], serve both
+// origin servers and proxies. The request's own "Authorization" header is not altered.
+//
+// Note that the proxy only sees this header for plain "http" requests; for "https" requests,
+// proxy credentials are sent when the tunnel is established - see [http.Transport.ProxyConnectHeader].
+//
+// An error is returned if the authenticator is a [FallibleAuthenticator] that fails.
+func AuthenticateProxy(a Authenticator, req *http.Request) error {
+	if a == nil || a.Type() == None {
+		return nil
+	}
+
+	scratch := *req
+	scratch.Header = make(http.Header)
+	err := AuthenticateRequest(a, &scratch)
+
+	// the authenticator may have buffered the body, e.g. for Digest auth-int
+	req.Body, req.GetBody = scratch.Body, scratch.GetBody
+
+	if v := scratch.Header.Get("Authorization"); v != "" {
+		req.Header.Set("Proxy-Authorization", v)
+	}
+	return err
+}
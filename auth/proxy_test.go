@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestAuthenticateProxy_basic(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.test/", nil)
+	req.Header.Set("Authorization", "Bearer xyz")
+
+	AuthenticateProxy(Basic("user", "password"), req)
+
+	expect.String(req.Header.Get("Proxy-Authorization")).ToBe(t, "Basic dXNlcjpwYXNzd29yZA==")
+	expect.String(req.Header.Get("Authorization")).ToBe(t, "Bearer xyz")
+}
+
+func TestAuthenticateProxy_digest_with_entity(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://example.test/dir/index.html", io.NopCloser(strings.NewReader("hello")))
+
+	d := Digest("Mufasa", "Circle of Life").Challenge([]string{
+		`Digest realm="proxy@example.org", qop="auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"`,
+	})
+	AuthenticateProxy(d, req)
+
+	expect.String(req.Header.Get("Proxy-Authorization")).ToContain(t, `Digest username="Mufasa", realm="proxy@example.org", `)
+	expect.String(req.Header.Get("Proxy-Authorization")).ToContain(t, `uri="/dir/index.html"`)
+	expect.String(req.Header.Get("Authorization")).ToBe(t, "")
+
+	// the entity was buffered so that it can still be sent
+	b, _ := io.ReadAll(req.Body)
+	expect.String(string(b)).ToBe(t, "hello")
+}
+
+func TestAuthenticateProxy_none(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.test/", nil)
+
+	AuthenticateProxy(Deferred("user", "password"), req)
+	AuthenticateProxy(nil, req)
+
+	expect.Map(req.Header).ToHaveLength(t, 0)
+}
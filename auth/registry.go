@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Factory creates an authenticator for the user's credentials.
+type Factory func(user, password string) Authenticator
+
+// ChallengeSupporter is optionally implemented by authenticators that can meet only some of
+// the challenges for their scheme, e.g. Digest challenges using unsupported algorithms.
+type ChallengeSupporter interface {
+	Supports(Challenge) bool
+}
+
+type registration struct {
+	scheme     string
+	preference int
+	factory    Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []registration // in descending order of preference
+)
+
+func init() {
+	Register("Digest", 200, func(user, password string) Authenticator { return Digest(user, password) })
+	Register("Basic", 100, Basic)
+}
+
+// Register adds an authenticator factory for a scheme, replacing any previous registration for
+// the same scheme. When a server offers several schemes, the registered scheme with the highest
+// preference is chosen. By default, Digest (200) is preferred over Basic (100).
+func Register(scheme string, preference int, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry = slices.DeleteFunc(registry, func(r registration) bool {
+		return strings.EqualFold(r.scheme, scheme)
+	})
+
+	registry = append(registry, registration{scheme: scheme, preference: preference, factory: factory})
+
+	slices.SortStableFunc(registry, func(a, b registration) int {
+		return b.preference - a.preference
+	})
+}
+
+// Schemes lists the registered schemes in descending order of preference.
+func Schemes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	schemes := make([]string, len(registry))
+	for i, r := range registry {
+		schemes[i] = r.scheme
+	}
+	return schemes
+}
+
+// Choose creates an authenticator for the most preferred registered scheme that can meet
+// the challenges. It returns nil if there is none.
+func Choose(challenges []Challenge, user, password string) Authenticator {
+	return choose(challenges, func(string, Challenge) (string, string, bool) {
+		return user, password, true
+	})
+}
+
+// ChooseCredentials is like [Choose] except that the user's credentials are obtained from the
+// provider for the protection space of each challenge on the host. Schemes for which there are
+// no credentials are not chosen. It returns nil if there is no suitable scheme.
+func ChooseCredentials(challenges []Challenge, host string, provider CredentialsProvider) Authenticator {
+	return choose(challenges, func(scheme string, c Challenge) (string, string, bool) {
+		return provider.Credentials(ProtectionSpace{Scheme: scheme, Host: host, Realm: c.Params["realm"]})
+	})
+}
+
+func choose(challenges []Challenge, credentials func(scheme string, c Challenge) (user, password string, ok bool)) Authenticator {
+	registryMu.RLock()
+	candidates := slices.Clone(registry)
+	registryMu.RUnlock()
+
+	for _, r := range candidates {
+		var offered []Challenge
+		for _, c := range challenges {
+			if c.Is(r.scheme) {
+				offered = append(offered, c)
+			}
+		}
+
+		if cs, ok := r.factory("", "").(ChallengeSupporter); ok {
+			offered = slices.DeleteFunc(offered, func(c Challenge) bool { return !cs.Supports(c) })
+		}
+
+		// use the credentials for the first challenge that has some, along with any other
+		// challenges (e.g. with different algorithms) that have the same credentials
+		var user, password string
+		found := false
+		offered = slices.DeleteFunc(offered, func(c Challenge) bool {
+			u, p, ok := credentials(r.scheme, c)
+			if ok && !found {
+				user, password, found = u, p, true
+			}
+			return !ok || u != user || p != password
+		})
+		if len(offered) == 0 {
+			continue
+		}
+
+		a := r.factory(user, password)
+		return a.Challenge(ChallengesFor(offered, a.Type()))
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestChoose_prefers_strongest_scheme(t *testing.T) {
+	cs := ParseChallenges([]string{`Basic realm="a", Digest realm="b", nonce="n"`})
+
+	a := Choose(cs, "fred", "pw")
+
+	expect.String(a.Type()).ToBe(t, "Digest")
+	expect.String(a.User()).ToBe(t, "fred")
+}
+
+func TestChoose_skips_unsupported_challenges(t *testing.T) {
+	cs := ParseChallenges([]string{`Digest realm="b", nonce="n", algorithm=SHA-512, Basic realm="a"`})
+
+	expect.String(Choose(cs, "fred", "pw").Type()).ToBe(t, "Basic")
+	expect.Any(Choose(ParseChallenges([]string{`Negotiate`}), "fred", "pw")).ToBeNil(t)
+}
+
+func TestChooseCredentials_by_realm(t *testing.T) {
+	cs := ParseChallenges([]string{`Digest realm="b", nonce="n", Basic realm="a"`})
+	provider := CredentialsMap{
+		{Host: "example.com", Realm: "a"}: {User: "alice", Password: "pw1"},
+		{Host: "other.com"}:               {User: "bob", Password: "pw2"},
+	}
+
+	// there are no credentials for realm "b", so Basic is chosen
+	a := ChooseCredentials(cs, "example.com:8443", provider)
+	expect.String(a.Type()).ToBe(t, "Basic")
+	expect.String(a.User()).ToBe(t, "alice")
+
+	expect.Any(ChooseCredentials(cs, "nowhere.com", provider)).ToBeNil(t)
+}
+
+func TestRegister(t *testing.T) {
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = registry[:0]
+		registryMu.Unlock()
+		Register("Digest", 200, func(user, password string) Authenticator { return Digest(user, password) })
+		Register("Basic", 100, Basic)
+	})
+
+	Register("basic", 300, Basic)
+	expect.Slice(Schemes()).ToBe(t, "basic", "Digest")
+
+	cs := ParseChallenges([]string{`Basic realm="a", Digest realm="b", nonce="n"`})
+	expect.String(Choose(cs, "fred", "pw").Type()).ToBe(t, "Basic")
+}
+
+func TestDeferred_Challenge(t *testing.T) {
+	a := Deferred("fred", "pw").Challenge([]string{`Basic realm="a", Digest realm="b", nonce="n"`})
+	expect.String(a.Type()).ToBe(t, "Digest")
+
+	a = Deferred("", "").Challenge([]string{`Basic realm="a"`})
+	expect.String(a.Type()).ToBe(t, None)
+}
@@ -1,370 +1,310 @@
 package auth
 
-// SAML provides authentication using Security Authentication Markup Language.
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rickb777/httpclient"
+)
+
+var _ FallibleAuthenticator = &SAMLAuth{}
+
+// SAMLConfig configures [SAML] authentication.
+type SAMLConfig struct {
+	User     string
+	Password string
+
+	// SiteURL is the relying party, e.g. "https://contoso.sharepoint.com/sites/dev".
+	SiteURL string
+
+	// STSURL is the WS-Trust security token service endpoint,
+	// e.g. "https://login.microsoftonline.com/extSTS.srf".
+	STSURL string
+
+	// ADFSURL, if not blank, is the AD FS "usernamemixed" endpoint used for federated users,
+	// e.g. "https://adfs.contoso.com/adfs/services/trust/13/usernamemixed". The user is then
+	// authenticated by AD FS and the resulting assertion is exchanged at STSURL.
+	ADFSURL string
+
+	// RelyingParty identifies the STS to AD FS. Zero means "urn:federation:MicrosoftOnline".
+	RelyingParty string
+
+	// SignInURL accepts the binary security token in exchange for session cookies. Zero means
+	// "/_forms/default.aspx?wa=wsignin1.0" on the SiteURL host.
+	SignInURL string
+
+	// Cookies names the session cookies. Nil means "FedAuth" and "rtFa".
+	Cookies []string
+
+	// ExpiryDelta is how long before the token expires the session is renewed. Zero means
+	// 60 seconds.
+	ExpiryDelta time.Duration
+
+	// HttpClient is used for sign-in requests. If nil, httpclient.DefaultClient is used.
+	HttpClient httpclient.HttpClient
+}
+
+// SAML provides authentication using Security Authentication Markup Language tokens
+// obtained via WS-Trust / WS-Federation, as used by SharePoint Online and AD FS.
 // See https://tools.ietf.org/html/rfc7522
-// TODO finish and test this
-//func SAML(user, pw, siteURL string, hc httpclient.HttpClient) Authenticator {
-//	return &samlAuth{
-//		user:    user,
-//		pw:      pw,
-//		siteURL: siteURL,
-//		hc:      hc,
-//	}
-//}
-
-//type samlAuth struct {
-//	user    string
-//	pw      string
-//	siteURL string
-//	hc      httpclient.HttpClient
-//}
-
-// Type identifies the Basic authenticator.
-//func (sa *samlAuth) Type() string {
-//	return "SAML"
-//}
-
-// User holds the BasicAuth username.
-//func (sa *samlAuth) User() string {
-//	return sa.user
-//}
-
-// pw holds the BasicAuth password.
-//func (sa *samlAuth) Password() string {
-//	return sa.pw
-//}
-
-//func (sa *samlAuth) Challenge([]string) Authenticator {
-//	return sa
-//}
-
-// Authenticate the current request.
-//func (sa *samlAuth) Authenticate(req *http.Request) {
-//	authCookie, _, err := sa.getAuth()
-//	if err == nil {
-//		req.Header.Set("Cookie", authCookie)
-//	}
-//}
-
-//func (sa *samlAuth) post(url, contentType string, body io.Reader) (resp *http.Response, err error) {
-//	req, err := http.NewRequest("POST", url, body)
-//	if err != nil {
-//		return nil, err
-//	}
-//	req.Header.Set("Content-Type", contentType)
-//	return sa.hc.Do(req)
-//}
-
-//func (sa *samlAuth) getAuth() (string, int64, error) {
-//	if sa.hc == nil {
-//		sa.hc = http.DefaultClient
-//	}
-//
-//	parsedURL, err := url.Parse(sa.siteURL)
-//	if err != nil {
-//		return "", 0, err
-//	}
-//
-//	cacheKey := parsedURL.Host + "@saml@" + sa.user + "@" + sa.pw
-//	if authToken, exp, found := storage.GetWithExpiration(cacheKey); found {
-//		return authToken.(string), exp.Unix(), nil
-//	}
-//
-//	authCookie, notAfter, err := getSecurityToken(sa)
-//	if err != nil {
-//		return "", 0, err
-//	}
-//
-//	notAfterTime, _ := time.Parse(time.RFC3339, notAfter)
-//	expiry := time.Until(notAfterTime) - 60*time.Second
-//	exp := time.Now().Add(expiry).Unix()
-//
-//	storage.Set(cacheKey, authCookie, expiry)
-//
-//	return authCookie, exp, nil
-//	return "", 0, nil
-//}
-
-//func getSecurityToken(sa *samlAuth) (string, string, error) {
-//	if sa.hc == nil {
-//		sa.hc = http.DefaultClient
-//	}
-//
-//	const endpoint = "https://login.microsoftonline.com/GetUserRealm.srf"
-//
-//	params := url.Values{}
-//	params.Set("login", sa.user)
-//
-//	sa.hc.(*http.Client).CheckRedirect = doNotCheckRedirect
-//
-//	resp, err := sa.post(endpoint, "application/x-www-form-urlencoded", strings.NewReader(params.Encode()))
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	defer func() {
-//		if resp != nil && resp.Body != nil {
-//			_ = resp.Body.Close()
-//		}
-//	}()
-//
-//	data, err := ioutil.ReadAll(resp.Body)
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	type userReadlmResponse struct {
-//		NameSpaceType       string `json:"NameSpaceType"`
-//		DomainName          string `json:"DomainName"`
-//		FederationBrandName string `json:"FederationBrandName"`
-//		CloudInstanceName   string `json:"CloudInstanceName"`
-//		State               int    `json:"State"`
-//		UserState           int    `json:"UserState"`
-//		Login               string `json:"Login"`
-//		AuthURL             string `json:"AuthURL"`
-//	}
-//
-//	userRealm := &userReadlmResponse{}
-//	if err = json.Unmarshal(data, &userRealm); err != nil {
-//		return "", "", err
-//	}
 //
-//	// fmt.Printf("Results: %v\n", userRealm.NameSpaceType)
-//
-//	if userRealm.NameSpaceType == "" {
-//		return "", "", errors.New("unable to define namespace type for Online authentiation")
-//	}
-//
-//	if userRealm.NameSpaceType == "Managed" {
-//		return getSecurityTokenWithOnline(sa)
-//	}
-//
-//	if userRealm.NameSpaceType == "Federated" {
-//		return getSecurityTokenWithAdfs(userRealm.AuthURL, sa)
-//	}
-//
-//	return "", "", fmt.Errorf("unable to resolve namespace authentiation type. Type received: %s", userRealm.NameSpaceType)
-//}
+// The user's credentials are sent to the security token service (STS), which issues a binary
+// security token. This is posted to the relying party's sign-in URL in exchange for session
+// cookies, which are then sent with each request. The cookies are kept until the token
+// expires, then renewed. If sign-in fails, TryAuthenticate fails, so the request is not sent.
+func SAML(cfg SAMLConfig) *SAMLAuth {
+	if cfg.HttpClient == nil {
+		cfg.HttpClient = httpclient.DefaultClient
+	}
+	if cfg.RelyingParty == "" {
+		cfg.RelyingParty = "urn:federation:MicrosoftOnline"
+	}
+	if cfg.Cookies == nil {
+		cfg.Cookies = []string{"FedAuth", "rtFa"}
+	}
+	if cfg.ExpiryDelta <= 0 {
+		cfg.ExpiryDelta = 60 * time.Second
+	}
+	return &SAMLAuth{cfg: cfg}
+}
 
-//func getSecurityTokenWithOnline(sa *samlAuth) (string, string, error) {
-//	if sa.hc == nil {
-//		sa.hc = http.DefaultClient
-//	}
-//
-//	parsedURL, err := url.Parse(sa.siteURL)
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	formsEndpoint := fmt.Sprintf("%s://%s/_forms/default.aspx?wa=wsignin1.0", parsedURL.Scheme, parsedURL.Host)
-//	samlBody, err := onlineSamlWsfedTemplate(formsEndpoint, sa.user, sa.pw)
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	stsEndpoint := "https://login.microsoftonline.com/extSTS.srf" // TODO: add mapping for diff SPOs
-//
-//	req, err := http.NewRequest("POST", stsEndpoint, bytes.NewBuffer([]byte(samlBody)))
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	req.Header.Set("Content-Type", "application/soap+xml;charset=utf-8")
-//
-//	sa.hc.(*http.Client).CheckRedirect = doNotCheckRedirect
-//
-//	resp, err := sa.hc.Do(req)
-//	if err != nil {
-//		return "", "", err
-//	}
-//	defer func() {
-//		if resp != nil && resp.Body != nil {
-//			_ = resp.Body.Close()
-//		}
-//	}()
-//
-//	xmlResponse, err := ioutil.ReadAll(resp.Body)
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	type samlAssertion struct {
-//		Fault    string `xml:"Body>Fault>Reason>Text"`
-//		Response struct {
-//			BinaryToken string `xml:"RequestedSecurityToken>BinarySecurityToken"`
-//			Lifetime    struct {
-//				Created string `xml:"Created"`
-//				Expires string `xml:"Expires"`
-//			} `xml:"Lifetime"`
-//		} `xml:"Body>RequestSecurityTokenResponse"`
-//	}
-//	result := &samlAssertion{}
-//	if err := xml.Unmarshal(xmlResponse, &result); err != nil {
-//		return "", "", err
-//	}
-//
-//	resp, err = sa.post(formsEndpoint, "application/x-www-form-urlencoded", strings.NewReader(result.Response.BinaryToken))
-//	if err != nil {
-//		return "", "", err
-//	}
-//	defer func() {
-//		if resp != nil && resp.Body != nil {
-//			_ = resp.Body.Close()
-//		}
-//	}()
-//
-//	if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
-//		return "", "", err
-//	}
-//
-//	// cookie := resp.Header.Get("Set-Cookie") // TODO: parse FedAuth and rtFa cookies only (?)
-//	// fmt.Printf("Cookie: %s\n", cookie)
-//	// fmt.Printf("Resp2, %v\n", resp.StatusCode)
-//
-//	var authCookie string
-//	for _, coo := range resp.Cookies() {
-//		if coo.Name == "rtFa" || coo.Name == "FedAuth" {
-//			authCookie += coo.String() + "; "
-//		}
-//	}
-//
-//	return authCookie, result.Response.Lifetime.Expires, nil
-//}
+// SAMLAuth structure holds our credentials and the current session.
+type SAMLAuth struct {
+	cfg SAMLConfig
 
-//func getSecurityTokenWithAdfs(adfsURL string, sa *samlAuth) (string, string, error) {
-//	if sa.hc == nil {
-//		sa.hc = http.DefaultClient
-//	}
-//
-//	parsedAdfsURL, err := url.Parse(adfsURL)
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	usernameMixedURL := fmt.Sprintf("%s://%s/adfs/services/trust/13/usernamemixed", parsedAdfsURL.Scheme, parsedAdfsURL.Host)
-//	samlBody, err := adfsSamlWsfedTemplate(usernameMixedURL, sa.user, sa.pw, "urn:federation:MicrosoftOnline")
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	sa.hc.(*http.Client).CheckRedirect = doNotCheckRedirect
-//	resp, err := sa.post(usernameMixedURL, "application/soap+xml;charset=utf-8", bytes.NewBuffer([]byte(samlBody)))
-//	if err != nil {
-//		return "", "", err
-//	}
-//	defer func() {
-//		if resp != nil && resp.Body != nil {
-//			_ = resp.Body.Close()
-//		}
-//	}()
-//
-//	res, err := ioutil.ReadAll(resp.Body)
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	type samlAssertion struct {
-//		Response struct {
-//			Fault string `xml:"Fault>Reason>Text"`
-//			Token struct {
-//				Inner      []byte `xml:",innerxml"`
-//				Conditions struct {
-//					NotBefore    string `xml:"NotBefore,attr"`
-//					NotOnOrAfter string `xml:"NotOnOrAfter,attr"`
-//				} `xml:"Assertion>Conditions"`
-//			} `xml:"RequestSecurityTokenResponseCollection>RequestSecurityTokenResponse>RequestedSecurityToken"`
-//		} `xml:"Body"`
-//	}
-//
-//	result := &samlAssertion{}
-//	if err := xml.Unmarshal(res, &result); err != nil {
-//		return "", "", err
-//	}
-//
-//	if result.Response.Fault != "" {
-//		return "", "", errors.New(result.Response.Fault)
-//	}
-//
-//	parsedURL, err := url.Parse(sa.siteURL)
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	rootSite := fmt.Sprintf("%s://%s", parsedURL.Scheme, parsedURL.Host)
-//	tokenRequest, err := onlineSamlWsfedAdfsTemplate(rootSite, string(result.Response.Token.Inner))
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	stsEndpoint := "https://login.microsoftonline.com/extSTS.srf" // TODO: mapping
-//
-//	resp, err = sa.post(stsEndpoint, "application/soap+xml;charset=utf-8", bytes.NewBuffer([]byte(tokenRequest)))
-//	if err != nil {
-//		return "", "", err
-//	}
-//	defer func() {
-//		if resp != nil && resp.Body != nil {
-//			_ = resp.Body.Close()
-//		}
-//	}()
-//
-//	xmlResponse, err := ioutil.ReadAll(resp.Body)
-//	if err != nil {
-//		return "", "", err
-//	}
-//
-//	type tokenAssertion struct {
-//		Fault    string `xml:"Body>Fault>Reason>Text"`
-//		Response struct {
-//			BinaryToken string `xml:"RequestedSecurityToken>BinarySecurityToken"`
-//			Lifetime    struct {
-//				Created string `xml:"Created"`
-//				Expires string `xml:"Expires"`
-//			} `xml:"Lifetime"`
-//		} `xml:"Body>RequestSecurityTokenResponse"`
-//	}
-//
-//	tokenResult := &tokenAssertion{}
-//	if err := xml.Unmarshal(xmlResponse, &tokenResult); err != nil {
-//		return "", "", err
-//	}
-//
-//	if tokenResult.Response.BinaryToken == "" {
-//		return "", "", errors.New("can't extract binary token")
-//	}
-//
-//	sa.hc.(*http.Client).CheckRedirect = doNotCheckRedirect
-//
-//	formsEndpoint := fmt.Sprintf("%s://%s/_forms/default.aspx?wa=wsignin1.0", parsedURL.Scheme, parsedURL.Host)
-//	resp, err = sa.post(formsEndpoint, "application/x-www-form-urlencoded", strings.NewReader(tokenResult.Response.BinaryToken))
-//	if err != nil {
-//		return "", "", err
-//	}
-//	defer func() {
-//		if resp != nil && resp.Body != nil {
-//			_ = resp.Body.Close()
-//		}
-//	}()
-//
-//	if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
-//		return "", "", err
-//	}
-//
-//	var authCookie string
-//	for _, coo := range resp.Cookies() {
-//		if coo.Name == "rtFa" || coo.Name == "FedAuth" {
-//			authCookie += coo.String() + "; "
-//		}
-//	}
-//
-//	return authCookie, tokenResult.Response.Lifetime.Expires, nil
-//}
+	mu       sync.Mutex // guards the following
+	cookies  []*http.Cookie
+	notAfter time.Time // zero if the token lifetime is unknown
+}
 
-// doNotCheckRedirect *http.Client CheckRedirect callback to ignore redirects
-//func doNotCheckRedirect(_ *http.Request, _ []*http.Request) error {
-//	return http.ErrUseLastResponse
-//}
+// Type identifies the SAML authenticator.
+func (sa *SAMLAuth) Type() string {
+	return "SAML"
+}
+
+// User holds the SAML username.
+func (sa *SAMLAuth) User() string {
+	return sa.cfg.User
+}
+
+// Password holds the SAML password.
+func (sa *SAMLAuth) Password() string {
+	return sa.cfg.Password
+}
+
+// Challenge discards the session cookies, which have been rejected, so that the next request
+// will sign in again.
+func (sa *SAMLAuth) Challenge([]string) Authenticator {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	sa.cookies = nil
+	return sa
+}
+
+// Authenticate the current request. See [SAMLAuth.TryAuthenticate].
+func (sa *SAMLAuth) Authenticate(req *http.Request) {
+	_ = sa.TryAuthenticate(req)
+}
+
+// TryAuthenticate authenticates the current request, signing in first if necessary. It returns
+// an error if sign-in fails, in which case no cookies are added.
+func (sa *SAMLAuth) TryAuthenticate(req *http.Request) error {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	if sa.cookies == nil || (!sa.notAfter.IsZero() && !now().Before(sa.notAfter.Add(-sa.cfg.ExpiryDelta))) {
+		var err error
+		sa.cookies, sa.notAfter, err = sa.signIn(req.Context())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range sa.cookies {
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// signIn obtains a security token and exchanges it for session cookies; sa.mu must be held.
+func (sa *SAMLAuth) signIn(ctx context.Context) ([]*http.Cookie, time.Time, error) {
+	site, err := url.Parse(sa.cfg.SiteURL)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	signInURL := sa.cfg.SignInURL
+	if signInURL == "" {
+		signInURL = fmt.Sprintf("%s://%s/_forms/default.aspx?wa=wsignin1.0", site.Scheme, site.Host)
+	}
+
+	var tokenRequest string
+	if sa.cfg.ADFSURL == "" {
+		tokenRequest, err = onlineSamlWsfedTemplate(sa.cfg.STSURL, signInURL, sa.cfg.User, sa.cfg.Password)
+	} else {
+		var assertion string
+		assertion, err = sa.adfsAssertion(ctx)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		rootSite := fmt.Sprintf("%s://%s", site.Scheme, site.Host)
+		tokenRequest, err = onlineSamlWsfedAdfsTemplate(sa.cfg.STSURL, rootSite, assertion)
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	binaryToken, expires, err := sa.securityToken(ctx, tokenRequest)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
 
-//var (
-//	storage = cache.New(5*time.Minute, 10*time.Minute)
-//)
+	cookies, err := sa.exchange(ctx, signInURL, binaryToken)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	notAfter, _ := time.Parse(time.RFC3339, expires)
+	return cookies, notAfter, nil
+}
+
+// adfsAssertion authenticates the user with AD FS, giving a SAML assertion.
+func (sa *SAMLAuth) adfsAssertion(ctx context.Context) (string, error) {
+	samlBody, err := adfsSamlWsfedTemplate(sa.cfg.ADFSURL, sa.cfg.User, sa.cfg.Password, sa.cfg.RelyingParty)
+	if err != nil {
+		return "", err
+	}
+
+	data, res, err := sa.post(ctx, sa.cfg.HttpClient, sa.cfg.ADFSURL, "application/soap+xml;charset=utf-8", samlBody)
+	if err != nil {
+		return "", err
+	}
+
+	type samlAssertion struct {
+		Response struct {
+			Fault string `xml:"Fault>Reason>Text"`
+			Token struct {
+				Inner []byte `xml:",innerxml"`
+			} `xml:"RequestSecurityTokenResponseCollection>RequestSecurityTokenResponse>RequestedSecurityToken"`
+		} `xml:"Body"`
+	}
+
+	result := &samlAssertion{}
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("saml: %s: %w", res.Status, err)
+	}
+
+	if result.Response.Fault != "" {
+		return "", fmt.Errorf("saml: %s", result.Response.Fault)
+	}
+
+	if len(result.Response.Token.Inner) == 0 {
+		return "", fmt.Errorf("saml: %s: no assertion in AD FS response", res.Status)
+	}
+
+	return string(result.Response.Token.Inner), nil
+}
+
+// securityToken requests the binary security token from the STS.
+func (sa *SAMLAuth) securityToken(ctx context.Context, tokenRequest string) (binaryToken, expires string, err error) {
+	data, res, err := sa.post(ctx, sa.cfg.HttpClient, sa.cfg.STSURL, "application/soap+xml;charset=utf-8", tokenRequest)
+	if err != nil {
+		return "", "", err
+	}
+
+	type tokenAssertion struct {
+		Fault    string `xml:"Body>Fault>Reason>Text"`
+		Response struct {
+			BinaryToken string `xml:"RequestedSecurityToken>BinarySecurityToken"`
+			Lifetime    struct {
+				Created string `xml:"Created"`
+				Expires string `xml:"Expires"`
+			} `xml:"Lifetime"`
+		} `xml:"Body>RequestSecurityTokenResponse"`
+	}
+
+	result := &tokenAssertion{}
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return "", "", fmt.Errorf("saml: %s: %w", res.Status, err)
+	}
+
+	if result.Fault != "" {
+		return "", "", fmt.Errorf("saml: %s", result.Fault)
+	}
+
+	if result.Response.BinaryToken == "" {
+		return "", "", fmt.Errorf("saml: %s: can't extract binary token", res.Status)
+	}
+
+	return result.Response.BinaryToken, result.Response.Lifetime.Expires, nil
+}
+
+// exchange posts the binary token to the relying party, which sets the session cookies.
+// Redirects are not followed because the cookies are set on the sign-in response.
+func (sa *SAMLAuth) exchange(ctx context.Context, signInURL, binaryToken string) ([]*http.Cookie, error) {
+	_, res, err := sa.post(ctx, doNotFollowRedirects(sa.cfg.HttpClient), signInURL, "application/x-www-form-urlencoded", binaryToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("saml: sign-in failed: %s", res.Status)
+	}
+
+	var cookies []*http.Cookie
+	for _, coo := range res.Cookies() {
+		for _, name := range sa.cfg.Cookies {
+			if coo.Name == name {
+				cookies = append(cookies, coo)
+			}
+		}
+	}
+
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf("saml: sign-in response has no session cookies")
+	}
+
+	return cookies, nil
+}
+
+func (sa *SAMLAuth) post(ctx context.Context, hc httpclient.HttpClient, url, contentType, body string) ([]byte, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	return data, res, err
+}
+
+// doNotFollowRedirects copies the client, if it is a *http.Client, such that redirects are
+// not followed.
+func doNotFollowRedirects(hc httpclient.HttpClient) httpclient.HttpClient {
+	if c, ok := hc.(*http.Client); ok {
+		noRedirects := *c
+		noRedirects.CheckRedirect = doNotCheckRedirect
+		return &noRedirects
+	}
+	return hc
+}
+
+// doNotCheckRedirect *http.Client CheckRedirect callback to ignore redirects
+func doNotCheckRedirect(_ *http.Request, _ []*http.Request) error {
+	return http.ErrUseLastResponse
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+// fakeSAML acts as the security token service, AD FS and the relying party.
+type fakeSAML struct {
+	*httptest.Server
+	expires       time.Time
+	issued        atomic.Int32
+	lastTo        string
+	lastAppliesTo string
+}
+
+// rst is the parts of a token request that the fake STS checks.
+type rst struct {
+	To        string `xml:"Header>To"`
+	Username  string `xml:"Header>Security>UsernameToken>Username"`
+	Password  string `xml:"Header>Security>UsernameToken>Password"`
+	Assertion struct {
+		ID string `xml:"AssertionID,attr"`
+	} `xml:"Header>Security>Assertion"`
+	AppliesTo string `xml:"Body>RequestSecurityToken>AppliesTo>EndpointReference>Address"`
+}
+
+func newFakeSAML(t *testing.T) *fakeSAML {
+	fs := &fakeSAML{expires: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /extSTS.srf", func(w http.ResponseWriter, req *http.Request) {
+		var r rst
+		body, _ := io.ReadAll(req.Body)
+		expect.Error(xml.Unmarshal(body, &r)).Not().ToHaveOccurred(t)
+		fs.lastTo, fs.lastAppliesTo = r.To, r.AppliesTo
+
+		w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+		if r.Assertion.ID != "_adfs1" && (r.Username != "alice@example.com" || r.Password != "p&ss<word>") {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `<S:Envelope xmlns:S="http://www.w3.org/2003/05/soap-envelope"><S:Body><S:Fault><S:Code><S:Value>S:Sender</S:Value></S:Code>`+
+				`<S:Reason><S:Text xml:lang="en-US">Authentication Failure</S:Text></S:Reason></S:Fault></S:Body></S:Envelope>`)
+			return
+		}
+
+		n := fs.issued.Add(1)
+		fmt.Fprintf(w, `<S:Envelope xmlns:S="http://www.w3.org/2003/05/soap-envelope" xmlns:wst="http://schemas.xmlsoap.org/ws/2005/02/trust" xmlns:wsse="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd" xmlns:wsu="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd">`+
+			`<S:Body><wst:RequestSecurityTokenResponse>`+
+			`<wst:Lifetime><wsu:Created>2030-01-01T04:00:00Z</wsu:Created><wsu:Expires>%s</wsu:Expires></wst:Lifetime>`+
+			`<wst:RequestedSecurityToken><wsse:BinarySecurityToken Id="Compact0">t=token%d</wsse:BinarySecurityToken></wst:RequestedSecurityToken>`+
+			`</wst:RequestSecurityTokenResponse></S:Body></S:Envelope>`, fs.expires.Format(time.RFC3339), n)
+	})
+
+	mux.HandleFunc("POST /adfs/services/trust/13/usernamemixed", func(w http.ResponseWriter, req *http.Request) {
+		var r rst
+		body, _ := io.ReadAll(req.Body)
+		expect.Error(xml.Unmarshal(body, &r)).Not().ToHaveOccurred(t)
+		expect.String(r.AppliesTo).ToBe(t, "urn:federation:MicrosoftOnline")
+
+		w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+		if r.Username != "bob@corp.example.com" || r.Password != "secret" {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body><s:Fault>`+
+				`<s:Reason><s:Text xml:lang="en-US">ID3242: The security token could not be authenticated or authorized.</s:Text></s:Reason></s:Fault></s:Body></s:Envelope>`)
+			return
+		}
+
+		fmt.Fprint(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body>`+
+			`<trust:RequestSecurityTokenResponseCollection xmlns:trust="http://docs.oasis-open.org/ws-sx/ws-trust/200512"><trust:RequestSecurityTokenResponse>`+
+			`<trust:RequestedSecurityToken><saml:Assertion MajorVersion="1" MinorVersion="1" AssertionID="_adfs1" xmlns:saml="urn:oasis:names:tc:SAML:1.0:assertion">`+
+			`<saml:Conditions NotBefore="2030-01-01T04:00:00Z" NotOnOrAfter="2030-01-01T05:00:00Z"/></saml:Assertion></trust:RequestedSecurityToken>`+
+			`</trust:RequestSecurityTokenResponse></trust:RequestSecurityTokenResponseCollection></s:Body></s:Envelope>`)
+	})
+
+	mux.HandleFunc("POST /_forms/default.aspx", func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if !strings.HasPrefix(string(body), "t=token") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "FedAuth", Value: "fed-" + string(body[7:]), Path: "/", HttpOnly: true})
+		http.SetCookie(w, &http.Cookie{Name: "rtFa", Value: "rt-" + string(body[7:]), Path: "/", HttpOnly: true})
+		http.SetCookie(w, &http.Cookie{Name: "other", Value: "x"})
+		http.Redirect(w, req, "/", http.StatusFound)
+	})
+
+	mux.HandleFunc("GET /api", func(w http.ResponseWriter, req *http.Request) {
+		if c, err := req.Cookie("FedAuth"); err != nil || !strings.HasPrefix(c.Value, "fed-") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
+
+	fs.Server = httptest.NewServer(mux)
+	t.Cleanup(fs.Close)
+	return fs
+}
+
+func TestSAML_online(t *testing.T) {
+	fs := newFakeSAML(t)
+	setNow(t, time.Date(2030, 1, 1, 4, 0, 0, 0, time.UTC))
+
+	sa := SAML(SAMLConfig{
+		User:     "alice@example.com",
+		Password: "p&ss<word>",
+		SiteURL:  fs.URL + "/sites/dev",
+		STSURL:   fs.URL + "/extSTS.srf",
+	})
+
+	req := httptest.NewRequest("GET", fs.URL+"/api", nil)
+	req.RequestURI = ""
+	err := sa.TryAuthenticate(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(req.Header.Get("Cookie")).ToBe(t, "FedAuth=fed-1; rtFa=rt-1")
+	expect.String(fs.lastTo).ToBe(t, fs.URL+"/extSTS.srf")
+	expect.String(fs.lastAppliesTo).ToBe(t, fs.URL+"/_forms/default.aspx?wa=wsignin1.0")
+
+	res, err := http.DefaultClient.Do(req)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusOK)
+
+	// the cookies are cached until shortly before the token expires
+	setNow(t, fs.expires.Add(-2*time.Minute))
+	req = httptest.NewRequest("GET", fs.URL+"/api", nil)
+	sa.Authenticate(req)
+	expect.String(req.Header.Get("Cookie")).ToBe(t, "FedAuth=fed-1; rtFa=rt-1")
+
+	setNow(t, fs.expires.Add(-time.Minute))
+	req = httptest.NewRequest("GET", fs.URL+"/api", nil)
+	sa.Authenticate(req)
+	expect.String(req.Header.Get("Cookie")).ToBe(t, "FedAuth=fed-2; rtFa=rt-2")
+	expect.Number(fs.issued.Load()).ToBe(t, int32(2))
+}
+
+func TestSAML_challenge_renews_session(t *testing.T) {
+	fs := newFakeSAML(t)
+	setNow(t, time.Date(2030, 1, 1, 4, 0, 0, 0, time.UTC))
+
+	sa := SAML(SAMLConfig{User: "alice@example.com", Password: "p&ss<word>", SiteURL: fs.URL, STSURL: fs.URL + "/extSTS.srf"})
+
+	sa.Authenticate(httptest.NewRequest("GET", "/api", nil))
+	expect.Any(sa.Challenge(nil)).ToBe(t, Authenticator(sa))
+
+	req := httptest.NewRequest("GET", "/api", nil)
+	sa.Authenticate(req)
+	expect.String(req.Header.Get("Cookie")).ToBe(t, "FedAuth=fed-2; rtFa=rt-2")
+}
+
+func TestSAML_adfs(t *testing.T) {
+	fs := newFakeSAML(t)
+	setNow(t, time.Date(2030, 1, 1, 4, 0, 0, 0, time.UTC))
+
+	sa := SAML(SAMLConfig{
+		User:     "bob@corp.example.com",
+		Password: "secret",
+		SiteURL:  fs.URL + "/sites/dev",
+		STSURL:   fs.URL + "/extSTS.srf",
+		ADFSURL:  fs.URL + "/adfs/services/trust/13/usernamemixed",
+	})
+
+	req := httptest.NewRequest("GET", "/api", nil)
+	err := sa.TryAuthenticate(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(req.Header.Get("Cookie")).ToBe(t, "FedAuth=fed-1; rtFa=rt-1")
+	expect.String(fs.lastAppliesTo).ToBe(t, fs.URL)
+}
+
+func TestSAML_faults(t *testing.T) {
+	fs := newFakeSAML(t)
+
+	sa := SAML(SAMLConfig{User: "alice@example.com", Password: "wrong", SiteURL: fs.URL, STSURL: fs.URL + "/extSTS.srf"})
+	req := httptest.NewRequest("GET", "/api", nil)
+	err := sa.TryAuthenticate(req)
+
+	expect.Error(err).ToContain(t, "saml: Authentication Failure")
+	expect.String(req.Header.Get("Cookie")).ToBe(t, "")
+
+	sa = SAML(SAMLConfig{User: "bob@corp.example.com", Password: "wrong", SiteURL: fs.URL, STSURL: fs.URL + "/extSTS.srf",
+		ADFSURL: fs.URL + "/adfs/services/trust/13/usernamemixed"})
+	err = sa.TryAuthenticate(httptest.NewRequest("GET", "/api", nil))
+
+	expect.Error(err).ToContain(t, "saml: ID3242")
+
+	sa = SAML(SAMLConfig{User: "alice@example.com", Password: "p&ss<word>", SiteURL: fs.URL, STSURL: fs.URL + "/extSTS.srf",
+		SignInURL: fs.URL + "/nowhere"})
+	err = sa.TryAuthenticate(httptest.NewRequest("GET", "/api", nil))
+
+	expect.Error(err).ToContain(t, "saml: sign-in failed: 404")
+}
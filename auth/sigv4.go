@@ -0,0 +1,392 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bodypkg "github.com/rickb777/httpclient/body"
+)
+
+var _ FallibleAuthenticator = &SigV4Auth{}
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	amzDateFormat    = "20060102T150405Z"
+)
+
+// emptySHA256 is the hash of an empty payload.
+var emptySHA256 = hexSHA256(nil)
+
+// SigV4Config holds the credentials and scope for AWS Signature Version 4.
+type SigV4Config struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // optional, for temporary credentials
+
+	Region  string // e.g. "us-east-1"
+	Service string // e.g. "s3"
+
+	// UnsignedPayload sends "UNSIGNED-PAYLOAD" instead of hashing the request entity.
+	// This is allowed by S3 over HTTPS.
+	UnsignedPayload bool
+
+	// ChunkSize, if positive, causes request entities of known length to be sent using
+	// "aws-chunked" encoding in which each chunk is signed (STREAMING-AWS4-HMAC-SHA256-PAYLOAD),
+	// so the entity need not be read twice. S3 requires chunks of at least 8KiB, apart from the last.
+	ChunkSize int
+}
+
+// SigV4 signs requests using AWS Signature Version 4, as used by AWS and S3-compatible
+// object stores such as MinIO.
+// See https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv.html
+//
+// Request entities buffered by the rest client are hashed without copying. Other entities
+// are read using http.Request.GetBody, or are buffered if that is not available. If the entity
+// cannot be read, TryAuthenticate fails, so the request is not sent.
+func SigV4(cfg SigV4Config) *SigV4Auth {
+	return &SigV4Auth{cfg: cfg}
+}
+
+// SigV4Auth structure holds our credentials.
+type SigV4Auth struct {
+	cfg SigV4Config
+}
+
+// Type identifies the SigV4 authenticator.
+func (s *SigV4Auth) Type() string {
+	return sigV4Algorithm
+}
+
+// User holds the access key ID.
+func (s *SigV4Auth) User() string {
+	return s.cfg.AccessKeyID
+}
+
+// Password holds the secret access key.
+func (s *SigV4Auth) Password() string {
+	return s.cfg.SecretAccessKey
+}
+
+// Challenge has no effect because AWS does not use authentication challenges.
+func (s *SigV4Auth) Challenge([]string) Authenticator {
+	return s
+}
+
+// Authenticate signs the current request. See [SigV4Auth.TryAuthenticate].
+func (s *SigV4Auth) Authenticate(req *http.Request) {
+	_ = s.TryAuthenticate(req)
+}
+
+// TryAuthenticate signs the current request, returning an error if the request entity cannot
+// be read for hashing, in which case no headers are set.
+func (s *SigV4Auth) TryAuthenticate(req *http.Request) error {
+	streaming := s.cfg.ChunkSize > 0 && req.ContentLength > 0 && req.Body != nil && req.Body != http.NoBody
+	payloadHash, err := s.payloadHash(req, streaming)
+	if err != nil {
+		return err
+	}
+
+	t := now().UTC()
+	amzDate := t.Format(amzDateFormat)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	if streaming {
+		req.Header.Set("Content-Encoding", appendToken(req.Header.Get("Content-Encoding"), "aws-chunked"))
+		req.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(req.ContentLength, 10))
+		req.ContentLength = chunkedLength(req.ContentLength, int64(s.cfg.ChunkSize))
+		req.Header.Set("Content-Length", strconv.FormatInt(req.ContentLength, 10))
+	}
+
+	if s.cfg.Service == "s3" || payloadHash == unsignedPayload || streaming {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := s.canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	key := s.signingKey(t)
+	signature := hmacHex(key, s.stringToSign(t, canonicalRequest))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.cfg.AccessKeyID, s.scope(t), signedHeaders, signature))
+
+	if streaming {
+		s.streamChunks(req, t, key, signature)
+	}
+	return nil
+}
+
+// Presign creates a URL that allows the request to be made by anyone, without credentials,
+// until it expires. Only the method, URL and Host are signed; the payload is unsigned. The
+// maximum expiry allowed by AWS is 7 days.
+func (s *SigV4Auth) Presign(req *http.Request, expires time.Duration) *url.URL {
+	t := now().UTC()
+
+	u := *req.URL
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.cfg.AccessKeyID+"/"+s.scope(t))
+	query.Set("X-Amz-Date", t.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if s.cfg.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(&u),
+		canonicalQuery(query),
+		"host:" + hostOf(req) + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	signature := hmacHex(s.signingKey(t), s.stringToSign(t, canonicalRequest))
+
+	u.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+	return &u
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func (s *SigV4Auth) payloadHash(req *http.Request, streaming bool) (string, error) {
+	switch {
+	case streaming:
+		return streamingPayload, nil
+	case s.cfg.UnsignedPayload:
+		return unsignedPayload, nil
+	}
+
+	b, err := bodypkg.RequestEntity(req)
+	if err != nil {
+		return "", err
+	}
+	return hexSHA256(b), nil
+}
+
+// ignoredHeaders are not signed because they may be altered in transit.
+var ignoredHeaders = map[string]bool{
+	"Authorization":   true,
+	"User-Agent":      true,
+	"X-Amzn-Trace-Id": true,
+	"Expect":          true,
+}
+
+func (s *SigV4Auth) canonicalHeaders(req *http.Request) (signed, canonical string) {
+	headers := map[string]string{"host": hostOf(req)}
+	for name, values := range req.Header {
+		if ignoredHeaders[name] {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(headers[name])
+		b.WriteByte('\n')
+	}
+
+	return strings.Join(names, ";"), b.String()
+}
+
+func hostOf(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// canonicalURI encodes each path segment; S3 paths are encoded once, others twice.
+func (s *SigV4Auth) canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if unescaped, err := url.PathUnescape(seg); err == nil {
+			seg = unescaped
+		}
+		seg = uriEncode(seg)
+		if s.cfg.Service != "s3" {
+			seg = uriEncode(seg)
+		}
+		segments[i] = seg
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for k, vs := range query {
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes everything except the RFC-3986 unreserved characters.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (s *SigV4Auth) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.cfg.Region + "/" + s.cfg.Service + "/aws4_request"
+}
+
+func (s *SigV4Auth) stringToSign(t time.Time, canonicalRequest string) string {
+	return sigV4Algorithm + "\n" + t.Format(amzDateFormat) + "\n" + s.scope(t) + "\n" + hexSHA256([]byte(canonicalRequest))
+}
+
+func (s *SigV4Auth) signingKey(t time.Time) []byte {
+	k := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), t.Format("20060102"))
+	k = hmacSHA256(k, s.cfg.Region)
+	k = hmacSHA256(k, s.cfg.Service)
+	return hmacSHA256(k, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hmacHex(key []byte, data string) string {
+	return hex.EncodeToString(hmacSHA256(key, data))
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func appendToken(list, token string) string {
+	if list == "" {
+		return token
+	}
+	return token + "," + list
+}
+
+//-------------------------------------------------------------------------------------------------
+
+const chunkSignatureLength = len(";chunk-signature=") + 64 + 2*len("\r\n")
+
+// chunkedLength computes the length of the aws-chunked encoding of an entity.
+func chunkedLength(size, chunkSize int64) int64 {
+	chunkHeader := func(n int64) int64 {
+		return int64(len(strconv.FormatInt(n, 16)) + chunkSignatureLength)
+	}
+
+	full := size / chunkSize
+	length := full * (chunkHeader(chunkSize) + chunkSize)
+	if rem := size % chunkSize; rem > 0 {
+		length += chunkHeader(rem) + rem
+	}
+	return length + chunkHeader(0)
+}
+
+// streamChunks replaces the request body with its aws-chunked encoding.
+func (s *SigV4Auth) streamChunks(req *http.Request, t time.Time, key []byte, seed string) {
+	encode := func(body io.ReadCloser) io.ReadCloser {
+		pr, pw := io.Pipe()
+		go func() {
+			defer body.Close()
+			_ = pw.CloseWithError(s.writeChunks(pw, body, t, key, seed))
+		}()
+		return pr
+	}
+
+	req.Body = encode(req.Body)
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return encode(body), nil
+		}
+	}
+}
+
+func (s *SigV4Auth) writeChunks(w io.Writer, body io.Reader, t time.Time, key []byte, previous string) error {
+	buf := make([]byte, s.cfg.ChunkSize)
+	for {
+		n, err := io.ReadFull(body, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		chunk := buf[:n]
+		stringToSign := strings.Join([]string{
+			sigV4Algorithm + "-PAYLOAD",
+			t.Format(amzDateFormat),
+			s.scope(t),
+			previous,
+			emptySHA256,
+			hexSHA256(chunk),
+		}, "\n")
+		previous = hmacHex(key, stringToSign)
+
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "%x;chunk-signature=%s\r\n", n, previous)
+		b.Write(chunk)
+		b.WriteString("\r\n")
+		if _, werr := w.Write(b.Bytes()); werr != nil {
+			return werr
+		}
+
+		if n == 0 {
+			return nil // the final chunk has been written
+		}
+	}
+}
@@ -8,6 +8,7 @@ package body
 import (
 	"bytes"
 	"io"
+	"net/http"
 )
 
 // A Body implements the io.Reader, io.Closer amd fmt.Stringer interfaces
@@ -82,6 +83,39 @@ func JSON(value any) *Body {
 	return &Body{b: bs}
 }
 
+// RequestEntity gets the entity of an outbound request without consuming it, e.g. so that it
+// can be hashed or signed. A *Body is used directly; otherwise http.Request.GetBody is used if
+// it is set. If not, the entity can only be read once, so it is buffered: req.Body and
+// req.GetBody are replaced with a *Body. A nil or http.NoBody entity yields nil.
+func RequestEntity(req *http.Request) ([]byte, error) {
+	switch b := req.Body.(type) {
+	case nil:
+		return nil, nil
+	case *Body:
+		return b.Bytes(), nil
+	}
+
+	if req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		rdr, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rdr.Close()
+		return io.ReadAll(rdr)
+	}
+
+	content, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	b := NewBody(content)
+	req.Body = b
+	req.GetBody = b.Getter()
+	return content, err
+}
+
 //-------------------------------------------------------------------------------------------------
 
 // String gets the byte slice as a string regardless of the current read position.
@@ -134,10 +168,13 @@ func (r *Body) Close() error {
 }
 
 // Getter returns a function that allows the body to be read multiple
-// times as used by http.Request.GetBody. b may be nil.
+// times as used by http.Request.GetBody. Each call returns a new reader
+// sharing the same byte slice, so the readers are independent. b may be nil.
 func (b *Body) Getter() func() (io.ReadCloser, error) {
 	return func() (io.ReadCloser, error) {
-		b.Rewind()
-		return b, nil
+		if b == nil {
+			return b, nil
+		}
+		return NewBody(b.b), nil
 	}
 }
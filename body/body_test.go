@@ -3,6 +3,8 @@ package body
 import (
 	"bytes"
 	"io"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/rickb777/expect"
@@ -101,6 +103,12 @@ func TestGetter(t *testing.T) {
 	expect.Error(err).Not().ToHaveOccurred(t)
 	expect.String(buf.String()).ToBe(t, "abcdefghijklmnopqrst")
 
+	//----- readers are independent -----
+	r1, _ := getter()
+	r2, _ := getter()
+	_, _ = io.Copy(io.Discard, r1)
+	expect.String(MustCopy(r2).String()).ToBe(t, "abcdefghijklmnopqrst")
+
 	// nil check
 	body = nil
 	g2 := body.Getter()
@@ -130,3 +138,36 @@ func TestNewJSON(t *testing.T) {
 	// Then...
 	expect.String(b.String()).ToContain(t, `{"A":"hello world","B":42}`)
 }
+
+func TestRequestEntity(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://example.com/", nil)
+	b, err := RequestEntity(req)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Slice(b).ToBeEmpty(t)
+
+	req.Body = http.NoBody
+	b, err = RequestEntity(req)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Slice(b).ToBeEmpty(t)
+
+	body := NewBodyString("buffered")
+	req, _ = http.NewRequest("POST", "http://example.com/", body)
+	b, err = RequestEntity(req)
+	expect.String(b, err).ToEqual(t, "buffered")
+	expect.Any(req.Body).ToBe(t, io.ReadCloser(body))
+
+	req, _ = http.NewRequest("POST", "http://example.com/", strings.NewReader("replayable"))
+	original := req.Body
+	b, err = RequestEntity(req)
+	expect.String(b, err).ToEqual(t, "replayable")
+	expect.Any(req.Body).ToBe(t, original) // not consumed
+
+	req, _ = http.NewRequest("POST", "http://example.com/", io.NopCloser(strings.NewReader("once only")))
+	b, err = RequestEntity(req)
+	expect.String(b, err).ToEqual(t, "once only")
+	rest, _ := io.ReadAll(req.Body)
+	expect.String(rest).ToEqual(t, "once only")
+	rdr, _ := req.GetBody()
+	again, _ := io.ReadAll(rdr)
+	expect.String(again).ToEqual(t, "once only")
+}
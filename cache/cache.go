@@ -0,0 +1,355 @@
+// Package cache provides a HttpClient wrapper that caches responses according to RFC-9111.
+//
+// Responses to GET requests are stored when permitted by their Cache-Control and Expires
+// headers, or when heuristically cacheable. Fresh responses are served without contacting the
+// server. Stale responses are revalidated using conditional requests ("If-None-Match" and
+// "If-Modified-Since"); a "304 Not Modified" response is then served using the stored entity.
+// Responses that vary (see the "Vary" header) are stored separately for each variant.
+//
+// Requests that carry their own conditional headers or a "Range" header bypass the cache.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rickb777/httpclient"
+)
+
+// Now provides the current time. It can be altered for testing.
+var Now = time.Now
+
+// DefaultMaxHeuristic is the default limit on heuristic freshness.
+const DefaultMaxHeuristic = 24 * time.Hour
+
+// Config controls the caching behaviour.
+type Config struct {
+	// Store holds the cached responses. If nil, an unbounded in-memory store is used.
+	Store Store
+
+	// Shared should be true when the cache is shared by several users, in which case responses
+	// marked "private", and most responses to requests that carried "Authorization", are not
+	// stored. Typically, a client-side cache is not shared.
+	Shared bool
+
+	// MaxHeuristic limits the freshness lifetime that is estimated from the Last-Modified
+	// header when the response has no explicit expiry. Zero means DefaultMaxHeuristic.
+	MaxHeuristic time.Duration
+}
+
+// CachingClient is a HttpClient that caches responses.
+type CachingClient struct {
+	upstream httpclient.HttpClient
+	cfg      Config
+
+	mu       sync.Mutex
+	inflight map[string]struct{} // background revalidations
+}
+
+// Wrap creates a caching client that wraps the next client and uses a private cache held in
+// the store. If store is nil, an unbounded in-memory store is used.
+func Wrap(next httpclient.HttpClient, store Store) httpclient.HttpClient {
+	return WrapWithConfig(next, Config{Store: store})
+}
+
+// WrapWithConfig creates a caching client that wraps the next client.
+func WrapWithConfig(next httpclient.HttpClient, cfg Config) httpclient.HttpClient {
+	if next == nil {
+		panic("Incorrect setup")
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore(0)
+	}
+	if cfg.MaxHeuristic <= 0 {
+		cfg.MaxHeuristic = DefaultMaxHeuristic
+	}
+	return &CachingClient{
+		upstream: next,
+		cfg:      cfg,
+		inflight: make(map[string]struct{}),
+	}
+}
+
+// SetCheckRedirect provides access to the http.Client.CheckRedirect field.
+func (c *CachingClient) SetCheckRedirect(fn func(req *http.Request, via []*http.Request) error) {
+	if hc, ok := c.upstream.(*http.Client); ok {
+		hc.CheckRedirect = fn
+	} else if cr, ok := c.upstream.(httpclient.ControlledRedirectClient); ok {
+		cr.SetCheckRedirect(fn)
+	}
+}
+
+func (c *CachingClient) Do(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet:
+		// cacheable
+	case http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return c.upstream.Do(req)
+	default:
+		return c.doUnsafe(req)
+	}
+
+	if bypass(req) {
+		return c.upstream.Do(req)
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	key := req.URL.String()
+	entries := decodeEntries(c.get(key))
+
+	var e *entry
+	for _, candidate := range entries {
+		if candidate.matches(req) {
+			e = candidate
+			break
+		}
+	}
+
+	if e == nil {
+		if reqCC.has("only-if-cached") {
+			return gatewayTimeout(req), nil
+		}
+		return c.fetch(req, reqCC, key, nil)
+	}
+
+	now := Now()
+	age := e.age(now)
+	lifetime := c.freshnessLifetime(e)
+	resCC := parseCacheControl(e.Header)
+
+	if c.usable(reqCC, resCC, age, lifetime) {
+		return e.response(req, age), nil
+	}
+
+	if reqCC.has("only-if-cached") {
+		return gatewayTimeout(req), nil
+	}
+
+	if d, ok := resCC.seconds("stale-while-revalidate"); ok && !c.mustRevalidate(reqCC, resCC) && age-lifetime <= d {
+		c.revalidateInBackground(req, key, e)
+		return e.response(req, age), nil
+	}
+
+	return c.fetch(req, reqCC, key, e)
+}
+
+// bypass is true for requests that the caller has made conditional or partial.
+func bypass(req *http.Request) bool {
+	for _, name := range []string{"Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "If-Range"} {
+		if req.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// usable determines whether a stored response can be served without revalidation
+// (see RFC-9111 section 4.2).
+func (c *CachingClient) usable(reqCC, resCC directives, age, lifetime time.Duration) bool {
+	if reqCC.has("no-cache") || resCC.has("no-cache") {
+		return false
+	}
+
+	if d, ok := reqCC.seconds("max-age"); ok && age > d {
+		return false
+	}
+
+	if d, ok := reqCC.seconds("min-fresh"); ok && lifetime-age < d {
+		return false
+	}
+
+	if age < lifetime {
+		return true
+	}
+
+	// stale
+	if reqCC.has("max-stale") && !c.mustRevalidate(reqCC, resCC) {
+		if d, ok := reqCC.seconds("max-stale"); ok {
+			return age-lifetime <= d
+		}
+		return reqCC["max-stale"] == "" // any staleness is acceptable
+	}
+
+	return false
+}
+
+func (c *CachingClient) mustRevalidate(reqCC, resCC directives) bool {
+	return resCC.has("must-revalidate") || (c.cfg.Shared && resCC.has("proxy-revalidate"))
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// fetch sends the request upstream, conditionally if there is a stale entry, and stores the
+// response where permitted.
+func (c *CachingClient) fetch(req *http.Request, reqCC directives, key string, stale *entry) (*http.Response, error) {
+	outbound := req
+	if stale != nil {
+		outbound = req.Clone(req.Context())
+		for name, values := range stale.validators() {
+			outbound.Header[name] = values
+		}
+	}
+
+	requestTime := Now()
+	res, err := c.upstream.Do(outbound)
+	responseTime := Now()
+
+	if stale != nil && (err != nil || res.StatusCode >= 500) {
+		if age := stale.age(responseTime); c.staleIfError(reqCC, stale, age) {
+			if res != nil {
+				_ = res.Body.Close()
+			}
+			return stale.response(req, age), nil
+		}
+	}
+
+	if err != nil {
+		return res, err
+	}
+
+	if stale != nil && res.StatusCode == http.StatusNotModified {
+		_ = res.Body.Close()
+		stale.update(res, requestTime, responseTime)
+		c.put(key, req, stale)
+		return stale.response(req, stale.age(responseTime)), nil
+	}
+
+	resCC := parseCacheControl(res.Header)
+	if !c.storable(req, res, reqCC, resCC) {
+		return res, nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	e := &entry{
+		StatusCode:   res.StatusCode,
+		Header:       res.Header.Clone(),
+		Body:         body,
+		VaryHeader:   make(http.Header),
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+	for _, name := range varyNames(res.Header) {
+		if values := req.Header.Values(name); len(values) > 0 {
+			e.VaryHeader[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+
+	c.put(key, req, e)
+	return res, nil
+}
+
+// staleIfError is true if the stale entry may be served because the server failed
+// (see RFC-5861 section 4).
+func (c *CachingClient) staleIfError(reqCC directives, e *entry, age time.Duration) bool {
+	resCC := parseCacheControl(e.Header)
+	if c.mustRevalidate(reqCC, resCC) {
+		return false
+	}
+
+	staleness := age - c.freshnessLifetime(e)
+	for _, cc := range []directives{reqCC, resCC} {
+		if d, ok := cc.seconds("stale-if-error"); ok && staleness <= d {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CachingClient) revalidateInBackground(req *http.Request, key string, stale *entry) {
+	c.mu.Lock()
+	_, busy := c.inflight[key]
+	if !busy {
+		c.inflight[key] = struct{}{}
+	}
+	c.mu.Unlock()
+
+	if busy {
+		return
+	}
+
+	// the stale entry is served to the caller, so revalidate a copy of it
+	clone := *stale
+	clone.Header = stale.Header.Clone()
+	bg := req.Clone(context.WithoutCancel(req.Context()))
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.inflight, key)
+			c.mu.Unlock()
+		}()
+
+		res, err := c.fetch(bg, parseCacheControl(bg.Header), key, &clone)
+		if err == nil {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+	}()
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// doUnsafe sends requests with unsafe methods, which invalidate any stored responses for the
+// target URL (see RFC-9111 section 4.4).
+func (c *CachingClient) doUnsafe(req *http.Request) (*http.Response, error) {
+	res, err := c.upstream.Do(req)
+	if err == nil && res.StatusCode < 400 {
+		c.cfg.Store.Delete(req.URL.String())
+		for _, name := range []string{"Location", "Content-Location"} {
+			if loc := res.Header.Get(name); loc != "" {
+				if u, e2 := req.URL.Parse(loc); e2 == nil && sameOrigin(u, req.URL) {
+					c.cfg.Store.Delete(u.String())
+				}
+			}
+		}
+	}
+	return res, err
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && a.Host == b.Host
+}
+
+func (c *CachingClient) get(key string) []byte {
+	data, _ := c.cfg.Store.Get(key)
+	return data
+}
+
+// put stores the entry, replacing any other variant that the request would select.
+func (c *CachingClient) put(key string, req *http.Request, e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := []*entry{e}
+	for _, other := range decodeEntries(c.get(key)) {
+		if !other.matches(req) {
+			entries = append(entries, other)
+		}
+	}
+
+	c.cfg.Store.Set(key, encodeEntries(entries))
+}
+
+func gatewayTimeout(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "504 " + http.StatusText(http.StatusGatewayTimeout),
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
@@ -0,0 +1,347 @@
+package cache
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+var t0 = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+type upstream struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	handler  func(req *http.Request) (*http.Response, error)
+}
+
+func (u *upstream) Do(req *http.Request) (*http.Response, error) {
+	u.mu.Lock()
+	u.requests = append(u.requests, req)
+	u.mu.Unlock()
+	return u.handler(req)
+}
+
+func (u *upstream) calls() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.requests)
+}
+
+func (u *upstream) last() *http.Request {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.requests[len(u.requests)-1]
+}
+
+func respond(code int, body string, headerKeyVals ...string) *http.Response {
+	rec := httptest.NewRecorder()
+	for i := 1; i < len(headerKeyVals); i += 2 {
+		rec.Header().Add(headerKeyVals[i-1], headerKeyVals[i])
+	}
+	rec.WriteHeader(code)
+	_, _ = rec.WriteString(body)
+	return rec.Result()
+}
+
+func setNow(t *testing.T, tm time.Time) {
+	Now = func() time.Time { return tm }
+	t.Cleanup(func() { Now = time.Now })
+}
+
+func get(t *testing.T, c http.RoundTripper, u string, headerKeyVals ...string) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, u, nil)
+	for i := 1; i < len(headerKeyVals); i += 2 {
+		req.Header.Add(headerKeyVals[i-1], headerKeyVals[i])
+	}
+	res, err := c.RoundTrip(req)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	b, err := io.ReadAll(res.Body)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	return res, string(b)
+}
+
+type roundTripper struct {
+	c interface {
+		Do(*http.Request) (*http.Response, error)
+	}
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt.c.Do(req)
+}
+
+func newClient(u *upstream, cfg Config) http.RoundTripper {
+	return roundTripper{c: WrapWithConfig(u, cfg)}
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func TestFresh_response_is_served_from_cache(t *testing.T) {
+	setNow(t, t0)
+	u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+		return respond(200, "hello", "Cache-Control", "max-age=60", "Date", httpDate(t0)), nil
+	}}
+	c := newClient(u, Config{})
+
+	_, body1 := get(t, c, "http://example.com/a")
+	setNow(t, t0.Add(30*time.Second))
+	res2, body2 := get(t, c, "http://example.com/a")
+
+	expect.Number(u.calls()).ToBe(t, 1)
+	expect.String(body1).ToBe(t, "hello")
+	expect.String(body2).ToBe(t, "hello")
+	expect.Number(res2.StatusCode).ToBe(t, 200)
+	expect.String(res2.Header.Get("Age")).ToBe(t, "30")
+}
+
+func TestStale_response_is_revalidated_and_304_served_from_cache(t *testing.T) {
+	setNow(t, t0)
+	u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			return respond(304, "", "Cache-Control", "max-age=60", "Date", httpDate(Now()), "X-Extra", "new"), nil
+		}
+		return respond(200, "hello", "Cache-Control", "max-age=60", "ETag", `"v1"`, "Date", httpDate(t0)), nil
+	}}
+	c := newClient(u, Config{})
+
+	get(t, c, "http://example.com/a")
+	setNow(t, t0.Add(2*time.Minute))
+	res2, body2 := get(t, c, "http://example.com/a")
+
+	expect.Number(u.calls()).ToBe(t, 2)
+	expect.String(u.last().Header.Get("If-None-Match")).ToBe(t, `"v1"`)
+	expect.Number(res2.StatusCode).ToBe(t, 200)
+	expect.String(body2).ToBe(t, "hello")
+	expect.String(res2.Header.Get("X-Extra")).ToBe(t, "new")
+
+	// the refreshed entry is fresh again
+	setNow(t, t0.Add(150*time.Second))
+	get(t, c, "http://example.com/a")
+	expect.Number(u.calls()).ToBe(t, 2)
+}
+
+func TestStale_response_is_replaced(t *testing.T) {
+	setNow(t, t0)
+	version := "one"
+	u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+		return respond(200, version, "Cache-Control", "max-age=60", "Last-Modified", httpDate(t0.Add(-time.Hour))), nil
+	}}
+	c := newClient(u, Config{})
+
+	get(t, c, "http://example.com/a")
+	setNow(t, t0.Add(2*time.Minute))
+	version = "two"
+	_, body2 := get(t, c, "http://example.com/a")
+	_, body3 := get(t, c, "http://example.com/a")
+
+	expect.Number(u.calls()).ToBe(t, 2)
+	expect.String(u.requests[1].Header.Get("If-Modified-Since")).ToBe(t, httpDate(t0.Add(-time.Hour)))
+	expect.String(body2).ToBe(t, "two")
+	expect.String(body3).ToBe(t, "two")
+}
+
+func TestNot_stored(t *testing.T) {
+	cases := map[string][]string{
+		"no-store":        {"Cache-Control", "no-store, max-age=60"},
+		"vary star":       {"Cache-Control", "max-age=60", "Vary", "*"},
+		"vary list star":  {"Cache-Control", "max-age=60", "Vary", "Accept, *"},
+		"vary lines star": {"Cache-Control", "max-age=60", "Vary", "Accept", "Vary", "*"},
+		"not cacheable":   {},
+		"expired already": {"Expires", "0"},
+	}
+
+	for name, headers := range cases {
+		setNow(t, t0)
+		u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+			return respond(200, "hello", headers...), nil
+		}}
+		c := newClient(u, Config{})
+
+		get(t, c, "http://example.com/a")
+		get(t, c, "http://example.com/a")
+
+		expect.Number(u.calls()).I(name).ToBe(t, 2)
+	}
+}
+
+func TestPrivate_is_stored_only_in_private_cache(t *testing.T) {
+	for _, shared := range []bool{false, true} {
+		setNow(t, t0)
+		u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+			return respond(200, "hello", "Cache-Control", "private, max-age=60"), nil
+		}}
+		c := newClient(u, Config{Shared: shared})
+
+		get(t, c, "http://example.com/a")
+		get(t, c, "http://example.com/a")
+
+		expected := 1
+		if shared {
+			expected = 2
+		}
+		expect.Number(u.calls()).Info(shared).ToBe(t, expected)
+	}
+}
+
+func TestHeuristic_freshness(t *testing.T) {
+	setNow(t, t0)
+	u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+		return respond(200, "hello", "Date", httpDate(t0), "Last-Modified", httpDate(t0.Add(-100*time.Minute))), nil
+	}}
+	c := newClient(u, Config{})
+
+	get(t, c, "http://example.com/a")
+	setNow(t, t0.Add(9*time.Minute))
+	get(t, c, "http://example.com/a")
+	expect.Number(u.calls()).ToBe(t, 1)
+
+	setNow(t, t0.Add(11*time.Minute))
+	get(t, c, "http://example.com/a")
+	expect.Number(u.calls()).ToBe(t, 2)
+}
+
+func TestVary_stores_variants(t *testing.T) {
+	setNow(t, t0)
+	u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+		return respond(200, req.Header.Get("Accept-Language"), "Cache-Control", "max-age=60", "Vary", "Accept-Language"), nil
+	}}
+	c := newClient(u, Config{})
+
+	_, en1 := get(t, c, "http://example.com/a", "Accept-Language", "en")
+	_, fr1 := get(t, c, "http://example.com/a", "Accept-Language", "fr")
+	_, en2 := get(t, c, "http://example.com/a", "Accept-Language", "en")
+	_, fr2 := get(t, c, "http://example.com/a", "Accept-Language", "fr")
+
+	expect.Number(u.calls()).ToBe(t, 2)
+	expect.String(en1).ToBe(t, "en")
+	expect.String(en2).ToBe(t, "en")
+	expect.String(fr1).ToBe(t, "fr")
+	expect.String(fr2).ToBe(t, "fr")
+}
+
+func TestRequest_directives(t *testing.T) {
+	cases := map[string]struct {
+		cc       string
+		expected int
+	}{
+		"no-cache":       {cc: "no-cache", expected: 2},
+		"max-age":        {cc: "max-age=10", expected: 2},
+		"min-fresh":      {cc: "min-fresh=40", expected: 2},
+		"max-stale":      {cc: "max-stale", expected: 1},
+		"plenty":         {cc: "max-age=100", expected: 1},
+		"only-if-cached": {cc: "only-if-cached", expected: 1},
+	}
+
+	for name, c1 := range cases {
+		setNow(t, t0)
+		u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+			return respond(200, "hello", "Cache-Control", "max-age=60", "Date", httpDate(t0)), nil
+		}}
+		c := newClient(u, Config{})
+
+		get(t, c, "http://example.com/a")
+		setNow(t, t0.Add(30*time.Second))
+		res, _ := get(t, c, "http://example.com/a", "Cache-Control", c1.cc)
+
+		expect.Number(u.calls()).I(name).ToBe(t, c1.expected)
+		expect.Number(res.StatusCode).I(name).ToBe(t, 200)
+	}
+}
+
+func TestOnly_if_cached_without_entry(t *testing.T) {
+	u := &upstream{}
+	c := newClient(u, Config{})
+
+	res, _ := get(t, c, "http://example.com/a", "Cache-Control", "only-if-cached")
+
+	expect.Number(u.calls()).ToBe(t, 0)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusGatewayTimeout)
+}
+
+func TestStale_while_revalidate(t *testing.T) {
+	setNow(t, t0)
+	version := "one"
+	done := make(chan struct{}, 1)
+	u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("If-None-Match") != "" {
+			defer func() { done <- struct{}{} }()
+		}
+		return respond(200, version, "Cache-Control", "max-age=60, stale-while-revalidate=30", "ETag", `"`+version+`"`, "Date", httpDate(Now())), nil
+	}}
+	c := newClient(u, Config{})
+
+	get(t, c, "http://example.com/a")
+	setNow(t, t0.Add(80*time.Second))
+	version = "two"
+	_, body2 := get(t, c, "http://example.com/a")
+	expect.String(body2).ToBe(t, "one")
+
+	<-done
+	// allow the background revalidation to store its result
+	time.Sleep(10 * time.Millisecond)
+
+	_, body3 := get(t, c, "http://example.com/a")
+	expect.String(body3).ToBe(t, "two")
+	expect.Number(u.calls()).ToBe(t, 2)
+}
+
+func TestStale_if_error(t *testing.T) {
+	setNow(t, t0)
+	fail := false
+	u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return respond(200, "hello", "Cache-Control", "max-age=60, stale-if-error=600", "ETag", `"v1"`), nil
+	}}
+	c := newClient(u, Config{})
+
+	get(t, c, "http://example.com/a")
+	setNow(t, t0.Add(5*time.Minute))
+	fail = true
+	res, body := get(t, c, "http://example.com/a")
+
+	expect.Number(u.calls()).ToBe(t, 2)
+	expect.Number(res.StatusCode).ToBe(t, 200)
+	expect.String(body).ToBe(t, "hello")
+}
+
+func TestUnsafe_method_invalidates(t *testing.T) {
+	setNow(t, t0)
+	u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+		return respond(200, "hello", "Cache-Control", "max-age=60"), nil
+	}}
+	c := newClient(u, Config{})
+
+	get(t, c, "http://example.com/a")
+	_, err := c.RoundTrip(httptest.NewRequest(http.MethodPost, "http://example.com/a", nil))
+	expect.Error(err).Not().ToHaveOccurred(t)
+	get(t, c, "http://example.com/a")
+
+	expect.Number(u.calls()).ToBe(t, 3)
+}
+
+func TestConditional_request_bypasses_cache(t *testing.T) {
+	setNow(t, t0)
+	u := &upstream{handler: func(req *http.Request) (*http.Response, error) {
+		return respond(200, "hello", "Cache-Control", "max-age=60"), nil
+	}}
+	c := newClient(u, Config{})
+
+	get(t, c, "http://example.com/a")
+	get(t, c, "http://example.com/a", "If-None-Match", `"x"`)
+	get(t, c, "http://example.com/a", "Range", "bytes=0-1")
+
+	expect.Number(u.calls()).ToBe(t, 3)
+}
+
+func httpDate(t time.Time) string {
+	return t.UTC().Format(http.TimeFormat)
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// directives holds the Cache-Control directives from a request or response.
+// Directive names are lowercase; values are unquoted.
+type directives map[string]string
+
+func parseCacheControl(h http.Header) directives {
+	cc := make(directives)
+	for _, line := range h.Values("Cache-Control") {
+		for _, d := range splitOutsideQuotes(line) {
+			name, value, _ := strings.Cut(d, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" {
+				cc[name] = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+
+	// see RFC-9111 section 5.4
+	if _, exists := cc["no-cache"]; !exists && len(h.Values("Cache-Control")) == 0 {
+		if strings.EqualFold(strings.TrimSpace(h.Get("Pragma")), "no-cache") {
+			cc["no-cache"] = ""
+		}
+	}
+
+	return cc
+}
+
+func splitOutsideQuotes(s string) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func (cc directives) has(name string) bool {
+	_, exists := cc[name]
+	return exists
+}
+
+// seconds gets a delta-seconds value. If the directive is absent or invalid, ok is false.
+func (cc directives) seconds(name string) (d time.Duration, ok bool) {
+	v, exists := cc[name]
+	if !exists {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// heuristicallyCacheable lists the status codes that are cacheable by default (RFC-9110 section 15.1).
+var heuristicallyCacheable = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// storable determines whether a response may be stored (see RFC-9111 section 3).
+func (c *CachingClient) storable(req *http.Request, res *http.Response, reqCC, resCC directives) bool {
+	if req.Method != http.MethodGet || res.StatusCode < 200 ||
+		res.StatusCode == http.StatusPartialContent || res.StatusCode == http.StatusNotModified {
+		return false
+	}
+
+	if reqCC.has("no-store") || resCC.has("no-store") {
+		return false
+	}
+
+	if slices.Contains(varyNames(res.Header), "*") {
+		return false
+	}
+
+	if c.cfg.Shared {
+		if resCC.has("private") {
+			return false
+		}
+		if req.Header.Get("Authorization") != "" &&
+			!(resCC.has("public") || resCC.has("s-maxage") || resCC.has("must-revalidate")) {
+			return false
+		}
+	}
+
+	return res.Header.Get("Expires") != "" ||
+		resCC.has("max-age") ||
+		(c.cfg.Shared && resCC.has("s-maxage")) ||
+		resCC.has("public") ||
+		(!c.cfg.Shared && resCC.has("private")) ||
+		heuristicallyCacheable[res.StatusCode]
+}
+
+// freshnessLifetime determines how long a stored response is fresh (see RFC-9111 section 4.2.1).
+func (c *CachingClient) freshnessLifetime(e *entry) time.Duration {
+	cc := parseCacheControl(e.Header)
+
+	if c.cfg.Shared {
+		if d, ok := cc.seconds("s-maxage"); ok {
+			return d
+		}
+	}
+
+	if d, ok := cc.seconds("max-age"); ok {
+		return d
+	}
+
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0 // invalid dates represent a time in the past
+		}
+		return max(t.Sub(e.date()), 0)
+	}
+
+	if lm, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && heuristicallyCacheable[e.StatusCode] {
+		return min(e.date().Sub(lm)/10, c.cfg.MaxHeuristic)
+	}
+
+	return 0
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	h := http.Header{}
+	h.Add("Cache-Control", `Max-Age=60, no-cache="Set-Cookie, X-Foo"`)
+	h.Add("Cache-Control", "private")
+
+	cc := parseCacheControl(h)
+
+	expect.Map(cc).ToHaveLength(t, 3)
+	expect.String(cc["no-cache"]).ToBe(t, "Set-Cookie, X-Foo")
+	expect.Bool(cc.has("private")).ToBeTrue(t)
+
+	d, ok := cc.seconds("max-age")
+	expect.Bool(ok).ToBeTrue(t)
+	expect.Number(d).ToBe(t, time.Minute)
+}
+
+func TestParseCacheControl_pragma(t *testing.T) {
+	h := http.Header{}
+	h.Set("Pragma", "no-cache")
+
+	expect.Bool(parseCacheControl(h).has("no-cache")).ToBeTrue(t)
+}
+
+func TestFreshnessLifetime(t *testing.T) {
+	c := WrapWithConfig(&upstream{}, Config{Shared: true, MaxHeuristic: time.Hour}).(*CachingClient)
+	date := httpDate(t0)
+
+	cases := map[string]struct {
+		header   http.Header
+		expected time.Duration
+	}{
+		"s-maxage": {header: http.Header{"Cache-Control": {"max-age=60, s-maxage=90"}}, expected: 90 * time.Second},
+		"max-age":  {header: http.Header{"Cache-Control": {"max-age=60"}, "Expires": {httpDate(t0.Add(time.Hour))}}, expected: time.Minute},
+		"expires":  {header: http.Header{"Date": {date}, "Expires": {httpDate(t0.Add(time.Hour))}}, expected: time.Hour},
+		"invalid":  {header: http.Header{"Date": {date}, "Expires": {"0"}}, expected: 0},
+		"heuristic": {header: http.Header{"Date": {date}, "Last-Modified": {httpDate(t0.Add(-5 * time.Hour))}},
+			expected: 30 * time.Minute},
+		"heuristic limit": {header: http.Header{"Date": {date}, "Last-Modified": {httpDate(t0.Add(-50 * time.Hour))}},
+			expected: time.Hour},
+	}
+
+	for name, c1 := range cases {
+		e := &entry{StatusCode: 200, Header: c1.header, ResponseTime: t0}
+		expect.Number(c.freshnessLifetime(e)).I(name).ToBe(t, c1.expected)
+	}
+}
+
+func TestEntryAge(t *testing.T) {
+	e := &entry{
+		Header:       http.Header{"Date": {httpDate(t0)}, "Age": {"10"}},
+		RequestTime:  t0.Add(time.Second),
+		ResponseTime: t0.Add(3 * time.Second),
+	}
+
+	// corrected initial age is 10s + 2s response delay, then resident for 20s
+	expect.Number(e.age(t0.Add(23*time.Second))).ToBe(t, 32*time.Second)
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// entry is a stored response. Each URL may have several entries that differ according to
+// the request headers listed by the Vary response header.
+type entry struct {
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+	VaryHeader   http.Header // the selecting request headers
+	RequestTime  time.Time
+	ResponseTime time.Time
+}
+
+func decodeEntries(data []byte) []*entry {
+	var entries []*entry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entries); err != nil {
+		return nil // treat corrupt entries as missing
+	}
+	return entries
+}
+
+func encodeEntries(entries []*entry) []byte {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(entries); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// date gets the Date header, or else the time the response was received.
+func (e *entry) date() time.Time {
+	if d, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return d
+	}
+	return e.ResponseTime
+}
+
+// age computes the current age of the entry (see RFC-9111 section 4.2.3).
+func (e *entry) age(now time.Time) time.Duration {
+	apparentAge := max(e.ResponseTime.Sub(e.date()), 0)
+
+	var ageValue time.Duration
+	if n, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && n > 0 {
+		ageValue = time.Duration(n) * time.Second
+	}
+
+	responseDelay := e.ResponseTime.Sub(e.RequestTime)
+	correctedInitialAge := max(apparentAge, ageValue+responseDelay)
+	residentTime := now.Sub(e.ResponseTime)
+	return correctedInitialAge + residentTime
+}
+
+// matches is true if the request has the same selecting headers as the stored one. "Vary: *"
+// never matches.
+func (e *entry) matches(req *http.Request) bool {
+	for _, name := range varyNames(e.Header) {
+		if name == "*" || normalise(req.Header.Values(name)) != normalise(e.VaryHeader.Values(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// validators gets the conditional request headers needed to revalidate the entry.
+func (e *entry) validators() http.Header {
+	h := make(http.Header)
+	if etag := e.Header.Get("ETag"); etag != "" {
+		h.Set("If-None-Match", etag)
+	}
+	if lm := e.Header.Get("Last-Modified"); lm != "" {
+		h.Set("If-Modified-Since", lm)
+	}
+	return h
+}
+
+// update merges the headers of a 304 Not Modified response (see RFC-9111 section 3.2).
+func (e *entry) update(res *http.Response, requestTime, responseTime time.Time) {
+	for name, values := range res.Header {
+		switch name {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding", "Content-Range":
+			// these describe the 304 message, not the stored entity
+		default:
+			e.Header[name] = values
+		}
+	}
+	e.RequestTime = requestTime
+	e.ResponseTime = responseTime
+}
+
+// response builds a response from the entry.
+func (e *entry) response(req *http.Request, age time.Duration) *http.Response {
+	h := e.Header.Clone()
+	h.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func varyNames(h http.Header) []string {
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func normalise(values []string) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		for _, p := range strings.Split(v, ",") {
+			parts = append(parts, strings.TrimSpace(p))
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// Store holds cached responses, which are opaque byte slices, by key. Implementations must be
+// safe for concurrent use. A store is allowed to discard entries at any time.
+type Store interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// MemoryStore is an in-memory [Store] that discards the least-recently used entries when full.
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	lru        *list.List // of *memoryItem; the front is the most recently used
+	items      map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryStore creates an in-memory store holding up to maxEntries entries. If maxEntries
+// is zero or negative, the store is unbounded.
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (ms *MemoryStore) Get(key string) ([]byte, bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	el, exists := ms.items[key]
+	if !exists {
+		return nil, false
+	}
+	ms.lru.MoveToFront(el)
+	return el.Value.(*memoryItem).value, true
+}
+
+func (ms *MemoryStore) Set(key string, value []byte) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if el, exists := ms.items[key]; exists {
+		el.Value.(*memoryItem).value = value
+		ms.lru.MoveToFront(el)
+		return
+	}
+
+	ms.items[key] = ms.lru.PushFront(&memoryItem{key: key, value: value})
+
+	for ms.maxEntries > 0 && ms.lru.Len() > ms.maxEntries {
+		oldest := ms.lru.Back()
+		ms.lru.Remove(oldest)
+		delete(ms.items, oldest.Value.(*memoryItem).key)
+	}
+}
+
+func (ms *MemoryStore) Delete(key string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if el, exists := ms.items[key]; exists {
+		ms.lru.Remove(el)
+		delete(ms.items, key)
+	}
+}
+
+// Len gets the number of entries in the store.
+func (ms *MemoryStore) Len() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.lru.Len()
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// FileStore is a [Store] that holds each entry in a file within a directory. The file names
+// are derived from a hash of the key. Errors are treated as cache misses.
+type FileStore struct {
+	fs  afero.Fs
+	dir string
+}
+
+// NewFileStore creates a store in the directory dir on the filesystem fs, which is the OS
+// filesystem if nil. The directory is created when needed.
+func NewFileStore(fs afero.Fs, dir string) *FileStore {
+	if fs == nil {
+		fs = afero.NewOsFs()
+	}
+	return &FileStore{fs: fs, dir: dir}
+}
+
+func (fs *FileStore) Get(key string) ([]byte, bool) {
+	value, err := afero.ReadFile(fs.fs, fs.path(key))
+	return value, err == nil
+}
+
+func (fs *FileStore) Set(key string, value []byte) {
+	if err := fs.fs.MkdirAll(fs.dir, 0755); err != nil {
+		return
+	}
+
+	// write to a temporary file first so that readers never see a partial entry
+	f, err := afero.TempFile(fs.fs, fs.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(value)
+	if e2 := f.Close(); err == nil {
+		err = e2
+	}
+
+	if err == nil {
+		err = fs.fs.Rename(f.Name(), fs.path(key))
+	}
+
+	if err != nil {
+		_ = fs.fs.Remove(f.Name())
+	}
+}
+
+func (fs *FileStore) Delete(key string) {
+	_ = fs.fs.Remove(fs.path(key))
+}
+
+func (fs *FileStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(fs.dir, hex.EncodeToString(sum[:]))
+}
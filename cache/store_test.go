@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+	"github.com/spf13/afero"
+)
+
+func TestMemoryStore_evicts_least_recently_used(t *testing.T) {
+	ms := NewMemoryStore(2)
+
+	ms.Set("a", []byte("1"))
+	ms.Set("b", []byte("2"))
+	_, _ = ms.Get("a")
+	ms.Set("c", []byte("3"))
+
+	_, found := ms.Get("b")
+	expect.Bool(found).ToBeFalse(t)
+
+	v, found := ms.Get("a")
+	expect.Bool(found).ToBeTrue(t)
+	expect.String(string(v)).ToBe(t, "1")
+	expect.Number(ms.Len()).ToBe(t, 2)
+
+	ms.Delete("a")
+	_, found = ms.Get("a")
+	expect.Bool(found).ToBeFalse(t)
+	expect.Number(ms.Len()).ToBe(t, 1)
+}
+
+func TestFileStore(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	store := NewFileStore(fs, "/var/cache/http")
+
+	_, found := store.Get("http://example.com/a")
+	expect.Bool(found).ToBeFalse(t)
+
+	store.Set("http://example.com/a", []byte("hello"))
+	store.Set("http://example.com/a", []byte("world"))
+
+	v, found := store.Get("http://example.com/a")
+	expect.Bool(found).ToBeTrue(t)
+	expect.String(string(v)).ToBe(t, "world")
+
+	names, err := afero.ReadDir(fs, "/var/cache/http")
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Slice(names).ToHaveLength(t, 1)
+
+	store.Delete("http://example.com/a")
+	_, found = store.Get("http://example.com/a")
+	expect.Bool(found).ToBeFalse(t)
+}
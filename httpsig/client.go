@@ -0,0 +1,59 @@
+package httpsig
+
+import (
+	"net/http"
+
+	"github.com/rickb777/httpclient"
+)
+
+type signingClient struct {
+	upstream httpclient.HttpClient
+	signer   *Signer
+	verifier *Verifier
+}
+
+// Wrap creates a client that signs every request using the signer, then, if verifier
+// is not nil, verifies every response. Responses that fail verification are closed
+// and an error is returned instead. Either signer or verifier may be nil. A copy of each
+// request is signed, so the caller's request is not altered.
+func Wrap(next httpclient.HttpClient, signer *Signer, verifier *Verifier) httpclient.HttpClient {
+	if next == nil {
+		panic("httpsig: next client is nil")
+	}
+	return &signingClient{upstream: next, signer: signer, verifier: verifier}
+}
+
+// SetCheckRedirect provides access to the http.Client.CheckRedirect field.
+func (c *signingClient) SetCheckRedirect(fn func(req *http.Request, via []*http.Request) error) {
+	if hc, ok := c.upstream.(*http.Client); ok {
+		hc.CheckRedirect = fn
+	} else if cr, ok := c.upstream.(httpclient.ControlledRedirectClient); ok {
+		cr.SetCheckRedirect(fn)
+	}
+}
+
+func (c *signingClient) Do(req *http.Request) (*http.Response, error) {
+	out := req
+	if c.signer != nil {
+		out = req.WithContext(req.Context())
+		out.Header = req.Header.Clone() // the caller's request is not altered
+		if err := c.signer.Sign(out); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := c.upstream.Do(out)
+	if err != nil || c.verifier == nil {
+		return res, err
+	}
+
+	if res.Request == nil {
+		res.Request = out
+	}
+
+	if err := c.verifier.Verify(res); err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+	return res, nil
+}
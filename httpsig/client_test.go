@@ -0,0 +1,79 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+// signingServer verifies signed requests and signs its responses.
+func signingServer(t *testing.T, tamper bool) *httptest.Server {
+	verifier := &Verifier{Keys: StaticKeys(map[string]VerifyingKey{"client": testSecret()}), Required: []string{"@method", "content-digest"}}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := verifier.VerifyRequest(req); err != nil {
+			t.Log(err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		content := []byte(`{"ok": true}`)
+		digest, _ := ContentDigest(SHA256, content)
+		w.Header().Set("Content-Digest", digest)
+
+		res := &http.Response{StatusCode: http.StatusOK, Header: w.Header(), Request: req}
+		p, _ := parseSignatureParams(`("@status" "content-digest" "@method";req);keyid="server"`)
+		base, _ := signatureBase(message{req: req, res: res}, p)
+		sig, _ := testSecret().Sign(base)
+		w.Header().Set("Signature-Input", "sig1="+p.raw)
+		w.Header().Set("Signature", "sig1=:"+base64.StdEncoding.EncodeToString(sig)+":")
+
+		if tamper {
+			content = []byte(`{"ok": false}`)
+		}
+		_, _ = w.Write(content)
+	}))
+}
+
+func TestWrap(t *testing.T) {
+	svr := signingServer(t, false)
+	defer svr.Close()
+
+	signer := NewSigner(Config{Key: testSecret(), KeyID: "client"})
+	verifier := &Verifier{Keys: StaticKeys(map[string]VerifyingKey{"server": testSecret()}), Required: []string{"@status"}}
+	client := Wrap(http.DefaultClient, signer, verifier)
+
+	req, _ := http.NewRequest("POST", svr.URL+"/a/b?c=d", strings.NewReader(`{"hello": "world"}`))
+	res, err := client.Do(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusOK)
+	b, _ := io.ReadAll(res.Body)
+	expect.String(string(b)).ToBe(t, `{"ok": true}`)
+
+	// the caller's request is unchanged
+	expect.String(req.Header.Get("Signature")).ToBe(t, "")
+	expect.String(req.Header.Get("Signature-Input")).ToBe(t, "")
+	expect.String(req.Header.Get("Content-Digest")).ToBe(t, "")
+}
+
+func TestWrap_response_verification_fails(t *testing.T) {
+	svr := signingServer(t, true)
+	defer svr.Close()
+
+	signer := NewSigner(Config{Key: testSecret(), KeyID: "client"})
+	verifier := &Verifier{Keys: StaticKeys(map[string]VerifyingKey{"server": testSecret()})}
+	client := Wrap(http.DefaultClient, signer, verifier)
+
+	req, _ := http.NewRequest("GET", svr.URL, nil)
+	res, err := client.Do(req)
+
+	expect.Any(res).ToBeNil(t)
+	expect.Bool(errors.Is(err, ErrDigestMismatch)).Info(err).ToBeTrue(t)
+}
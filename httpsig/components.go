@@ -0,0 +1,241 @@
+package httpsig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// component is a covered component identifier (RFC-9421 section 2).
+type component struct {
+	name string // a derived component such as "@method", or a lowercase field name
+	req  bool   // in a response, the component is taken from the request
+}
+
+// derivedComponents lists the supported derived components (RFC-9421 section 2.2).
+var derivedComponents = map[string]bool{
+	"@method":         true,
+	"@target-uri":     true,
+	"@authority":      true,
+	"@scheme":         true,
+	"@request-target": true,
+	"@path":           true,
+	"@query":          true,
+	"@status":         true,
+}
+
+func newComponent(name string, params map[string]string) (component, error) {
+	c := component{name: name}
+
+	for k := range params {
+		if k != "req" {
+			return c, fmt.Errorf("httpsig: unsupported component parameter %q on %q", k, name)
+		}
+		c.req = true
+	}
+
+	if strings.HasPrefix(name, "@") {
+		if !derivedComponents[name] {
+			return c, fmt.Errorf("httpsig: unsupported derived component %q", name)
+		}
+	} else if name == "" || name != strings.ToLower(name) {
+		return c, fmt.Errorf("httpsig: invalid component name %q", name)
+	}
+
+	return c, nil
+}
+
+// parseComponent parses a component identifier such as "content-type" or "@method;req".
+func parseComponent(id string) (component, error) {
+	name, param, _ := strings.Cut(id, ";")
+	params := map[string]string{}
+	if param != "" {
+		params[param] = "?1"
+	}
+	return newComponent(strings.ToLower(name), params)
+}
+
+func (c component) String() string {
+	if c.req {
+		return quote(c.name) + ";req"
+	}
+	return quote(c.name)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// signatureParams holds the signature metadata (RFC-9421 section 2.3).
+type signatureParams struct {
+	components []component
+	created    int64 // zero if absent
+	expires    int64 // zero if absent
+	nonce      string
+	alg        string
+	keyID      string
+	tag        string
+	raw        string // the serialised form
+}
+
+func (p *signatureParams) serialise() string {
+	ids := make([]string, len(p.components))
+	for i, c := range p.components {
+		ids[i] = c.String()
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("(" + strings.Join(ids, " ") + ")")
+	if p.created != 0 {
+		b.WriteString(";created=" + strconv.FormatInt(p.created, 10))
+	}
+	if p.expires != 0 {
+		b.WriteString(";expires=" + strconv.FormatInt(p.expires, 10))
+	}
+	if p.nonce != "" {
+		b.WriteString(";nonce=" + quote(p.nonce))
+	}
+	if p.keyID != "" {
+		b.WriteString(";keyid=" + quote(p.keyID))
+	}
+	if p.alg != "" {
+		b.WriteString(";alg=" + quote(p.alg))
+	}
+	if p.tag != "" {
+		b.WriteString(";tag=" + quote(p.tag))
+	}
+	return b.String()
+}
+
+func (p *signatureParams) covers(name string) bool {
+	for _, c := range p.components {
+		if c.name == name && !c.req {
+			return true
+		}
+	}
+	return false
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// message is the request or response being signed or verified.
+type message struct {
+	req *http.Request  // the request, or the request that elicited the response
+	res *http.Response // nil for requests
+}
+
+// signatureBase creates the signature base (RFC-9421 section 2.5).
+func signatureBase(m message, p *signatureParams) ([]byte, error) {
+	var b bytes.Buffer
+	seen := map[component]bool{}
+
+	for _, c := range p.components {
+		if seen[c] {
+			return nil, fmt.Errorf("httpsig: duplicate component %s", c)
+		}
+		seen[c] = true
+
+		v, err := m.value(c)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "%s: %s\n", c, v)
+	}
+
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", p.raw)
+	return b.Bytes(), nil
+}
+
+func (m message) value(c component) (string, error) {
+	if c.req {
+		if m.res == nil || m.req == nil {
+			return "", fmt.Errorf("httpsig: %s is only allowed in a response", c)
+		}
+		m.res = nil
+	}
+
+	if !strings.HasPrefix(c.name, "@") {
+		header := m.header()
+		if header == nil || len(header.Values(c.name)) == 0 {
+			return "", fmt.Errorf("httpsig: %s is not present in the message", c)
+		}
+		return fieldValue(header.Values(c.name)), nil
+	}
+
+	if c.name == "@status" {
+		if m.res == nil {
+			return "", errors.New(`httpsig: "@status" is only allowed in a response`)
+		}
+		return strconv.Itoa(m.res.StatusCode), nil
+	}
+
+	req := m.req
+	if req == nil {
+		return "", fmt.Errorf("httpsig: %s requires the request", c)
+	}
+
+	switch c.name {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		return scheme(req) + "://" + authority(req) + req.URL.RequestURI(), nil
+	case "@authority":
+		return authority(req), nil
+	case "@scheme":
+		return scheme(req), nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		if p := req.URL.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return "/", nil
+	default: // @query
+		return "?" + req.URL.RawQuery, nil
+	}
+}
+
+func (m message) header() http.Header {
+	if m.res != nil {
+		return m.res.Header
+	}
+	return m.req.Header
+}
+
+// fieldValue combines the field lines, removing surrounding whitespace (RFC-9421 section 2.1).
+func fieldValue(values []string) string {
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ", ")
+}
+
+func scheme(req *http.Request) string {
+	if req.URL.Scheme != "" {
+		return strings.ToLower(req.URL.Scheme)
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// authority is the lowercase host, omitting the default port for the scheme.
+func authority(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host = strings.ToLower(host)
+
+	u := url.URL{Host: host}
+	switch port := u.Port(); {
+	case port == "80" && scheme(req) == "http",
+		port == "443" && scheme(req) == "https":
+		return strings.TrimSuffix(host, ":"+port)
+	}
+	return host
+}
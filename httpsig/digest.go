@@ -0,0 +1,97 @@
+package httpsig
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+
+	bodypkg "github.com/rickb777/httpclient/body"
+)
+
+// Content-Digest algorithms registered by RFC-9530 section 5.
+const (
+	SHA256 = "sha-256"
+	SHA512 = "sha-512"
+)
+
+// ErrDigestMismatch is returned when the content does not match its Content-Digest.
+var ErrDigestMismatch = errors.New("httpsig: content digest mismatch")
+
+var digestAlgorithms = map[string]func() hash.Hash{
+	SHA256: sha256.New,
+	SHA512: sha512.New,
+}
+
+// ContentDigest computes the Content-Digest field value (RFC-9530) for some content,
+// using SHA256 or SHA512.
+func ContentDigest(alg string, content []byte) (string, error) {
+	if _, ok := digestAlgorithms[alg]; !ok {
+		return "", fmt.Errorf("httpsig: unsupported digest algorithm %q", alg)
+	}
+	return alg + "=:" + base64.StdEncoding.EncodeToString(digest(alg, content)) + ":", nil
+}
+
+func digest(alg string, content []byte) []byte {
+	h := digestAlgorithms[alg]()
+	h.Write(content)
+	return h.Sum(nil)
+}
+
+// VerifyContentDigest checks the content against a Content-Digest field value. Every
+// supported digest in the field must match and there must be at least one.
+func VerifyContentDigest(field string, content []byte) error {
+	members, err := parseDictionary(field)
+	if err != nil {
+		return err
+	}
+
+	checked := 0
+	for _, m := range members {
+		if _, ok := digestAlgorithms[m.key]; !ok {
+			continue
+		}
+		given, err := parseByteSequence(m.raw)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(given, digest(m.key, content)) != 1 {
+			return ErrDigestMismatch
+		}
+		checked++
+	}
+
+	if checked == 0 {
+		return fmt.Errorf("httpsig: no supported algorithm in Content-Digest %q", field)
+	}
+	return nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// responseContent gets the response entity, which is buffered so that it can still be read.
+func responseContent(res *http.Response) ([]byte, error) {
+	if b, ok := res.Body.(*bodypkg.Body); ok {
+		return b.Bytes(), nil
+	}
+	if res.Body == nil || res.Body == http.NoBody {
+		return nil, nil
+	}
+
+	content, err := readAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = bodypkg.NewBody(content)
+	return content, nil
+}
+
+func readAll(rdr io.ReadCloser) ([]byte, error) {
+	defer rdr.Close()
+	return io.ReadAll(rdr)
+}
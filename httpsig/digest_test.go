@@ -0,0 +1,32 @@
+package httpsig
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestContentDigest(t *testing.T) {
+	content := []byte(`{"hello": "world"}`)
+
+	d256, err := ContentDigest(SHA256, content)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(d256).ToBe(t, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:")
+
+	d512, err := ContentDigest(SHA512, content)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(d512).ToBe(t, "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:")
+
+	_, err = ContentDigest("md5", content)
+	expect.Error(err).ToHaveOccurred(t)
+}
+
+func TestVerifyContentDigest(t *testing.T) {
+	content := []byte(`{"hello": "world"}`)
+
+	expect.Error(VerifyContentDigest("sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", content)).Not().ToHaveOccurred(t)
+	expect.Error(VerifyContentDigest("unixsum=:AAAA:, sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", content)).Not().ToHaveOccurred(t)
+	expect.Any(VerifyContentDigest("sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", []byte("{}"))).ToBe(t, ErrDigestMismatch)
+	expect.Error(VerifyContentDigest("unixsum=:AAAA:", content)).ToHaveOccurred(t)
+	expect.Error(VerifyContentDigest("", content)).ToHaveOccurred(t)
+}
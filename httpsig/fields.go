@@ -0,0 +1,261 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file contains just enough of RFC-8941 (structured field values) to handle the
+// Signature-Input, Signature and Content-Digest dictionaries.
+
+// member is a dictionary member; raw is the unparsed value.
+type member struct {
+	key, raw string
+}
+
+// parseDictionary splits a dictionary field into its members, in order.
+func parseDictionary(field string) ([]member, error) {
+	var members []member
+	for _, m := range splitOutside(field, ',') {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		key, raw, ok := strings.Cut(m, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("httpsig: invalid dictionary member %q", m)
+		}
+		members = append(members, member{key: strings.TrimSpace(key), raw: strings.TrimSpace(raw)})
+	}
+	return members, nil
+}
+
+// splitOutside splits s at each sep that is not inside a string or an inner list.
+func splitOutside(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped, depth, start := false, false, 0, 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+//-------------------------------------------------------------------------------------------------
+
+type scanner struct {
+	s string
+	i int
+}
+
+func (sc *scanner) done() bool {
+	return sc.i >= len(sc.s)
+}
+
+func (sc *scanner) peek() byte {
+	if sc.done() {
+		return 0
+	}
+	return sc.s[sc.i]
+}
+
+func (sc *scanner) skipSpaces() {
+	for sc.peek() == ' ' {
+		sc.i++
+	}
+}
+
+func (sc *scanner) errorf(format string, args ...any) error {
+	return fmt.Errorf("httpsig: "+format+" at offset %d in %q", append(args, sc.i, sc.s)...)
+}
+
+// key scans a parameter key.
+func (sc *scanner) key() (string, error) {
+	start := sc.i
+	for !sc.done() {
+		c := sc.peek()
+		if ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '_' || c == '-' || c == '.' || c == '*' {
+			sc.i++
+		} else {
+			break
+		}
+	}
+	if sc.i == start {
+		return "", sc.errorf("expected key")
+	}
+	return sc.s[start:sc.i], nil
+}
+
+// bareItem scans a string, integer, token or byte sequence. Strings are unescaped; byte
+// sequences are returned in base64.
+func (sc *scanner) bareItem() (string, error) {
+	switch c := sc.peek(); {
+	case c == '"':
+		return sc.str()
+
+	case c == ':':
+		end := strings.IndexByte(sc.s[sc.i+1:], ':')
+		if end < 0 {
+			return "", sc.errorf("unterminated byte sequence")
+		}
+		v := sc.s[sc.i+1 : sc.i+1+end]
+		sc.i += end + 2
+		return v, nil
+
+	default:
+		start := sc.i
+		for !sc.done() && !strings.ContainsRune(" ;,()=\"", rune(sc.peek())) {
+			sc.i++
+		}
+		if sc.i == start {
+			return "", sc.errorf("expected item")
+		}
+		return sc.s[start:sc.i], nil
+	}
+}
+
+func (sc *scanner) str() (string, error) {
+	var b strings.Builder
+	for sc.i++; !sc.done(); sc.i++ {
+		switch c := sc.s[sc.i]; c {
+		case '\\':
+			sc.i++
+			if sc.done() {
+				return "", sc.errorf("unterminated string")
+			}
+			b.WriteByte(sc.s[sc.i])
+		case '"':
+			sc.i++
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", sc.errorf("unterminated string")
+}
+
+// params scans any parameters. Boolean parameters without a value are given the value "?1".
+func (sc *scanner) params() (map[string]string, error) {
+	params := map[string]string{}
+	for sc.peek() == ';' {
+		sc.i++
+		sc.skipSpaces()
+		k, err := sc.key()
+		if err != nil {
+			return nil, err
+		}
+		v := "?1"
+		if sc.peek() == '=' {
+			sc.i++
+			if v, err = sc.bareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params[k] = v
+	}
+	return params, nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// parseSignatureParams parses a Signature-Input member value, which is an inner list of
+// component identifiers followed by the signature parameters.
+func parseSignatureParams(raw string) (*signatureParams, error) {
+	sc := &scanner{s: raw}
+	if sc.peek() != '(' {
+		return nil, sc.errorf("expected inner list")
+	}
+	sc.i++
+
+	p := &signatureParams{raw: raw}
+	for {
+		sc.skipSpaces()
+		if sc.peek() == ')' {
+			sc.i++
+			break
+		}
+		if sc.peek() != '"' {
+			return nil, sc.errorf("expected component identifier")
+		}
+		name, err := sc.str()
+		if err != nil {
+			return nil, err
+		}
+		cp, err := sc.params()
+		if err != nil {
+			return nil, err
+		}
+		c, err := newComponent(name, cp)
+		if err != nil {
+			return nil, err
+		}
+		p.components = append(p.components, c)
+	}
+
+	params, err := sc.params()
+	if err != nil {
+		return nil, err
+	}
+	if !sc.done() {
+		return nil, sc.errorf("unexpected text")
+	}
+
+	for k, v := range params {
+		switch k {
+		case "created", "expires":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("httpsig: invalid %s parameter %q", k, v)
+			}
+			if k == "created" {
+				p.created = n
+			} else {
+				p.expires = n
+			}
+		case "nonce":
+			p.nonce = v
+		case "alg":
+			p.alg = v
+		case "keyid":
+			p.keyID = v
+		case "tag":
+			p.tag = v
+		}
+	}
+	return p, nil
+}
+
+// parseByteSequence parses a dictionary member value that is a byte sequence.
+func parseByteSequence(raw string) ([]byte, error) {
+	sc := &scanner{s: raw}
+	if sc.peek() != ':' {
+		return nil, sc.errorf("expected byte sequence")
+	}
+	v, err := sc.bareItem()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(v)
+}
+
+// quote serialises a string item.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
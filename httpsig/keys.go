@@ -0,0 +1,175 @@
+package httpsig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Algorithm names registered by RFC-9421 section 6.2.2.
+const (
+	RSAPSSSHA512    = "rsa-pss-sha512"
+	ECDSAP256SHA256 = "ecdsa-p256-sha256"
+	Ed25519         = "ed25519"
+	HMACSHA256      = "hmac-sha256"
+)
+
+// ErrInvalidSignature is returned when a signature does not match the signature base.
+var ErrInvalidSignature = errors.New("httpsig: invalid signature")
+
+// SigningKey creates signatures.
+type SigningKey interface {
+	// Algorithm gets the registered algorithm name.
+	Algorithm() string
+
+	// Sign signs the signature base.
+	Sign(base []byte) ([]byte, error)
+}
+
+// VerifyingKey verifies signatures.
+type VerifyingKey interface {
+	// Algorithm gets the registered algorithm name.
+	Algorithm() string
+
+	// Verify checks the signature of the signature base, returning ErrInvalidSignature
+	// if it does not match.
+	Verify(base, signature []byte) error
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Ed25519Key creates a signing key for the "ed25519" algorithm (RFC-9421 section 3.3.6).
+func Ed25519Key(key ed25519.PrivateKey) SigningKey { return ed25519Key(key) }
+
+// Ed25519PublicKey creates a verifying key for the "ed25519" algorithm.
+func Ed25519PublicKey(key ed25519.PublicKey) VerifyingKey { return ed25519PublicKey(key) }
+
+type ed25519Key ed25519.PrivateKey
+
+func (k ed25519Key) Algorithm() string { return Ed25519 }
+
+func (k ed25519Key) Sign(base []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(k), base), nil
+}
+
+type ed25519PublicKey ed25519.PublicKey
+
+func (k ed25519PublicKey) Algorithm() string { return Ed25519 }
+
+func (k ed25519PublicKey) Verify(base, signature []byte) error {
+	if !ed25519.Verify(ed25519.PublicKey(k), base, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// ECDSAKey creates a signing key for the "ecdsa-p256-sha256" algorithm (RFC-9421 section 3.3.4).
+// The key must use the P-256 curve.
+func ECDSAKey(key *ecdsa.PrivateKey) SigningKey { return ecdsaKey{key} }
+
+// ECDSAPublicKey creates a verifying key for the "ecdsa-p256-sha256" algorithm.
+func ECDSAPublicKey(key *ecdsa.PublicKey) VerifyingKey { return ecdsaPublicKey{key} }
+
+type ecdsaKey struct{ key *ecdsa.PrivateKey }
+
+func (k ecdsaKey) Algorithm() string { return ECDSAP256SHA256 }
+
+// Sign produces the 64-byte concatenation of r and s, not the ASN.1 encoding.
+func (k ecdsaKey) Sign(base []byte) ([]byte, error) {
+	if k.key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("httpsig: %s requires a P-256 key", ECDSAP256SHA256)
+	}
+
+	h := sha256.Sum256(base)
+	r, s, err := ecdsa.Sign(rand.Reader, k.key, h[:])
+	if err != nil {
+		return nil, err
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig, nil
+}
+
+type ecdsaPublicKey struct{ key *ecdsa.PublicKey }
+
+func (k ecdsaPublicKey) Algorithm() string { return ECDSAP256SHA256 }
+
+func (k ecdsaPublicKey) Verify(base, signature []byte) error {
+	if len(signature) != 64 {
+		return ErrInvalidSignature
+	}
+
+	h := sha256.Sum256(base)
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(k.key, h[:], r, s) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// RSAPSSKey creates a signing key for the "rsa-pss-sha512" algorithm (RFC-9421 section 3.3.1).
+func RSAPSSKey(key *rsa.PrivateKey) SigningKey { return rsaPSSKey{key} }
+
+// RSAPSSPublicKey creates a verifying key for the "rsa-pss-sha512" algorithm.
+func RSAPSSPublicKey(key *rsa.PublicKey) VerifyingKey { return rsaPSSPublicKey{key} }
+
+var pssOptions = &rsa.PSSOptions{SaltLength: 64, Hash: crypto.SHA512}
+
+type rsaPSSKey struct{ key *rsa.PrivateKey }
+
+func (k rsaPSSKey) Algorithm() string { return RSAPSSSHA512 }
+
+func (k rsaPSSKey) Sign(base []byte) ([]byte, error) {
+	h := sha512.Sum512(base)
+	return rsa.SignPSS(rand.Reader, k.key, crypto.SHA512, h[:], pssOptions)
+}
+
+type rsaPSSPublicKey struct{ key *rsa.PublicKey }
+
+func (k rsaPSSPublicKey) Algorithm() string { return RSAPSSSHA512 }
+
+func (k rsaPSSPublicKey) Verify(base, signature []byte) error {
+	h := sha512.Sum512(base)
+	if rsa.VerifyPSS(k.key, crypto.SHA512, h[:], signature, pssOptions) != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// HMACKey is a shared-secret key for the "hmac-sha256" algorithm (RFC-9421 section 3.3.3).
+// It is used both for signing and verifying.
+type HMACKey []byte
+
+func (k HMACKey) Algorithm() string { return HMACSHA256 }
+
+func (k HMACKey) Sign(base []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k)
+	mac.Write(base)
+	return mac.Sum(nil), nil
+}
+
+func (k HMACKey) Verify(base, signature []byte) error {
+	expected, _ := k.Sign(base)
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Package httpsig provides HTTP message signatures (RFC-9421), optionally combined with
+// content digests (RFC-9530).
+//
+// A [Signer] adds "Signature-Input" and "Signature" headers to requests. It can be used
+// directly, as a HttpClient decorator via [Wrap], or as an authenticator for the rest
+// client (see auth.MessageSignature). A [Verifier] checks the signatures of responses
+// (or of requests, when used in a server).
+//
+// The signature base covers a configurable list of components: the derived components
+// "@method", "@target-uri", "@authority", "@scheme", "@request-target", "@path", "@query" and
+// "@status", and any header fields, including "content-digest".
+package httpsig
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	bodypkg "github.com/rickb777/httpclient/body"
+)
+
+// now provides the current time. It can be altered for testing.
+var now = time.Now
+
+// DefaultComponents are covered when no others are specified.
+var DefaultComponents = []string{"@method", "@target-uri", "@authority", "content-digest"}
+
+// Config controls how requests are signed.
+type Config struct {
+	// Key signs the signature base. This is required.
+	Key SigningKey
+
+	// KeyID identifies the key to the verifier (the "keyid" parameter).
+	KeyID string
+
+	// Label names the signature in the Signature-Input and Signature dictionaries.
+	// Zero means "sig1".
+	Label string
+
+	// Components lists the covered components, e.g. "@method" or "content-type". Header
+	// names are case-insensitive. Nil means DefaultComponents.
+	Components []string
+
+	// Digest is the Content-Digest algorithm, SHA256 or SHA512, used when "content-digest"
+	// is covered and the request does not already have one. Zero means SHA256.
+	Digest string
+
+	// Expires, if positive, limits the lifetime of the signature (the "expires" parameter).
+	Expires time.Duration
+
+	// Nonce adds a random "nonce" parameter.
+	Nonce bool
+
+	// Tag is an optional application-specific "tag" parameter.
+	Tag string
+
+	// IncludeAlg adds the "alg" parameter. This is usually unnecessary because the
+	// verifier can determine the algorithm from the key.
+	IncludeAlg bool
+}
+
+// Signer signs HTTP requests.
+type Signer struct {
+	cfg        Config
+	components []component
+}
+
+// NewSigner creates a signer. It panics if the configuration is invalid.
+func NewSigner(cfg Config) *Signer {
+	if cfg.Key == nil {
+		panic("httpsig: no signing key")
+	}
+	if cfg.Label == "" {
+		cfg.Label = "sig1"
+	}
+	if cfg.Components == nil {
+		cfg.Components = DefaultComponents
+	}
+	if cfg.Digest == "" {
+		cfg.Digest = SHA256
+	}
+	if _, ok := digestAlgorithms[cfg.Digest]; !ok {
+		panic("httpsig: unsupported digest algorithm " + cfg.Digest)
+	}
+
+	s := &Signer{cfg: cfg}
+	for _, id := range cfg.Components {
+		c, err := parseComponent(id)
+		if err != nil {
+			panic(err)
+		}
+		if c.req {
+			panic("httpsig: requests cannot cover " + c.String())
+		}
+		s.components = append(s.components, c)
+	}
+	return s
+}
+
+// KeyID gets the configured key identifier.
+func (s *Signer) KeyID() string {
+	return s.cfg.KeyID
+}
+
+// Sign adds a signature to the request, replacing any previous signature having the same
+// label. If "content-digest" is covered, the Content-Digest header is added if absent; this
+// reads the request entity without consuming it.
+func (s *Signer) Sign(req *http.Request) error {
+	p := &signatureParams{
+		components: s.components,
+		created:    now().Unix(),
+		keyID:      s.cfg.KeyID,
+		tag:        s.cfg.Tag,
+	}
+
+	if p.covers("content-digest") && req.Header.Get("Content-Digest") == "" {
+		content, err := bodypkg.RequestEntity(req)
+		if err != nil {
+			return err
+		}
+		field, _ := ContentDigest(s.cfg.Digest, content)
+		req.Header.Set("Content-Digest", field)
+	}
+
+	if s.cfg.Expires > 0 {
+		p.expires = p.created + int64(s.cfg.Expires/time.Second)
+	}
+	if s.cfg.Nonce {
+		p.nonce = randomNonce()
+	}
+	if s.cfg.IncludeAlg {
+		p.alg = s.cfg.Key.Algorithm()
+	}
+	p.raw = p.serialise()
+
+	base, err := signatureBase(message{req: req}, p)
+	if err != nil {
+		return err
+	}
+
+	sig, err := s.cfg.Key.Sign(base)
+	if err != nil {
+		return err
+	}
+
+	setMember(req.Header, "Signature-Input", s.cfg.Label, p.raw)
+	setMember(req.Header, "Signature", s.cfg.Label, ":"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// setMember sets a dictionary member, keeping any other members.
+func setMember(header http.Header, name, label, value string) {
+	field := label + "=" + value
+	if members, err := parseDictionary(strings.Join(header.Values(name), ", ")); err == nil {
+		for _, m := range members {
+			if m.key != label {
+				field += ", " + m.key + "=" + m.raw
+			}
+		}
+	}
+	header.Set(name, field)
+}
+
+func randomNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,146 @@
+package httpsig
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+// The test request and keys are from RFC-9421 appendix B.
+
+const (
+	testEd25519Seed  = "n4Ni-HpISpVObnQMW0wOhCKROaIKqKtW_2ZYb2p9KcU"
+	testSharedSecret = "uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ=="
+)
+
+func setNow(t *testing.T, unix int64) {
+	now = func() time.Time { return time.Unix(unix, 0) }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func testRequest() *http.Request {
+	req, _ := http.NewRequest("POST", "http://example.com/foo?param=Value&Pet=dog", strings.NewReader(`{"hello": "world"}`))
+	req.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Digest", "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:")
+	req.Header.Set("Content-Length", "18")
+	return req
+}
+
+func testEd25519Key() ed25519.PrivateKey {
+	seed, _ := base64.RawURLEncoding.DecodeString(testEd25519Seed)
+	return ed25519.NewKeyFromSeed(seed)
+}
+
+func testSecret() HMACKey {
+	secret, _ := base64.StdEncoding.DecodeString(testSharedSecret)
+	return secret
+}
+
+func TestSign_ed25519_rfc9421_B_2_6(t *testing.T) {
+	setNow(t, 1618884473)
+	req := testRequest()
+
+	signer := NewSigner(Config{
+		Key:        Ed25519Key(testEd25519Key()),
+		KeyID:      "test-key-ed25519",
+		Label:      "sig-b26",
+		Components: []string{"date", "@method", "@path", "@authority", "Content-Type", "content-length"},
+	})
+
+	err := signer.Sign(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(req.Header.Get("Signature-Input")).ToBe(t, `sig-b26=("date" "@method" "@path" "@authority" "content-type" "content-length");created=1618884473;keyid="test-key-ed25519"`)
+	expect.String(req.Header.Get("Signature")).ToBe(t, `sig-b26=:wqcAqbmYJ2ji2glfAMaRy4gruYYnx2nEFN2HN6jrnDnQCK1u02Gb04v9EDgwUPiu4A0w6vuQv5lIp5WPpBKRCw==:`)
+}
+
+func TestSign_hmac_rfc9421_B_2_5(t *testing.T) {
+	setNow(t, 1618884473)
+	req := testRequest()
+
+	signer := NewSigner(Config{
+		Key:        testSecret(),
+		KeyID:      "test-shared-secret",
+		Label:      "sig-b25",
+		Components: []string{"date", "@authority", "content-type"},
+	})
+
+	err := signer.Sign(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(req.Header.Get("Signature")).ToBe(t, `sig-b25=:pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=:`)
+}
+
+func TestSignatureBase(t *testing.T) {
+	req := testRequest()
+	p := &signatureParams{created: 1618884473, keyID: "k", expires: 1618884773, nonce: "n", tag: "t", alg: Ed25519}
+	for _, id := range []string{"@method", "@target-uri", "@authority", "@scheme", "@request-target", "@path", "@query", "content-digest"} {
+		c, _ := parseComponent(id)
+		p.components = append(p.components, c)
+	}
+	p.raw = p.serialise()
+
+	base, err := signatureBase(message{req: req}, p)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(string(base)).ToBe(t, `"@method": POST
+"@target-uri": http://example.com/foo?param=Value&Pet=dog
+"@authority": example.com
+"@scheme": http
+"@request-target": /foo?param=Value&Pet=dog
+"@path": /foo
+"@query": ?param=Value&Pet=dog
+"content-digest": sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:
+"@signature-params": ("@method" "@target-uri" "@authority" "@scheme" "@request-target" "@path" "@query" "content-digest");created=1618884473;expires=1618884773;nonce="n";keyid="k";alg="ed25519";tag="t"`)
+}
+
+func TestSign_adds_content_digest(t *testing.T) {
+	req, _ := http.NewRequest("PUT", "https://example.com:443/x", strings.NewReader(`{"hello": "world"}`))
+
+	err := NewSigner(Config{Key: testSecret(), Expires: time.Minute, Nonce: true, IncludeAlg: true}).Sign(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(req.Header.Get("Content-Digest")).ToBe(t, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:")
+	expect.String(req.Header.Get("Signature-Input")).ToContain(t, `sig1=("@method" "@target-uri" "@authority" "content-digest");created=`)
+	expect.String(req.Header.Get("Signature-Input")).ToContain(t, `;alg="hmac-sha256"`)
+
+	// the entity can still be sent
+	rdr, _ := req.GetBody()
+	b := make([]byte, 100)
+	n, _ := rdr.Read(b)
+	expect.String(string(b[:n])).ToBe(t, `{"hello": "world"}`)
+}
+
+func TestSign_keeps_other_signatures(t *testing.T) {
+	req := testRequest()
+	req.Header.Set("Signature-Input", `proxy=("@method");created=1`)
+	req.Header.Set("Signature", `proxy=:AAAA:`)
+
+	err := NewSigner(Config{Key: testSecret(), Components: []string{"@method"}}).Sign(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(req.Header.Get("Signature-Input")).ToContain(t, `, proxy=("@method");created=1`)
+	expect.String(req.Header.Get("Signature")).ToContain(t, `, proxy=:AAAA:`)
+}
+
+func TestSign_missing_header(t *testing.T) {
+	req := testRequest()
+
+	err := NewSigner(Config{Key: testSecret(), Components: []string{"x-missing"}}).Sign(req)
+
+	expect.Error(err).ToHaveOccurred(t)
+	expect.String(req.Header.Get("Signature")).ToBe(t, "")
+}
+
+func TestNewSigner_invalid_component(t *testing.T) {
+	defer func() {
+		expect.Any(recover()).Not().ToBeNil(t)
+	}()
+	NewSigner(Config{Key: testSecret(), Components: []string{"@unknown"}})
+}
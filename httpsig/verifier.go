@@ -0,0 +1,187 @@
+package httpsig
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	bodypkg "github.com/rickb777/httpclient/body"
+)
+
+// ErrNoSignature is returned when a message has no signature to verify.
+var ErrNoSignature = errors.New("httpsig: no signature")
+
+// ErrExpired is returned when a signature has expired or is too old.
+var ErrExpired = errors.New("httpsig: signature expired")
+
+// Verifier checks the signatures of HTTP messages.
+type Verifier struct {
+	// Keys looks up the verifying key for a "keyid" parameter, returning nil if it is
+	// unknown. This is required.
+	Keys func(keyID string) VerifyingKey
+
+	// Label, if not blank, selects the signature to verify; otherwise all the signatures
+	// in the message are verified.
+	Label string
+
+	// Required lists components that every signature must cover, e.g. "@status" or
+	// "content-digest". For responses, request components are written like "@method;req".
+	Required []string
+
+	// MaxAge, if positive, rejects signatures created longer ago than this.
+	MaxAge time.Duration
+}
+
+// StaticKeys provides a verifier's keys from a map.
+func StaticKeys(keys map[string]VerifyingKey) func(string) VerifyingKey {
+	return func(keyID string) VerifyingKey { return keys[keyID] }
+}
+
+// Verify checks the signatures of a response. Components marked ";req" are taken from
+// res.Request. If "content-digest" is covered, the response entity is checked too; it
+// is buffered so that it can still be read afterwards.
+func (v *Verifier) Verify(res *http.Response) error {
+	return v.verify(message{req: res.Request, res: res})
+}
+
+// VerifyRequest checks the signatures of a request, e.g. when received by a server. If
+// "content-digest" is covered, the request entity is checked too, without consuming it.
+func (v *Verifier) VerifyRequest(req *http.Request) error {
+	return v.verify(message{req: req})
+}
+
+func (v *Verifier) verify(m message) error {
+	header := m.header()
+
+	inputs, err := parseDictionary(strings.Join(header.Values("Signature-Input"), ", "))
+	if err != nil {
+		return err
+	}
+	signatures, err := parseDictionary(strings.Join(header.Values("Signature"), ", "))
+	if err != nil {
+		return err
+	}
+
+	verified := 0
+	for _, input := range inputs {
+		if v.Label != "" && input.key != v.Label {
+			continue
+		}
+
+		sig, ok := find(signatures, input.key)
+		if !ok {
+			return &SignatureError{Label: input.key, Err: ErrNoSignature}
+		}
+
+		if err := v.verifyOne(m, input.raw, sig.raw); err != nil {
+			return &SignatureError{Label: input.key, Err: err}
+		}
+		verified++
+	}
+
+	if verified == 0 {
+		return ErrNoSignature
+	}
+	return nil
+}
+
+func (v *Verifier) verifyOne(m message, input, signature string) error {
+	p, err := parseSignatureParams(input)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range v.Required {
+		c, err := parseComponent(id)
+		if err != nil {
+			return err
+		}
+		if !covered(p, c) {
+			return fmt.Errorf("httpsig: %s is not covered", c)
+		}
+	}
+
+	t := now().Unix()
+	if p.expires != 0 && t > p.expires {
+		return ErrExpired
+	}
+	if v.MaxAge > 0 && (p.created == 0 || t > p.created+int64(v.MaxAge/time.Second)) {
+		return ErrExpired
+	}
+
+	key := v.Keys(p.keyID)
+	if key == nil {
+		return fmt.Errorf("httpsig: unknown key %q", p.keyID)
+	}
+	if p.alg != "" && p.alg != key.Algorithm() {
+		return fmt.Errorf("httpsig: algorithm %q does not match key %q", p.alg, p.keyID)
+	}
+
+	if p.covers("content-digest") {
+		if err := v.verifyContent(m); err != nil {
+			return err
+		}
+	}
+
+	sig, err := parseByteSequence(signature)
+	if err != nil {
+		return err
+	}
+
+	base, err := signatureBase(m, p)
+	if err != nil {
+		return err
+	}
+
+	return key.Verify(base, sig)
+}
+
+func (v *Verifier) verifyContent(m message) error {
+	var content []byte
+	var err error
+	if m.res != nil {
+		content, err = responseContent(m.res)
+	} else {
+		content, err = bodypkg.RequestEntity(m.req)
+	}
+	if err != nil {
+		return err
+	}
+	return VerifyContentDigest(strings.Join(m.header().Values("Content-Digest"), ", "), content)
+}
+
+func find(members []member, key string) (member, bool) {
+	for _, m := range members {
+		if m.key == key {
+			return m, true
+		}
+	}
+	return member{}, false
+}
+
+func covered(p *signatureParams, c component) bool {
+	for _, pc := range p.components {
+		if pc == c {
+			return true
+		}
+	}
+	return false
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// SignatureError is returned when a particular signature cannot be verified.
+type SignatureError struct {
+	Label string
+	Err   error
+}
+
+func (e *SignatureError) Error() string {
+	return "httpsig: signature " + e.Label + ": " + strings.TrimPrefix(e.Err.Error(), "httpsig: ")
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
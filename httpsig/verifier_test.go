@@ -0,0 +1,159 @@
+package httpsig
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestVerifyRequest_round_trip(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edKey := testEd25519Key()
+
+	cases := map[string]struct {
+		sk SigningKey
+		vk VerifyingKey
+	}{
+		"ed25519": {Ed25519Key(edKey), Ed25519PublicKey(edKey.Public().(ed25519.PublicKey))},
+		"ecdsa":   {ECDSAKey(ecKey), ECDSAPublicKey(&ecKey.PublicKey)},
+		"rsa-pss": {RSAPSSKey(rsaKey), RSAPSSPublicKey(&rsaKey.PublicKey)},
+		"hmac":    {testSecret(), testSecret()},
+	}
+
+	for name, c := range cases {
+		req := testRequest()
+		req.Header.Del("Content-Digest")
+		err := NewSigner(Config{Key: c.sk, KeyID: name, IncludeAlg: true}).Sign(req)
+		expect.Error(err).Info(name).Not().ToHaveOccurred(t)
+
+		v := &Verifier{Keys: StaticKeys(map[string]VerifyingKey{name: c.vk}), Required: []string{"@method", "content-digest"}}
+		err = v.VerifyRequest(req)
+		expect.Error(err).Info(name).Not().ToHaveOccurred(t)
+
+		// tampering is detected
+		req.Method = "PUT"
+		err = v.VerifyRequest(req)
+		expect.Bool(errors.Is(err, ErrInvalidSignature)).Info(name, err).ToBeTrue(t)
+	}
+}
+
+func TestVerify_response(t *testing.T) {
+	req := testRequest()
+	res := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"busy": true}`)),
+		Request:    req,
+	}
+	signResponse(t, res, `("@status" "content-digest" "@method";req "@authority";req)`)
+
+	v := &Verifier{Keys: StaticKeys(map[string]VerifyingKey{"k": testSecret()}), Required: []string{"@status", "@method;req"}}
+
+	err := v.Verify(res)
+	expect.Error(err).Not().ToHaveOccurred(t)
+
+	// the body can still be read
+	b, _ := io.ReadAll(res.Body)
+	expect.String(string(b)).ToBe(t, `{"busy": true}`)
+}
+
+func TestVerify_response_content_mismatch(t *testing.T) {
+	res := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"busy": true}`)),
+		Request:    testRequest(),
+	}
+	signResponse(t, res, `("@status" "content-digest")`)
+	res.Body = io.NopCloser(strings.NewReader(`{"busy": false}`))
+
+	err := (&Verifier{Keys: StaticKeys(map[string]VerifyingKey{"k": testSecret()})}).Verify(res)
+
+	expect.Bool(errors.Is(err, ErrDigestMismatch)).Info(err).ToBeTrue(t)
+	var se *SignatureError
+	expect.Bool(errors.As(err, &se)).ToBeTrue(t)
+	expect.String(se.Label).ToBe(t, "sig1")
+	expect.String(err.Error()).ToBe(t, "httpsig: signature sig1: content digest mismatch")
+}
+
+// signResponse signs a response using the serialised component list.
+func signResponse(t *testing.T, res *http.Response, components string) {
+	t.Helper()
+	content, _ := responseContent(res)
+	digest, _ := ContentDigest(SHA512, content)
+	res.Header.Set("Content-Digest", digest)
+
+	p, err := parseSignatureParams(components + `;created=1618884473;keyid="k"`)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	base, err := signatureBase(message{req: res.Request, res: res}, p)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	sig, _ := testSecret().Sign(base)
+
+	res.Header.Set("Signature-Input", "sig1="+p.raw)
+	res.Header.Set("Signature", "sig1=:"+base64.StdEncoding.EncodeToString(sig)+":")
+}
+
+func TestVerify_failures(t *testing.T) {
+	setNow(t, 1618884473)
+	keys := StaticKeys(map[string]VerifyingKey{"k": testSecret(), "other": Ed25519PublicKey(nil)})
+
+	sign := func(cfg Config) *http.Request {
+		req := testRequest()
+		cfg.Key = testSecret()
+		expect.Error(NewSigner(cfg).Sign(req)).Not().ToHaveOccurred(t)
+		return req
+	}
+
+	err := (&Verifier{Keys: keys}).VerifyRequest(testRequest())
+	expect.Any(err).ToBe(t, ErrNoSignature)
+
+	err = (&Verifier{Keys: keys, Label: "other"}).VerifyRequest(sign(Config{KeyID: "k"}))
+	expect.Any(err).ToBe(t, ErrNoSignature)
+
+	err = (&Verifier{Keys: keys}).VerifyRequest(sign(Config{KeyID: "nobody"}))
+	expect.String(err.Error()).ToBe(t, `httpsig: signature sig1: unknown key "nobody"`)
+
+	err = (&Verifier{Keys: keys}).VerifyRequest(sign(Config{KeyID: "other", IncludeAlg: true}))
+	expect.String(err.Error()).ToBe(t, `httpsig: signature sig1: algorithm "hmac-sha256" does not match key "other"`)
+
+	err = (&Verifier{Keys: keys, Required: []string{"content-type"}}).VerifyRequest(sign(Config{KeyID: "k"}))
+	expect.String(err.Error()).ToBe(t, `httpsig: signature sig1: "content-type" is not covered`)
+
+	req := sign(Config{KeyID: "k", Expires: time.Minute})
+	setNow(t, 1618884473+61)
+	err = (&Verifier{Keys: keys}).VerifyRequest(req)
+	expect.Bool(errors.Is(err, ErrExpired)).ToBeTrue(t)
+
+	err = (&Verifier{Keys: keys, MaxAge: time.Minute}).VerifyRequest(sign(Config{KeyID: "k"}))
+	expect.Error(err).Not().ToHaveOccurred(t)
+}
+
+func TestParseSignatureParams(t *testing.T) {
+	p, err := parseSignatureParams(`("@method" "@status";req "x-a");created=1;expires=2;nonce="a\"b";keyid="k";alg="ed25519";tag="t";other=3`)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Slice(p.components).ToBe(t, component{name: "@method"}, component{name: "@status", req: true}, component{name: "x-a"})
+	expect.Number(p.created).ToBe(t, int64(1))
+	expect.Number(p.expires).ToBe(t, int64(2))
+	expect.String(p.nonce).ToBe(t, `a"b`)
+	expect.String(p.keyID).ToBe(t, "k")
+	expect.String(p.alg).ToBe(t, "ed25519")
+	expect.String(p.tag).ToBe(t, "t")
+
+	for _, bad := range []string{`"@method"`, `("@method"`, `("@foo")`, `("X-A")`, `("a";bs)`, `("a");created=x`} {
+		_, err = parseSignatureParams(bad)
+		expect.Error(err).Info(bad).ToHaveOccurred(t)
+	}
+}
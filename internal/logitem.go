@@ -24,10 +24,11 @@ func PrepareTheLogItem(req *http.Request, level logging.Level) *logging.LogItem
 
 	if level == logging.WithHeadersAndBodies {
 		if req.Body != nil && req.Body != http.NoBody {
-			item.Request.Body, _ = body.Copy(req.Body)
-			req.Body = item.Request.Body
+			b, _ := body.Copy(req.Body)
+			item.Request.Body = b // may be replaced by a redacted copy later
+			req.Body = b
 			req.GetBody = func() (io.ReadCloser, error) {
-				return item.Request.Body, nil
+				return b, nil
 			}
 
 		} else if req.GetBody != nil {
@@ -42,7 +43,14 @@ func PrepareTheLogItem(req *http.Request, level logging.Level) *logging.LogItem
 
 type ILogger func(item *logging.LogItem)
 
-func CompleteTheLogging(res *http.Response, err error, item *logging.LogItem, log ILogger, level logging.Level) (*http.Response, error) {
+// CompleteTheLogging records the response and passes the log item to the logger, after
+// hiding any secrets (see logging.Redact).
+func CompleteTheLogging(res *http.Response, err error, item *logging.LogItem, logger ILogger, level logging.Level) (*http.Response, error) {
+	log := func(item *logging.LogItem) {
+		logging.Redact.Apply(item)
+		logger(item)
+	}
+
 	item.Duration = logging.Now().Sub(item.Start)
 
 	if res != nil {
@@ -0,0 +1,214 @@
+package mytesting
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DigestServer is a http.Handler implementing the server side of RFC-7616 digest authentication,
+// for testing clients. Authenticated requests receive "204 No Content", unless Next is set.
+//
+// If Proxy is set, it acts as an authenticating proxy: it reads "Proxy-Authorization" and
+// challenges with "407 Proxy Authentication Required". Authenticated requests are passed to
+// Next, which may forward them.
+type DigestServer struct {
+	Realm      string
+	Algorithms []string // one challenge is sent per algorithm; default MD5
+	Qop        string   // e.g. "auth, auth-int"; blank for RFC-2069 compatibility
+	UserHash   bool
+	Users      map[string]string // username to password
+	StaleAfter int               // if positive, nonces expire after this many uses
+	Proxy      bool
+	Next       http.Handler // optional
+
+	mu         sync.Mutex
+	nonces     map[string]*nonceState
+	Authorized []map[string]string // the parameters of each accepted Authorization header
+	Challenges int
+}
+
+type nonceState struct {
+	lastNC uint64
+	uses   int
+}
+
+func (ds *DigestServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if ds.nonces == nil {
+		ds.nonces = make(map[string]*nonceState)
+	}
+
+	authorization := req.Header.Get(ds.header("Authorization", "Proxy-Authorization"))
+	if !strings.HasPrefix(authorization, "Digest ") {
+		ds.challenge(w, false)
+		return
+	}
+
+	params := parseParams(authorization[7:])
+	state, known := ds.nonces[params["nonce"]]
+	if !known {
+		ds.challenge(w, false)
+		return
+	}
+
+	if ds.StaleAfter > 0 && state.uses >= ds.StaleAfter {
+		delete(ds.nonces, params["nonce"])
+		ds.challenge(w, true)
+		return
+	}
+
+	if params["qop"] != "" {
+		nc, err := strconv.ParseUint(params["nc"], 16, 32)
+		if err != nil || nc <= state.lastNC {
+			http.Error(w, "replayed nonce count", http.StatusBadRequest)
+			return
+		}
+		state.lastNC = nc
+	}
+
+	if !ds.verify(req, params) {
+		ds.challenge(w, false)
+		return
+	}
+
+	state.uses++
+	ds.Authorized = append(ds.Authorized, params)
+
+	if ds.Next != nil {
+		ds.Next.ServeHTTP(w, req)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (ds *DigestServer) header(server, proxy string) string {
+	if ds.Proxy {
+		return proxy
+	}
+	return server
+}
+
+func (ds *DigestServer) challenge(w http.ResponseWriter, stale bool) {
+	ds.Challenges++
+	algorithms := ds.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{"MD5"}
+	}
+
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	nonce := hex.EncodeToString(b)
+	ds.nonces[nonce] = &nonceState{}
+
+	for _, a := range algorithms {
+		c := fmt.Sprintf(`Digest realm="%s", nonce="%s", algorithm=%s, opaque="xyz"`, ds.Realm, nonce, a)
+		if ds.Qop != "" {
+			c += fmt.Sprintf(`, qop="%s"`, ds.Qop)
+		}
+		if ds.UserHash {
+			c += ", charset=UTF-8, userhash=true"
+		}
+		if stale {
+			c += ", stale=true"
+		}
+		w.Header().Add(ds.header("WWW-Authenticate", "Proxy-Authenticate"), c)
+	}
+
+	if ds.Proxy {
+		w.WriteHeader(http.StatusProxyAuthRequired)
+	} else {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+}
+
+func (ds *DigestServer) verify(req *http.Request, p map[string]string) bool {
+	var newHash func() hash.Hash
+	algorithm := p["algorithm"]
+	switch strings.TrimSuffix(algorithm, "-sess") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	case "SHA-512-256":
+		newHash = sha512.New512_256
+	default:
+		return false
+	}
+
+	h := func(s string) string {
+		hh := newHash()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	if p["uri"] != req.URL.RequestURI() || p["realm"] != ds.Realm {
+		return false
+	}
+
+	for user, pw := range ds.Users {
+		name := user
+		if p["userhash"] == "true" {
+			name = h(user + ":" + ds.Realm)
+		}
+		if p["username"] != name {
+			continue
+		}
+
+		ha1 := h(user + ":" + ds.Realm + ":" + pw)
+		if strings.HasSuffix(algorithm, "-sess") {
+			ha1 = h(ha1 + ":" + p["nonce"] + ":" + p["cnonce"])
+		}
+
+		ha2 := h(req.Method + ":" + p["uri"])
+		if p["qop"] == "auth-int" {
+			hh := newHash()
+			_, _ = io.Copy(hh, req.Body)
+			ha2 = h(req.Method + ":" + p["uri"] + ":" + hex.EncodeToString(hh.Sum(nil)))
+		}
+
+		var expected string
+		if p["qop"] == "" {
+			expected = h(ha1 + ":" + p["nonce"] + ":" + ha2)
+		} else {
+			expected = h(strings.Join([]string{ha1, p["nonce"], p["nc"], p["cnonce"], p["qop"], ha2}, ":"))
+		}
+		return p["response"] == expected
+	}
+
+	return false
+}
+
+// parseParams parses comma-separated auth-params, some of which may be quoted strings.
+func parseParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s != "" {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, found := strings.Cut(s, "=")
+		if !found {
+			break
+		}
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"') + 1
+			value, s = rest[1:end], rest[end+1:]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+		}
+
+		params[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return params
+}
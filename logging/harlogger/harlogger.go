@@ -0,0 +1,249 @@
+// Package harlogger provides a logger that records requests and responses in an HTTP Archive
+// (HAR 1.2), which can be opened in browser developer tools and used by replay tools.
+// See http://www.softwareishard.com/blog/har-12-spec/
+package harlogger
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/rickb777/httpclient/logging"
+	"github.com/rickb777/httpclient/logging/logger"
+	"github.com/spf13/afero"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Archive accumulates log items as HAR entries, which are written to a file when the
+// archive is flushed or closed. It is safe for concurrent use.
+type Archive struct {
+	name string
+	fs   afero.Fs
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// New returns a new Archive that will be written to a file with the given name.
+// If fs is nil, the OS filesystem is used.
+func New(name string, fs afero.Fs) *Archive {
+	if fs == nil {
+		fs = afero.NewOsFs()
+	}
+	return &Archive{name: name, fs: fs}
+}
+
+// Logger gets a logger that adds log items to the archive, for use with loggingclient or
+// loggingtransport. As with other loggers, the item's level determines whether headers and
+// bodies are recorded.
+func (a *Archive) Logger() logger.Logger {
+	return a.Add
+}
+
+// Add adds a log item to the archive.
+func (a *Archive) Add(item *logging.LogItem) {
+	e := newEntry(item)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, e)
+}
+
+// Entries gets a copy of the entries accumulated so far, in order of their start times.
+func (a *Archive) Entries() []Entry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sortedEntries()
+}
+
+// Flush writes the archive file, containing all the entries so far. The file is rewritten
+// by each flush, so the archive can be flushed periodically.
+func (a *Archive) Flush() error {
+	a.mu.Lock()
+	doc := HAR{Log: Log{
+		Version: "1.2",
+		Creator: Creator{Name: "httpclient", Version: creatorVersion()},
+		Entries: a.sortedEntries(),
+	}}
+	a.mu.Unlock()
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := a.fs.OpenFile(a.name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(b)
+	if e2 := f.Close(); err == nil {
+		err = e2
+	}
+	return err
+}
+
+// Close flushes the archive.
+func (a *Archive) Close() error {
+	return a.Flush()
+}
+
+// sortedEntries is called when a.mu is held.
+func (a *Archive) sortedEntries() []Entry {
+	entries := make([]Entry, len(a.entries))
+	copy(entries, a.entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].start.Before(entries[j].start)
+	})
+	if entries == nil {
+		entries = []Entry{} // HAR requires an array
+	}
+	return entries
+}
+
+func creatorVersion() string {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range bi.Deps {
+			if dep.Path == "github.com/rickb777/httpclient" {
+				return dep.Version
+			}
+		}
+	}
+	return "(devel)"
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func newEntry(item *logging.LogItem) Entry {
+	ms := float64(item.Duration.Microseconds()) / 1000
+
+	e := Entry{
+		start:           item.Start,
+		StartedDateTime: item.Start.Format("2006-01-02T15:04:05.000Z07:00"),
+		Time:            ms,
+		Request: Request{
+			Method:      item.Method,
+			URL:         item.URL.String(),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []Cookie{},
+			Headers:     []NameValue{},
+			QueryString: []NameValue{},
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: Response{
+			Status:      item.StatusCode,
+			StatusText:  http.StatusText(item.StatusCode),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []Cookie{},
+			Headers:     []NameValue{},
+			Content:     Content{MimeType: "x-unknown"},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Cache:   struct{}{},
+		Timings: Timings{Blocked: -1, DNS: -1, Connect: -1, Send: 0, Wait: ms, Receive: 0, SSL: -1},
+	}
+
+	if item.Err != nil {
+		e.Comment = item.Err.Error()
+		e.Response.StatusText = ""
+	}
+
+	for _, k := range sortedKeys(item.URL.Query()) {
+		for _, v := range item.URL.Query()[k] {
+			e.Request.QueryString = append(e.Request.QueryString, NameValue{Name: k, Value: v})
+		}
+	}
+
+	if item.Level >= logging.WithHeaders {
+		e.Request.Headers = headers(item.Request.Header)
+		e.Response.Headers = headers(item.Response.Header)
+		e.Request.Cookies = cookies((&http.Request{Header: item.Request.Header}).Cookies())
+		e.Response.Cookies = cookies((&http.Response{Header: item.Response.Header}).Cookies())
+		e.Response.RedirectURL = item.Response.Header.Get("Location")
+		if ct := item.Response.Header.Get("Content-Type"); ct != "" {
+			e.Response.Content.MimeType = ct
+		}
+	}
+
+	if item.Level == logging.WithHeadersAndBodies {
+		if item.Request.Body != nil && len(item.Request.Body.Bytes()) > 0 {
+			body := item.Request.Body.Bytes()
+			e.Request.BodySize = len(body)
+			e.Request.PostData = postData(item.Request, body)
+		}
+
+		if item.Response.Body != nil {
+			body := item.Response.Body.Bytes()
+			e.Response.BodySize = len(body)
+			e.Response.Content.Size = len(body)
+			e.Response.Content.Text, e.Response.Content.Encoding = text(item.Response, body)
+		}
+	}
+
+	return e
+}
+
+func postData(lc logging.LogContent, body []byte) *PostData {
+	pd := &PostData{MimeType: lc.Header.Get("Content-Type"), Params: []Param{}}
+	pd.Text, pd.Encoding = text(lc, body)
+
+	if lc.ContentType() == "application/x-www-form-urlencoded" {
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			for _, k := range sortedKeys(values) {
+				for _, v := range values[k] {
+					pd.Params = append(pd.Params, Param{Name: k, Value: v})
+				}
+			}
+		}
+	}
+
+	return pd
+}
+
+// text gets textual content verbatim; binary content is base64-encoded.
+func text(lc logging.LogContent, body []byte) (string, string) {
+	textual := lc.ContentType() == "" || lc.ContentType() == "application/x-www-form-urlencoded" || lc.IsTextual()
+	if textual && utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func headers(hdrs http.Header) []NameValue {
+	list := []NameValue{}
+	for _, k := range sortedKeys(hdrs) {
+		for _, v := range hdrs[k] {
+			list = append(list, NameValue{Name: k, Value: v})
+		}
+	}
+	return list
+}
+
+func cookies(cs []*http.Cookie) []Cookie {
+	list := []Cookie{}
+	for _, c := range cs {
+		hc := Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.UTC().Format(time.RFC3339)
+		}
+		list = append(list, hc)
+	}
+	return list
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
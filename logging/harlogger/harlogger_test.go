@@ -0,0 +1,151 @@
+package harlogger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rickb777/expect"
+	"github.com/rickb777/httpclient/body"
+	"github.com/rickb777/httpclient/logging"
+	"github.com/rickb777/httpclient/loggingclient"
+	"github.com/spf13/afero"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2021, 04, 01, 10, 11, 12, 1000000, time.UTC)
+
+func TestArchive_typical_POST_form(t *testing.T) {
+	u, _ := url.Parse("http://somewhere.com/a/b/c?foo=1&bar=2&foo=3")
+	reqHeader := make(http.Header)
+	reqHeader.Set("Content-Type", "application/x-www-form-urlencoded")
+	reqHeader.Set("Cookie", "a=123; b=4556")
+
+	resHeader := make(http.Header)
+	resHeader.Set("Content-Type", "application/json; charset=UTF-8")
+	resHeader.Add("Set-Cookie", "c=789; Path=/; HttpOnly")
+
+	a := New("test.har", afero.NewMemMapFs())
+	a.Logger()(&logging.LogItem{
+		Method:     "POST",
+		URL:        u,
+		StatusCode: 201,
+		Request:    logging.LogContent{Header: reqHeader, Body: body.NewBodyString("x=1&y=hello+world")},
+		Response:   logging.LogContent{Header: resHeader, Body: body.NewBodyString(`{"A":"foo","B":7}`)},
+		Start:      t0,
+		Duration:   1500 * time.Microsecond,
+		Level:      logging.WithHeadersAndBodies,
+	})
+
+	entries := a.Entries()
+	expect.Slice(entries).ToHaveLength(t, 1)
+	e := entries[0]
+	expect.String(e.StartedDateTime).ToBe(t, "2021-04-01T10:11:12.001Z")
+	expect.Number(e.Time).ToBe(t, 1.5)
+	expect.Number(e.Timings.Wait).ToBe(t, 1.5)
+	expect.String(e.Request.URL).ToBe(t, "http://somewhere.com/a/b/c?foo=1&bar=2&foo=3")
+	expect.Slice(e.Request.QueryString).ToBe(t, NameValue{"bar", "2"}, NameValue{"foo", "1"}, NameValue{"foo", "3"})
+	expect.Slice(e.Request.Headers).ToHaveLength(t, 2)
+	expect.Slice(e.Request.Cookies).ToBe(t, Cookie{Name: "a", Value: "123"}, Cookie{Name: "b", Value: "4556"})
+	expect.Number(e.Request.BodySize).ToBe(t, 17)
+	expect.String(e.Request.PostData.Text).ToBe(t, "x=1&y=hello+world")
+	expect.Slice(e.Request.PostData.Params).ToBe(t, Param{"x", "1"}, Param{"y", "hello world"})
+	expect.Number(e.Response.Status).ToBe(t, 201)
+	expect.String(e.Response.StatusText).ToBe(t, "Created")
+	expect.Slice(e.Response.Cookies).ToBe(t, Cookie{Name: "c", Value: "789", Path: "/", HTTPOnly: true})
+	expect.String(e.Response.Content.MimeType).ToBe(t, "application/json; charset=UTF-8")
+	expect.String(e.Response.Content.Text).ToBe(t, `{"A":"foo","B":7}`)
+	expect.String(e.Response.Content.Encoding).ToBe(t, "")
+}
+
+func TestArchive_binary_content_and_errors(t *testing.T) {
+	u, _ := url.Parse("http://somewhere.com/img.png")
+	resHeader := make(http.Header)
+	resHeader.Set("Content-Type", "image/png")
+
+	a := New("test.har", afero.NewMemMapFs())
+	a.Add(&logging.LogItem{
+		Method:     "GET",
+		URL:        u,
+		StatusCode: 200,
+		Request:    logging.LogContent{Header: make(http.Header)},
+		Response:   logging.LogContent{Header: resHeader, Body: body.NewBody([]byte{0x89, 'P', 'N', 'G'})},
+		Start:      t0.Add(time.Second),
+		Level:      logging.WithHeadersAndBodies,
+	})
+	a.Add(&logging.LogItem{
+		Method: "GET",
+		URL:    u,
+		Err:    errors.New("connection refused"),
+		Start:  t0,
+		Level:  logging.Summary,
+	})
+
+	entries := a.Entries()
+	expect.Slice(entries).ToHaveLength(t, 2)
+
+	// the entries are in order of start time
+	expect.String(entries[0].Comment).ToBe(t, "connection refused")
+	expect.Number(entries[0].Response.Status).ToBe(t, 0)
+	expect.Slice(entries[0].Response.Headers).ToBeEmpty(t)
+
+	expect.String(entries[1].Response.Content.Text).ToBe(t, "iVBORw==")
+	expect.String(entries[1].Response.Content.Encoding).ToBe(t, "base64")
+	expect.Number(entries[1].Response.Content.Size).ToBe(t, 4)
+}
+
+func TestArchive_with_loggingclient(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "hello %s", req.URL.Query().Get("n"))
+	}))
+	defer svr.Close()
+
+	fs := afero.NewMemMapFs()
+	a := New("out/test.har", fs)
+	hc := loggingclient.New(http.DefaultClient, a.Logger(), logging.WithHeadersAndBodies)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("POST", fmt.Sprintf("%s/x?n=%d", svr.URL, i), strings.NewReader("data"))
+			res, err := hc.Do(req)
+			expect.Error(err).Not().ToHaveOccurred(t)
+			res.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	expect.Error(a.Close()).Not().ToHaveOccurred(t)
+
+	b, err := afero.ReadFile(fs, "out/test.har")
+	expect.Error(err).Not().ToHaveOccurred(t)
+
+	var doc HAR
+	expect.Error(json.Unmarshal(b, &doc)).Not().ToHaveOccurred(t)
+	expect.String(doc.Log.Version).ToBe(t, "1.2")
+	expect.String(doc.Log.Creator.Name).ToBe(t, "httpclient")
+	expect.Slice(doc.Log.Entries).ToHaveLength(t, 10)
+
+	for _, e := range doc.Log.Entries {
+		expect.String(e.Request.Method).ToBe(t, "POST")
+		expect.String(e.Request.PostData.Text).ToBe(t, "data")
+		expect.Number(e.Response.Status).ToBe(t, 200)
+		expect.String(e.Response.Content.Text).ToBe(t, "hello "+e.Request.QueryString[0].Value)
+	}
+}
+
+func TestArchive_empty(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	expect.Error(New("test.har", fs).Flush()).Not().ToHaveOccurred(t)
+
+	b, _ := afero.ReadFile(fs, "test.har")
+	expect.String(string(b)).ToContain(t, `"entries": []`)
+}
@@ -0,0 +1,483 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/rickb777/httpclient/body"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Redact hides secrets in every log item before it is passed to any logger. It can be
+// altered to suit, or set to nil to disable redaction. The requests and responses themselves
+// are never altered, only what is logged.
+var Redact = NewRedaction()
+
+// Redaction specifies which secrets are hidden in log items. Each hidden value is replaced by a
+// marker that hints at its length, e.g. "[REDACTED:12]". Names are not case-sensitive.
+type Redaction struct {
+	// Headers lists the headers whose values are hidden. For headers ending with "Authorization",
+	// the scheme is kept. For "Cookie" and "Set-Cookie", only the cookie values are hidden.
+	Headers []string
+
+	// QueryParams lists the URL query parameters whose values are hidden.
+	QueryParams []string
+
+	// FormFields lists the fields hidden in "application/x-www-form-urlencoded" bodies.
+	FormFields []string
+
+	// JSONFields lists the values hidden in JSON bodies. Each rule is either a JSON pointer
+	// (RFC-6901), e.g. "/user/password", or a field name that matches at any depth, e.g. "password".
+	// In pointers, "*" matches any field name or array index, e.g. "/users/*/password".
+	// When a value is an object or array, all of it is hidden.
+	JSONFields []string
+
+	// XMLElements lists the elements whose text is hidden in XML bodies, using XPath-like rules
+	// of local names (i.e. ignoring namespaces). A rule starting "/" is an absolute path, e.g.
+	// "/Envelope/Header/Security/Password", whereas a rule starting "//" matches at any depth,
+	// e.g. "//Password". "*" matches any element and "@name" matches an attribute, e.g.
+	// "//Account/@pin".
+	XMLElements []string
+
+	// Mask provides the marker that replaces each hidden value. Nil means [DefaultMask].
+	Mask func(value string) string
+}
+
+// NewRedaction returns a Redaction with sensible defaults.
+func NewRedaction() *Redaction {
+	return &Redaction{
+		Headers:     []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token"},
+		QueryParams: []string{"access_token", "api_key", "apikey", "client_secret", "password", "token", "X-Amz-Security-Token", "X-Amz-Signature"},
+		FormFields:  []string{"password", "client_secret", "access_token", "refresh_token"},
+		JSONFields:  []string{"password", "secret", "client_secret", "access_token", "refresh_token", "id_token"},
+		XMLElements: []string{"//Password"},
+	}
+}
+
+// DefaultMask replaces a value with "[REDACTED:n]" where n is its length in bytes.
+func DefaultMask(value string) string {
+	return fmt.Sprintf("[REDACTED:%d]", len(value))
+}
+
+// Apply replaces the URL, headers and bodies of a log item with redacted copies, where they
+// contain any secrets. r may be nil, in which case nothing is changed.
+func (r *Redaction) Apply(item *LogItem) {
+	if r == nil {
+		return
+	}
+
+	item.URL = r.url(item.URL)
+	item.Request.Header = r.header(item.Request.Header)
+	item.Response.Header = r.header(item.Response.Header)
+	item.Request.Body = r.body(item.Request)
+	item.Response.Body = r.body(item.Response)
+}
+
+func (r *Redaction) mask(value string) string {
+	if r.Mask == nil {
+		return DefaultMask(value)
+	}
+	return r.Mask(value)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func (r *Redaction) header(hdrs http.Header) http.Header {
+	var redacted http.Header
+	for k, values := range hdrs {
+		if !containsFold(r.Headers, k) {
+			continue
+		}
+
+		if redacted == nil {
+			redacted = hdrs.Clone() // the original belongs to the request or response
+		}
+
+		masked := make([]string, len(values))
+		for i, v := range values {
+			masked[i] = r.headerValue(k, v)
+		}
+		redacted[k] = masked
+	}
+
+	if redacted == nil {
+		return hdrs
+	}
+	return redacted
+}
+
+func (r *Redaction) headerValue(name, value string) string {
+	switch {
+	case strings.EqualFold(name, "Cookie"):
+		cookies := strings.Split(value, ";")
+		for i, c := range cookies {
+			cookies[i] = r.cookie(c)
+		}
+		return strings.Join(cookies, ";")
+
+	case strings.EqualFold(name, "Set-Cookie"):
+		cookie, attributes, found := strings.Cut(value, ";")
+		if found {
+			return r.cookie(cookie) + ";" + attributes
+		}
+		return r.cookie(cookie)
+
+	case strings.HasSuffix(strings.ToLower(name), "authorization"):
+		if scheme, credentials, found := strings.Cut(value, " "); found {
+			return scheme + " " + r.mask(credentials)
+		}
+	}
+
+	return r.mask(value)
+}
+
+func (r *Redaction) cookie(c string) string {
+	if name, value, found := strings.Cut(c, "="); found {
+		return name + "=" + r.mask(value)
+	}
+	return c
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func (r *Redaction) url(u *url.URL) *url.URL {
+	if u == nil || u.RawQuery == "" {
+		return u
+	}
+
+	query, changed := r.pairs(u.RawQuery, r.QueryParams)
+	if !changed {
+		return u
+	}
+
+	u2 := *u
+	u2.RawQuery = query
+	return &u2
+}
+
+// pairs redacts a query string or form, preserving the order of its fields.
+func (r *Redaction) pairs(s string, names []string) (string, bool) {
+	changed := false
+	pairs := strings.Split(s, "&")
+	for i, p := range pairs {
+		k, v, found := strings.Cut(p, "=")
+		if key, err := url.QueryUnescape(k); err == nil {
+			k = key
+		}
+
+		if found && containsFold(names, k) {
+			if value, err := url.QueryUnescape(v); err == nil {
+				v = value
+			}
+			pairs[i] = url.QueryEscape(k) + "=" + url.QueryEscape(r.mask(v))
+			changed = true
+		}
+	}
+	return strings.Join(pairs, "&"), changed
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func (r *Redaction) body(lc LogContent) *body.Body {
+	data := lc.Body.Bytes()
+	if len(data) == 0 {
+		return lc.Body
+	}
+
+	var redacted []byte
+	var changed bool
+
+	ct := strings.ToLower(strings.TrimSpace(lc.ContentType()))
+	switch {
+	case ct == "application/x-www-form-urlencoded":
+		var form string
+		form, changed = r.pairs(string(data), r.FormFields)
+		redacted = []byte(form)
+	case ct == "application/json" || strings.HasSuffix(ct, "+json"):
+		redacted, changed = r.json(data)
+	case strings.HasSuffix(ct, "/xml") || strings.HasSuffix(ct, "+xml"):
+		redacted, changed = r.xml(data)
+	}
+
+	if !changed {
+		return lc.Body
+	}
+	return body.NewBody(redacted) // the original may belong to the request or response
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// json rewrites a JSON document, which is made compact in the process. If the document is
+// not valid JSON, it is returned unchanged.
+func (r *Redaction) json(data []byte) ([]byte, bool) {
+	if len(r.JSONFields) == 0 {
+		return data, false
+	}
+
+	j := &jsonRedactor{r: r, dec: json.NewDecoder(bytes.NewReader(data))}
+	j.dec.UseNumber()
+	if err := j.value(nil); err != nil || !j.changed {
+		return data, false
+	}
+	return j.out.Bytes(), true
+}
+
+type jsonRedactor struct {
+	r       *Redaction
+	dec     *json.Decoder
+	out     bytes.Buffer
+	changed bool
+}
+
+func (j *jsonRedactor) value(path []string) error {
+	tok, err := j.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		open, end := byte(t), byte('}')
+		if t == '[' {
+			end = ']'
+		}
+
+		j.out.WriteByte(open)
+		for i := 0; j.dec.More(); i++ {
+			if i > 0 {
+				j.out.WriteByte(',')
+			}
+
+			name := strconv.Itoa(i)
+			if open == '{' {
+				key, err := j.dec.Token()
+				if err != nil {
+					return err
+				}
+				name = key.(string) // object keys are always strings
+				j.write(name)
+				j.out.WriteByte(':')
+			}
+
+			if err := j.member(append(slices.Clip(path), name)); err != nil {
+				return err
+			}
+		}
+
+		if _, err := j.dec.Token(); err != nil {
+			return err
+		}
+		j.out.WriteByte(end)
+
+	case json.Number:
+		j.out.WriteString(t.String())
+	default: // string, bool or nil
+		j.write(t)
+	}
+
+	return nil
+}
+
+func (j *jsonRedactor) member(path []string) error {
+	if !j.r.matchesJSON(path) {
+		return j.value(path)
+	}
+
+	var raw json.RawMessage
+	if err := j.dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	s := string(raw) // e.g. a number
+	if bytes.HasPrefix(raw, []byte{'"'}) {
+		_ = json.Unmarshal(raw, &s)
+	}
+
+	j.write(j.r.mask(s))
+	j.changed = true
+	return nil
+}
+
+func (j *jsonRedactor) write(v any) {
+	b, _ := json.Marshal(v)
+	j.out.Write(b)
+}
+
+func (r *Redaction) matchesJSON(path []string) bool {
+	for _, rule := range r.JSONFields {
+		if !strings.HasPrefix(rule, "/") {
+			if strings.EqualFold(rule, path[len(path)-1]) {
+				return true
+			}
+		} else if segments := strings.Split(rule[1:], "/"); len(segments) == len(path) {
+			for i, seg := range segments {
+				segments[i] = strings.ReplaceAll(strings.ReplaceAll(seg, "~1", "/"), "~0", "~")
+			}
+			if matchSegments(segments, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//-------------------------------------------------------------------------------------------------
+
+type span struct {
+	start, end int64
+	text       string
+}
+
+// xml rewrites the hidden text and attribute values of an XML document, leaving the rest of the
+// document exactly as it was. If the document is not well-formed, it is returned unchanged.
+func (r *Redaction) xml(data []byte) ([]byte, bool) {
+	if len(r.XMLElements) == 0 {
+		return data, false
+	}
+
+	var stack []string
+	var spans []span
+	hidden := 0 // the depth within a hidden element
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		start := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return data, false
+		}
+		end := dec.InputOffset()
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			if hidden > 0 || r.matchesXML(stack) {
+				hidden++
+			}
+
+			values := attributeSpans(data[start:end])
+			for i, a := range t.Attr {
+				if i < len(values) && r.matchesXML(append(slices.Clip(stack), "@"+a.Name.Local)) {
+					spans = append(spans, span{start: start + values[i].start, end: start + values[i].end, text: a.Value})
+				}
+			}
+
+		case xml.EndElement:
+			if hidden > 0 {
+				hidden--
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+
+		case xml.CharData:
+			if hidden > 0 && len(bytes.TrimSpace(t)) > 0 {
+				spans = append(spans, span{start: start, end: end, text: string(t)})
+			}
+		}
+	}
+
+	if len(spans) == 0 {
+		return data, false
+	}
+
+	var out bytes.Buffer
+	var prev int64
+	for _, s := range spans {
+		out.Write(data[prev:s.start])
+		_ = xml.EscapeText(&out, []byte(r.mask(s.text)))
+		prev = s.end
+	}
+	out.Write(data[prev:])
+	return out.Bytes(), true
+}
+
+// attributeSpans finds the positions of the attribute values, excluding their quotes, within a
+// well-formed start tag, in the order the attributes appear.
+func attributeSpans(tag []byte) []span {
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\r' || c == '\n' }
+
+	var spans []span
+	i := 1 // after "<"
+	for i < len(tag) && !isSpace(tag[i]) && tag[i] != '/' && tag[i] != '>' {
+		i++ // the element name
+	}
+
+	for {
+		for i < len(tag) && isSpace(tag[i]) {
+			i++
+		}
+		if i >= len(tag) || tag[i] == '/' || tag[i] == '>' {
+			return spans
+		}
+
+		// the attribute name, "=" and the opening quote
+		for i < len(tag) && tag[i] != '=' {
+			i++
+		}
+		i++
+		for i < len(tag) && isSpace(tag[i]) {
+			i++
+		}
+		if i >= len(tag) {
+			return spans
+		}
+
+		quote := tag[i]
+		end := bytes.IndexByte(tag[i+1:], quote)
+		if end < 0 {
+			return spans
+		}
+		spans = append(spans, span{start: int64(i + 1), end: int64(i + 1 + end)})
+		i += end + 2
+	}
+}
+
+func (r *Redaction) matchesXML(path []string) bool {
+	for _, rule := range r.XMLElements {
+		anywhere := strings.HasPrefix(rule, "//")
+		segments := strings.Split(strings.TrimLeft(rule, "/"), "/")
+		if len(segments) > len(path) || (!anywhere && len(segments) != len(path)) {
+			continue
+		}
+
+		for i, seg := range segments {
+			if prefix, local, found := strings.Cut(seg, ":"); found {
+				segments[i] = local // ignore any namespace prefix
+				if strings.HasPrefix(prefix, "@") {
+					segments[i] = "@" + local
+				}
+			}
+		}
+
+		if matchSegments(segments, path[len(path)-len(segments):]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments compares rule segments with a path; "*" matches any name except an attribute.
+func matchSegments(segments, path []string) bool {
+	for i, seg := range segments {
+		if seg == "*" && !strings.HasPrefix(path[i], "@") {
+			continue
+		}
+		if !strings.EqualFold(seg, path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	return slices.ContainsFunc(list, func(v string) bool { return strings.EqualFold(v, s) })
+}
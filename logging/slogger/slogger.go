@@ -0,0 +1,168 @@
+// Package slogger provides a logger that writes log items using the standard library's
+// structured logger, log/slog. The attributes are the same as those written by zerologger,
+// except that request and response details are in groups "req" and "resp".
+package slogger
+
+import (
+	"context"
+	"fmt"
+	"github.com/rickb777/acceptable/header"
+	bodypkg "github.com/rickb777/httpclient/file"
+	"github.com/rickb777/httpclient/logging"
+	"github.com/rickb777/httpclient/logging/logger"
+	"github.com/rickb777/httpclient/mime"
+	"github.com/spf13/afero"
+	"log"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// DurationAsString enables writing durations as e.g. "21.4ms" instead of using the
+	// handler's representation of slog.Duration (nanoseconds for slog.JSONHandler).
+	DurationAsString = false
+
+	// Message is the message of every log record.
+	Message = "http"
+)
+
+// LogWriter returns a new Logger. Items are logged at slog.LevelInfo, or slog.LevelError
+// if there was an error.
+// The filesystem fs specifies where request and response bodies will be
+// written as files, if enabled by the item's level.
+// If lgr is nil, slog.Default() is used. If fs is nil, the OS filesystem is used.
+func LogWriter(lgr *slog.Logger, fs afero.Fs) logger.Logger {
+	if lgr == nil {
+		lgr = slog.Default()
+	}
+	if fs == nil {
+		fs = afero.NewOsFs()
+	}
+
+	return func(item *logging.LogItem) {
+		level := slog.LevelInfo
+		var attrs []slog.Attr
+
+		if item.Err != nil {
+			level = slog.LevelError
+			attrs = append(attrs, slog.String("error", item.Err.Error()))
+		}
+
+		// basic info
+		attrs = append(attrs,
+			slog.Time("at", item.Start),
+			slog.String("method", item.Method),
+			slog.String("url", item.URL.String()),
+			slog.Int("status", item.StatusCode))
+
+		if DurationAsString {
+			attrs = append(attrs, slog.String("duration", item.Duration.Round(time.Microsecond).String()))
+		} else {
+			attrs = append(attrs, slog.Duration("duration", item.Duration.Round(time.Microsecond)))
+		}
+
+		// verbose info
+		switch item.Level {
+		case logging.WithHeaders:
+			attrs = appendPart(attrs, fs, item.Request.Header, true, "", nil, logger.LongBodyThreshold)
+			attrs = appendPart(attrs, fs, item.Response.Header, false, "", nil, logger.LongBodyThreshold)
+
+		case logging.WithHeadersAndBodies:
+			file := item.FileName()
+			attrs = appendPart(attrs, fs, item.Request.Header, true, file, item.Request.Body.Bytes(), logger.LongBodyThreshold)
+			attrs = appendPart(attrs, fs, item.Response.Header, false, file, item.Response.Body.Bytes(), logger.LongBodyThreshold)
+		}
+
+		lgr.LogAttrs(context.Background(), level, Message, attrs...)
+	}
+}
+
+// appendPart appends a group containing the headers and body, if any.
+func appendPart(attrs []slog.Attr, fs afero.Fs, hdrs http.Header, isRequest bool, file string, body []byte, longBodyThreshold int) []slog.Attr {
+	prefix := ternary(isRequest, "req", "resp")
+
+	var group []any
+	if headers := headerAttrs(hdrs); headers != nil {
+		group = append(group, slog.Group("headers", headers...))
+	}
+
+	if len(body) > 0 {
+		name := fmt.Sprintf("%s_%s", file, prefix)
+		ct := header.ParseContentType(hdrs.Get("Content-Type"))
+		ct.Params = nil
+		if len(body) > longBodyThreshold {
+			extn := mime.FileExtension(ct.String())
+			if extn != "" && writeBodyToFile(fs, name, extn, body) {
+				group = append(group, slog.String("file", name+extn))
+			}
+			group = append(group, slog.Int("body_len", len(body)))
+
+		} else if ct.IsTextual() {
+			// write short body inline
+			group = append(group, slog.String("body", strings.Trim(string(body), "\n")))
+
+		} else {
+			group = append(group, slog.Int("body_len", len(body)))
+		}
+	}
+
+	if len(group) == 0 {
+		return attrs
+	}
+	return append(attrs, slog.Group(prefix, group...))
+}
+
+func writeBodyToFile(fs afero.Fs, name, extn string, body []byte) bool {
+	f, err := fs.Create(name + extn)
+	if err != nil {
+		log.Printf("logger open file error: %s\n", err)
+		return false
+	}
+
+	err = bodypkg.PrettyPrint(extn, f, body)
+	if err != nil {
+		log.Printf("logger transcode error: %s\n", err)
+		return false
+	}
+
+	err = f.Close()
+	if err != nil {
+		log.Printf("logger close error: %s\n", err)
+	}
+
+	return true
+}
+
+func headerAttrs(hdrs http.Header) []any {
+	if len(hdrs) == 0 {
+		return nil
+	}
+
+	var keys []string
+	for k := range hdrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]any, 0, len(keys))
+	for _, k := range keys {
+		vs := hdrs[k]
+		if len(vs) == 1 {
+			attrs = append(attrs, slog.String(k, vs[0]))
+		} else {
+			attrs = append(attrs, slog.Any(k, vs))
+		}
+	}
+
+	return attrs
+}
+
+func ternary(predicate bool, yes, no string) string {
+	if predicate {
+		return yes
+	}
+	return no
+}
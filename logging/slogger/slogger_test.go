@@ -0,0 +1,196 @@
+package slogger
+
+import (
+	"fmt"
+	"github.com/rickb777/expect"
+	"github.com/rickb777/httpclient/body"
+	"github.com/rickb777/httpclient/logging"
+	"github.com/spf13/afero"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const longJSON = `{"alpha":"some text","beta":"some more text","gamma":"this might drag on","delta":"and on past the 80 char threshold"}` + "\n"
+
+var t0 = time.Date(2021, 04, 01, 10, 11, 12, 1000000, time.UTC)
+
+func TestLogWriter_typical_GET_terse(t *testing.T) {
+	u, _ := url.Parse("http://somewhere.com/a/b/c")
+	reqHeader := make(http.Header)
+	reqHeader.Set("Accept", "application/json")
+
+	lgrBuf := &strings.Builder{}
+	log := LogWriter(slog.New(slog.NewJSONHandler(lgrBuf, nil)), afero.NewMemMapFs())
+	log(&logging.LogItem{
+		Method:     "GET",
+		URL:        u,
+		StatusCode: 200,
+		Request:    logging.LogContent{Header: reqHeader},
+		Start:      t0,
+		Duration:   time.Millisecond,
+		Level:      logging.Summary,
+	})
+
+	msg := lgrBuf.String()
+	expect.String(msg).ToContain(t, `"level":"INFO"`)
+	expect.String(msg).ToContain(t, `"msg":"http"`)
+	expect.String(msg).ToContain(t, `"at":"2021-04-01T10:11:12.001Z"`)
+	expect.String(msg).ToContain(t, `"status":200`)
+	expect.String(msg).ToContain(t, `"method":"GET"`)
+	expect.String(msg).ToContain(t, `"url":"http://somewhere.com/a/b/c"`)
+	expect.String(msg).ToContain(t, `"duration":1000000`)
+	expect.String(msg).Not().ToContain(t, `"req":`)
+}
+
+func TestLogWriter_typical_GET_JSON_short_content(t *testing.T) {
+	u, _ := url.Parse("http://somewhere.com/a/b/c?foo=1")
+	reqHeader := make(http.Header)
+	reqHeader.Set("Accept", "application/json")
+	reqHeader.Set("Cookie", "a=123")
+	reqHeader.Add("Cookie", "b=4556")
+
+	resHeader := make(http.Header)
+	resHeader.Set("Content-Type", "application/json; charset=UTF-8")
+	resHeader.Set("Content-Length", "18")
+
+	lgrBuf := &strings.Builder{}
+	log := LogWriter(slog.New(slog.NewJSONHandler(lgrBuf, nil)), afero.NewMemMapFs())
+	log(&logging.LogItem{
+		Method:     "GET",
+		URL:        u,
+		StatusCode: 200,
+		Request:    logging.LogContent{Header: reqHeader},
+		Response: logging.LogContent{
+			Header: resHeader,
+			Body:   body.NewBodyString(`{"A":"foo","B":7}` + "\n"),
+		},
+		Start:    t0,
+		Duration: time.Millisecond,
+		Level:    logging.WithHeadersAndBodies,
+	})
+
+	msg := lgrBuf.String()
+	expect.String(msg).ToContain(t, `"url":"http://somewhere.com/a/b/c?foo=1"`)
+	expect.String(msg).ToContain(t, `"req":{"headers":{"Accept":"application/json","Cookie":["a=123","b=4556"]}}`)
+	expect.String(msg).ToContain(t, `"resp":{"headers":{"Content-Length":"18","Content-Type":"application/json; charset=UTF-8"},"body":"{\"A\":\"foo\",\"B\":7}"}`)
+}
+
+func TestLogWriter_typical_GET_JSON_long_content(t *testing.T) {
+	u, _ := url.Parse("http://somewhere.com/a/b/c?foo=1")
+	reqHeader := make(http.Header)
+	reqHeader.Set("Host", "somewhere.com")
+
+	resHeader := make(http.Header)
+	resHeader.Set("Content-Type", "application/json; charset=UTF-8")
+
+	fs := afero.NewMemMapFs()
+	lgrBuf := &strings.Builder{}
+	log := LogWriter(slog.New(slog.NewJSONHandler(lgrBuf, nil)), fs)
+	log(&logging.LogItem{
+		Method:     "GET",
+		URL:        u,
+		StatusCode: 200,
+		Request:    logging.LogContent{Header: reqHeader},
+		Response: logging.LogContent{
+			Header: resHeader,
+			Body:   body.NewBodyString(longJSON),
+		},
+		Start:    t0,
+		Duration: time.Millisecond,
+		Level:    logging.WithHeadersAndBodies,
+	})
+
+	msg := lgrBuf.String()
+	expect.String(msg).Not().ToContain(t, `"body":`)
+	expect.String(msg).ToContain(t, `"file":"2021-04-01_10-11-12-001_GET_somewhere.com_a_b_c_resp.json"`)
+	expect.String(msg).ToContain(t, `"body_len":119`)
+
+	exists, _ := afero.Exists(fs, "2021-04-01_10-11-12-001_GET_somewhere.com_a_b_c_resp.json")
+	expect.Bool(exists).ToBeTrue(t)
+}
+
+func TestLogWriter_typical_GET_binary(t *testing.T) {
+	u, _ := url.Parse("http://somewhere.com/a/b/c")
+	resHeader := make(http.Header)
+	resHeader.Set("Content-Type", "application/octet-stream")
+
+	lgrBuf := &strings.Builder{}
+	log := LogWriter(slog.New(slog.NewJSONHandler(lgrBuf, nil)), afero.NewMemMapFs())
+	log(&logging.LogItem{
+		Method:     "GET",
+		URL:        u,
+		StatusCode: 200,
+		Response: logging.LogContent{
+			Header: resHeader,
+			Body:   body.NewBodyString("{}\n"),
+		},
+		Start:    t0,
+		Duration: time.Millisecond,
+		Level:    logging.WithHeadersAndBodies,
+	})
+
+	msg := lgrBuf.String()
+	expect.String(msg).Not().ToContain(t, `"body":`)
+	expect.String(msg).ToContain(t, `"resp":{"headers":{"Content-Type":"application/octet-stream"},"body_len":3}`)
+}
+
+func TestLogWriter_typical_PUT_headers_only_with_error(t *testing.T) {
+	u, _ := url.Parse("http://somewhere.com/a/b/c")
+	reqHeader := make(http.Header)
+	reqHeader.Set("Content-Type", "application/json; charset=UTF-8")
+
+	DurationAsString = true
+	defer func() { DurationAsString = false }()
+
+	lgrBuf := &strings.Builder{}
+	log := LogWriter(slog.New(slog.NewJSONHandler(lgrBuf, nil)), afero.NewMemMapFs())
+	log(&logging.LogItem{
+		Method: "PUT",
+		URL:    u,
+		Request: logging.LogContent{
+			Header: reqHeader,
+			Body:   body.NewBodyString(`{"A":"foo","B":7}` + "\n"),
+		},
+		Start:    t0,
+		Duration: 123456,
+		Level:    logging.WithHeaders,
+		Err:      fmt.Errorf(`Bang "!"`),
+	})
+
+	msg := lgrBuf.String()
+	expect.String(msg).ToContain(t, `"level":"ERROR"`)
+	expect.String(msg).ToContain(t, `"error":"Bang \"!\""`)
+	expect.String(msg).ToContain(t, `"status":0`)
+	expect.String(msg).ToContain(t, `"method":"PUT"`)
+	expect.String(msg).ToContain(t, `"duration":"123µs"`)
+	expect.String(msg).ToContain(t, `"req":{"headers":{"Content-Type":"application/json; charset=UTF-8"}}`)
+}
+
+func TestLogWriter_typical_PUT_short_content_text(t *testing.T) {
+	u, _ := url.Parse("http://somewhere.com/a/b/c")
+	reqHeader := make(http.Header)
+	reqHeader.Set("Content-Type", "text/plain")
+
+	lgrBuf := &strings.Builder{}
+	log := LogWriter(slog.New(slog.NewTextHandler(lgrBuf, nil)), afero.NewMemMapFs())
+	log(&logging.LogItem{
+		Method:     "PUT",
+		URL:        u,
+		StatusCode: 204,
+		Request: logging.LogContent{
+			Header: reqHeader,
+			Body:   body.NewBodyString("Sunny day\n"),
+		},
+		Start:    t0,
+		Duration: time.Millisecond,
+		Level:    logging.WithHeadersAndBodies,
+	})
+
+	msg := lgrBuf.String()
+	expect.String(msg).ToContain(t, `level=INFO msg=http at=2021-04-01T10:11:12.001Z method=PUT url=http://somewhere.com/a/b/c status=204 duration=1ms`)
+	expect.String(msg).ToContain(t, `req.headers.Content-Type=text/plain req.body="Sunny day"`)
+}
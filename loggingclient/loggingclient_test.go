@@ -96,6 +96,48 @@ Content-Length: 18
 	}
 }
 
+func TestLoggingClient_200_OK_redacted(t *testing.T) {
+	input := `{"user":"joe","password":"hunter2"}`
+	target := "http://somewhere.com/a/b/c?token=abc"
+
+	logging.Now = stubbedTime()
+	req := httptest.NewRequest("POST", target, strings.NewReader(input))
+	req.Header.Set("Authorization", "Bearer abc.def")
+	req.Header.Set("Content-Type", "application/json")
+	testClient := testhttpclient.New(t).AddLiteralResponse("POST", target,
+		`HTTP/1.1 200 OK
+Content-Type: application/json; charset=UTF-8
+Set-Cookie: session=xyz; Path=/
+Content-Length: 26
+
+{"access_token":"a1b2c3"}
+`)
+	logged := false
+	logger := func(item *logging.LogItem) {
+		logged = true
+		expect.String(item.URL.String()).ToBe(t, "http://somewhere.com/a/b/c?token=%5BREDACTED%3A3%5D")
+		expect.String(item.Request.Header.Get("Authorization")).ToBe(t, "Bearer [REDACTED:7]")
+		expect.String(item.Request.Body.String()).ToBe(t, `{"user":"joe","password":"[REDACTED:7]"}`)
+		expect.String(item.Response.Header.Get("Set-Cookie")).ToBe(t, "session=[REDACTED:3]; Path=/")
+		expect.String(item.Response.Body.String()).ToBe(t, `{"access_token":"[REDACTED:6]"}`)
+	}
+
+	client := New(testClient, logger, logging.WithHeadersAndBodies)
+	res, err := client.Do(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Bool(logged).ToBeTrue(t)
+
+	// the request and response are unchanged
+	expect.String(req.URL.RawQuery).ToBe(t, "token=abc")
+	expect.String(req.Header.Get("Authorization")).ToBe(t, "Bearer abc.def")
+	expect.String(testClient.CapturedBody(0).String()).ToBe(t, input)
+	expect.String(res.Header.Get("Set-Cookie")).ToBe(t, "session=xyz; Path=/")
+	buf := &bytes.Buffer{}
+	buf.ReadFrom(res.Body)
+	expect.String(buf.String()).ToBe(t, `{"access_token":"a1b2c3"}`+"\n")
+}
+
 func TestLoggingClient_200_OK_Levels(t *testing.T) {
 	target := "http://somewhere.com/a/b/c?foo=1&bar=2"
 	req := httptest.NewRequest("GET", target, nil)
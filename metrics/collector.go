@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var _ Registry = &Collector{}
+
+// Collector is the built-in [Registry]. It keeps the metrics in memory and writes them in
+// the Prometheus text exposition format (version 0.0.4), so it can be scraped by Prometheus
+// without needing the Prometheus client library. It is safe for concurrent use.
+type Collector struct {
+	mu       sync.Mutex // guards the following, including the contents of each family
+	families map[string]*family
+}
+
+// NewCollector returns a new, empty Collector.
+func NewCollector() *Collector {
+	return &Collector{families: make(map[string]*family)}
+}
+
+type family struct {
+	c          *Collector
+	name, help string
+	kind       string // "counter", "gauge" or "histogram"
+	labelNames []string
+	buckets    []float64 // upper bounds, ascending
+	series     map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64  // counter or gauge
+	counts      []uint64 // histogram; not cumulative
+	sum         float64
+	count       uint64
+}
+
+// Counter implements [Registry].
+func (c *Collector) Counter(name, help string, labelNames ...string) Counter {
+	return c.family(name, help, "counter", nil, labelNames)
+}
+
+// Gauge implements [Registry].
+func (c *Collector) Gauge(name, help string, labelNames ...string) Gauge {
+	return c.family(name, help, "gauge", nil, labelNames)
+}
+
+// Histogram implements [Registry].
+func (c *Collector) Histogram(name, help string, buckets []float64, labelNames ...string) Histogram {
+	buckets = slices.Clone(buckets)
+	sort.Float64s(buckets)
+	return c.family(name, help, "histogram", buckets, labelNames)
+}
+
+// family gets or creates a family. It panics if an existing family of the same name differs.
+func (c *Collector) family(name, help, kind string, buckets []float64, labelNames []string) *family {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if f, exists := c.families[name]; exists {
+		if f.kind != kind || !slices.Equal(f.labelNames, labelNames) || !slices.Equal(f.buckets, buckets) {
+			panic(fmt.Sprintf("metrics: %s is already registered differently", name))
+		}
+		return f
+	}
+
+	f := &family{
+		c:          c,
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: slices.Clone(labelNames),
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	c.families[name] = f
+	return f
+}
+
+// Add implements [Counter] and [Gauge].
+func (f *family) Add(delta float64, labelValues ...string) {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+	f.get(labelValues).value += delta
+}
+
+// Observe implements [Histogram].
+func (f *family) Observe(value float64, labelValues ...string) {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+
+	s := f.get(labelValues)
+	if i := sort.SearchFloat64s(f.buckets, value); i < len(f.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+// get gets or creates a series; f.c.mu must be held.
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s needs %d label values, not %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, exists := f.series[key]
+	if !exists {
+		s = &series{labelValues: slices.Clone(labelValues), counts: make([]uint64, len(f.buckets))}
+		f.series[key] = s
+	}
+	return s
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// ContentType is the media type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText writes all the metrics in the Prometheus text exposition format, sorted by name
+// and label values.
+func (c *Collector) WriteText(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	names := make([]string, 0, len(c.families))
+	for name := range c.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := c.families[name]
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			s := f.series[k]
+			if f.kind != "histogram" {
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, f.labels(s, ""), formatFloat(s.value))
+				continue
+			}
+
+			var cumulative uint64
+			for i, bound := range f.buckets {
+				cumulative += s.counts[i]
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, f.labels(s, formatFloat(bound)), cumulative)
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, f.labels(s, "+Inf"), s.count)
+			fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, f.labels(s, ""), formatFloat(s.sum))
+			fmt.Fprintf(bw, "%s_count%s %d\n", f.name, f.labels(s, ""), s.count)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics to Prometheus or similar scrapers.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	if req.Method == http.MethodGet {
+		_ = c.WriteText(w)
+	}
+}
+
+// labels formats the labels of a series, with the "le" label if bound is not blank.
+func (f *family) labels(s *series, bound string) string {
+	if len(f.labelNames) == 0 && bound == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range f.labelNames {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, escapeLabel(s.labelValues[i]))
+	}
+	if bound != "" {
+		if len(f.labelNames) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `le="%s"`, bound)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, +1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
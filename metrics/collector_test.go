@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestCollector_WriteText(t *testing.T) {
+	c := NewCollector()
+	requests := c.Counter("app_requests_total", "Total requests.\nAll of them.", "method", "path")
+	inFlight := c.Gauge("app_in_flight", `Requests in flight (C:\).`)
+	latency := c.Histogram("app_latency_seconds", "Latency.", []float64{1, 0.5}, "method")
+
+	requests.Add(1, "GET", "/a")
+	requests.Add(2, "GET", "/a")
+	requests.Add(1, "POST", `/"quoted"\`)
+	inFlight.Add(1)
+	inFlight.Add(-1)
+	latency.Observe(0.5, "GET")
+	latency.Observe(0.75, "GET")
+	latency.Observe(3, "GET")
+
+	buf := &strings.Builder{}
+	err := c.WriteText(buf)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(buf.String()).ToBe(t, `# HELP app_in_flight Requests in flight (C:\\).
+# TYPE app_in_flight gauge
+app_in_flight 0
+# HELP app_latency_seconds Latency.
+# TYPE app_latency_seconds histogram
+app_latency_seconds_bucket{method="GET",le="0.5"} 1
+app_latency_seconds_bucket{method="GET",le="1"} 2
+app_latency_seconds_bucket{method="GET",le="+Inf"} 3
+app_latency_seconds_sum{method="GET"} 4.25
+app_latency_seconds_count{method="GET"} 3
+# HELP app_requests_total Total requests.\nAll of them.
+# TYPE app_requests_total counter
+app_requests_total{method="GET",path="/a"} 3
+app_requests_total{method="POST",path="/\"quoted\"\\"} 1
+`)
+}
+
+func TestCollector_same_instrument(t *testing.T) {
+	c := NewCollector()
+	c.Counter("n_total", "N.", "a").Add(1, "x")
+	c.Counter("n_total", "N.", "a").Add(1, "x")
+
+	buf := &strings.Builder{}
+	_ = c.WriteText(buf)
+	expect.String(buf.String()).ToContain(t, `n_total{a="x"} 2`)
+}
+
+func TestCollector_misuse(t *testing.T) {
+	c := NewCollector()
+	c.Counter("n_total", "N.", "a")
+
+	expect.Bool(panics(func() { c.Gauge("n_total", "N.", "a") })).ToBeTrue(t)
+	expect.Bool(panics(func() { c.Counter("n_total", "N.", "b") })).ToBeTrue(t)
+	expect.Bool(panics(func() { c.Counter("n_total", "N.", "a").Add(1) })).ToBeTrue(t)
+}
+
+func TestCollector_ServeHTTP(t *testing.T) {
+	c := NewCollector()
+	c.Gauge("g", "G.").Add(math.Inf(1))
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	expect.Number(w.Code).ToBe(t, http.StatusOK)
+	expect.String(w.Header().Get("Content-Type")).ToBe(t, ContentType)
+	expect.String(w.Body.String()).ToContain(t, "g +Inf\n")
+
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+
+	expect.Number(w.Code).ToBe(t, http.StatusMethodNotAllowed)
+}
+
+func panics(fn func()) (panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+	fn()
+	return false
+}
@@ -0,0 +1,253 @@
+// Package metrics provides an HttpClient decorator and a http.RoundTripper decorator that
+// record client-side metrics: request counts, latencies, requests in flight, and request
+// and response sizes. These are labelled by method, host, route template and status class.
+//
+// The metrics are kept in a [Registry]. The built-in [Collector] serves them in the
+// Prometheus text exposition format; other registries can be adapted to the interface.
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rickb777/httpclient"
+)
+
+// Registry creates or finds the named instruments. Each label value passed to an instrument
+// corresponds to one of its label names, in order. Asking for an instrument that already
+// exists returns the existing instrument.
+type Registry interface {
+	Counter(name, help string, labelNames ...string) Counter
+	Gauge(name, help string, labelNames ...string) Gauge
+	Histogram(name, help string, buckets []float64, labelNames ...string) Histogram
+}
+
+// Counter is a value that only increases.
+type Counter interface {
+	Add(delta float64, labelValues ...string)
+}
+
+// Gauge is a value that increases and decreases.
+type Gauge interface {
+	Add(delta float64, labelValues ...string)
+}
+
+// Histogram counts observations in buckets.
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+var (
+	// DefaultDurationBuckets are the upper bounds of the latency histogram buckets, in seconds.
+	DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+	// DefaultSizeBuckets are the upper bounds of the size histogram buckets, in bytes.
+	DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7, 1e8}
+)
+
+// Config configures the metrics. Only the registry is required.
+type Config struct {
+	Registry Registry
+
+	// Namespace is the prefix of the metric names. Zero means "httpclient".
+	Namespace string
+
+	// Route gets the route template of a request, e.g. "/users/{id}", which is used instead of
+	// the path so that the number of label values stays small. Nil means [RouteOf].
+	Route func(req *http.Request) string
+
+	// The histogram buckets. Zero means DefaultDurationBuckets and DefaultSizeBuckets respectively.
+	DurationBuckets []float64
+	SizeBuckets     []float64
+}
+
+type routeKey struct{}
+
+// WithRoute returns a context that specifies the route template for requests made with it,
+// e.g. "/users/{id}".
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// RouteOf gets the route template from the request context (see [WithRoute]), if any.
+func RouteOf(req *http.Request) string {
+	route, _ := req.Context().Value(routeKey{}).(string)
+	return route
+}
+
+// now provides the current time. It can be stubbed for testing.
+var now = time.Now
+
+//-------------------------------------------------------------------------------------------------
+
+type instruments struct {
+	route        func(req *http.Request) string
+	requests     Counter
+	duration     Histogram
+	inFlight     Gauge
+	requestSize  Histogram
+	responseSize Histogram
+}
+
+func newInstruments(cfg Config) *instruments {
+	if cfg.Registry == nil {
+		panic("metrics: incorrect setup")
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = "httpclient"
+	}
+	if cfg.Route == nil {
+		cfg.Route = RouteOf
+	}
+	if len(cfg.DurationBuckets) == 0 {
+		cfg.DurationBuckets = DefaultDurationBuckets
+	}
+	if len(cfg.SizeBuckets) == 0 {
+		cfg.SizeBuckets = DefaultSizeBuckets
+	}
+
+	r, ns := cfg.Registry, cfg.Namespace
+	return &instruments{
+		route:        cfg.Route,
+		requests:     r.Counter(ns+"_requests_total", "Total number of requests.", "method", "host", "route", "status_class"),
+		duration:     r.Histogram(ns+"_request_duration_seconds", "Time until the response headers were received.", cfg.DurationBuckets, "method", "host", "route", "status_class"),
+		inFlight:     r.Gauge(ns+"_requests_in_flight", "Number of requests awaiting a response.", "method", "host", "route"),
+		requestSize:  r.Histogram(ns+"_request_size_bytes", "Size of the request entities.", cfg.SizeBuckets, "method", "host", "route", "status_class"),
+		responseSize: r.Histogram(ns+"_response_size_bytes", "Size of the response entities.", cfg.SizeBuckets, "method", "host", "route", "status_class"),
+	}
+}
+
+// measure sends a request and records its metrics. When the response size is not known in
+// advance, it is recorded when the response body has been read or closed.
+func (in *instruments) measure(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	method, host, route := req.Method, req.URL.Host, in.route(req)
+
+	in.inFlight.Add(1, method, host, route)
+	start := now()
+	res, err := send(req)
+	elapsed := now().Sub(start)
+	in.inFlight.Add(-1, method, host, route)
+
+	class := statusClass(res, err)
+	in.requests.Add(1, method, host, route, class)
+	in.duration.Observe(elapsed.Seconds(), method, host, route, class)
+	if req.ContentLength >= 0 {
+		in.requestSize.Observe(float64(req.ContentLength), method, host, route, class)
+	}
+
+	if err != nil {
+		return res, err
+	}
+
+	responseSize := func(n int64) {
+		in.responseSize.Observe(float64(n), method, host, route, class)
+	}
+
+	switch {
+	case res.ContentLength >= 0:
+		responseSize(res.ContentLength)
+	case res.Body == nil || res.Body == http.NoBody:
+		responseSize(0)
+	default:
+		res.Body = &countingBody{ReadCloser: res.Body, done: responseSize}
+	}
+
+	return res, nil
+}
+
+// statusClass gets e.g. "2xx", or "error" if there was no response.
+func statusClass(res *http.Response, err error) string {
+	if err != nil || res == nil {
+		return "error"
+	}
+	return strconv.Itoa(res.StatusCode/100) + "xx"
+}
+
+// countingBody counts the bytes read, reporting the total at EOF or when closed.
+type countingBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(n int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.once.Do(func() { b.done(b.n) })
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.once.Do(func() { b.done(b.n) })
+	return b.ReadCloser.Close()
+}
+
+//-------------------------------------------------------------------------------------------------
+
+type metricsClient struct {
+	upstream    httpclient.HttpClient
+	instruments *instruments
+}
+
+// New wraps an upstream client and records metrics for all requests made to it.
+// Each request is measured separately, including requests that are repeated to meet
+// authentication challenges or to retry after failures.
+func New(upstream httpclient.HttpClient, cfg Config) httpclient.HttpClient {
+	if upstream == nil {
+		panic("metrics: incorrect setup")
+	}
+	return &metricsClient{upstream: upstream, instruments: newInstruments(cfg)}
+}
+
+// SetCheckRedirect provides access to the http.Client.CheckRedirect field.
+func (mc *metricsClient) SetCheckRedirect(fn func(req *http.Request, via []*http.Request) error) {
+	if hc, ok := mc.upstream.(*http.Client); ok {
+		hc.CheckRedirect = fn
+	} else if cr, ok := mc.upstream.(httpclient.ControlledRedirectClient); ok {
+		cr.SetCheckRedirect(fn)
+	}
+}
+
+func (mc *metricsClient) Do(req *http.Request) (*http.Response, error) {
+	return mc.instruments.measure(req, mc.upstream.Do)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+type metricsTransport struct {
+	upstream    http.RoundTripper
+	instruments *instruments
+}
+
+// WrapTransport wraps the transport of a client so that metrics are recorded for all
+// requests, including each redirect. See [NewTransport].
+func WrapTransport(client *http.Client, cfg Config) *http.Client {
+	upstream := http.DefaultTransport
+	if client.Transport != nil {
+		upstream = client.Transport
+	}
+
+	client.Transport = NewTransport(upstream, cfg)
+	return client
+}
+
+// NewTransport wraps an upstream transport and records metrics for all requests made. This is
+// similar to [New], except that each redirect followed by http.Client is measured separately.
+func NewTransport(upstream http.RoundTripper, cfg Config) http.RoundTripper {
+	if upstream == nil {
+		panic("metrics: incorrect setup")
+	}
+	return &metricsTransport{upstream: upstream, instruments: newInstruments(cfg)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (mt *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return mt.instruments.measure(req, mt.upstream.RoundTrip)
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestNew_records_metrics(t *testing.T) {
+	now = stubbedTime(150 * time.Millisecond)
+	defer func() { now = time.Now }()
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		w.Write([]byte("hello"))
+	}))
+	defer svr.Close()
+	host := strings.TrimPrefix(svr.URL, "http://")
+
+	c := NewCollector()
+	client := New(&http.Client{}, Config{Registry: c})
+
+	for _, id := range []string{"1", "2"} {
+		ctx := WithRoute(context.Background(), "/users/{id}")
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPut, svr.URL+"/users/"+id, strings.NewReader("input!"))
+		res, err := client.Do(req)
+		expect.Error(err).Not().ToHaveOccurred(t)
+		res.Body.Close()
+	}
+
+	labels := `method="PUT",host="` + host + `",route="/users/{id}"`
+	text := metricsText(c)
+	expect.String(text).ToContain(t, `httpclient_requests_total{`+labels+`,status_class="2xx"} 2`+"\n")
+	expect.String(text).ToContain(t, `httpclient_requests_in_flight{`+labels+`} 0`+"\n")
+	expect.String(text).ToContain(t, `httpclient_request_duration_seconds_bucket{`+labels+`,status_class="2xx",le="0.1"} 0`+"\n")
+	expect.String(text).ToContain(t, `httpclient_request_duration_seconds_bucket{`+labels+`,status_class="2xx",le="0.25"} 2`+"\n")
+	expect.String(text).ToContain(t, `httpclient_request_duration_seconds_sum{`+labels+`,status_class="2xx"} 0.3`+"\n")
+	expect.String(text).ToContain(t, `httpclient_request_size_bytes_sum{`+labels+`,status_class="2xx"} 12`+"\n")
+	expect.String(text).ToContain(t, `httpclient_response_size_bytes_sum{`+labels+`,status_class="2xx"} 10`+"\n")
+	expect.String(text).ToContain(t, `httpclient_response_size_bytes_count{`+labels+`,status_class="2xx"} 2`+"\n")
+}
+
+func TestNew_unknown_response_size(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not "))
+		w.(http.Flusher).Flush() // so the response is chunked
+		w.Write([]byte("found"))
+	}))
+	defer svr.Close()
+
+	c := NewCollector()
+	client := New(&http.Client{}, Config{Registry: c, Namespace: "app", Route: func(*http.Request) string { return "any" }})
+
+	req, _ := http.NewRequest(http.MethodGet, svr.URL+"/x", nil)
+	res, err := client.Do(req)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.ContentLength).ToBe(t, -1)
+	expect.String(metricsText(c)).Not().ToContain(t, "app_response_size_bytes_count")
+
+	b, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	expect.String(string(b)).ToBe(t, "not found")
+
+	text := metricsText(c)
+	expect.String(text).ToContain(t, `route="any",status_class="4xx"} 1`)
+	expect.String(text).ToContain(t, `app_response_size_bytes_sum{method="GET",host="`)
+	expect.String(text).ToContain(t, `status_class="4xx"} 9`+"\n")
+	expect.String(text).ToContain(t, `app_response_size_bytes_count{method="GET",host="`)
+}
+
+func TestNew_network_failure(t *testing.T) {
+	svr := httptest.NewServer(http.NotFoundHandler())
+	svr.Close() // nothing is listening
+
+	c := NewCollector()
+	client := New(&http.Client{}, Config{Registry: c})
+
+	req, _ := http.NewRequest(http.MethodGet, svr.URL, nil)
+	_, err := client.Do(req)
+	expect.Error(err).ToHaveOccurred(t)
+
+	text := metricsText(c)
+	expect.String(text).ToContain(t, `route="",status_class="error"} 1`+"\n")
+	expect.String(text).ToContain(t, `route=""} 0`+"\n") // in flight
+	expect.String(text).Not().ToContain(t, "httpclient_response_size_bytes_count")
+}
+
+func TestWrapTransport_measures_redirects(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/a" {
+			http.Redirect(w, r, "/b", http.StatusFound)
+		}
+	}))
+	defer svr.Close()
+
+	c := NewCollector()
+	client := WrapTransport(&http.Client{}, Config{Registry: c})
+
+	res, err := client.Get(svr.URL + "/a")
+	expect.Error(err).Not().ToHaveOccurred(t)
+	res.Body.Close()
+
+	text := metricsText(c)
+	expect.String(text).ToContain(t, `httpclient_requests_total{method="GET",host="`+strings.TrimPrefix(svr.URL, "http://")+`",route="",status_class="3xx"} 1`)
+	expect.String(text).ToContain(t, `status_class="2xx"} 1`)
+}
+
+func metricsText(c *Collector) string {
+	buf := &strings.Builder{}
+	_ = c.WriteText(buf)
+	return buf.String()
+}
+
+func stubbedTime(step time.Duration) func() time.Time {
+	t := time.Date(2021, 04, 01, 10, 11, 12, 0, time.UTC)
+	return func() time.Time {
+		t = t.Add(step)
+		return t
+	}
+}
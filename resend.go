@@ -0,0 +1,33 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+)
+
+type previousKey struct{}
+
+// WithPrevious returns a context for a request that repeats an earlier request, for example
+// to meet an authentication challenge or to retry after a failure. Decorators such as
+// tracing use this to relate the requests; see [Previous].
+func WithPrevious(ctx context.Context, previous *http.Request) context.Context {
+	return context.WithValue(ctx, previousKey{}, previous)
+}
+
+// Previous gets the earlier request that a request repeats, if any, and the number of times
+// the request has been sent before. This includes redirects followed by http.Client, which sets
+// http.Request.Response, as well as requests whose context was made by [WithPrevious].
+func Previous(req *http.Request) (previous *http.Request, resends int) {
+	if req.Response != nil && req.Response.Request != nil && req.Response.Request != req {
+		previous = req.Response.Request
+	} else if p, ok := req.Context().Value(previousKey{}).(*http.Request); ok && p != req {
+		previous = p
+	}
+
+	if previous == nil {
+		return nil, 0
+	}
+
+	_, n := Previous(previous)
+	return previous, n + 1
+}
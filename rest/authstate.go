@@ -0,0 +1,191 @@
+package rest
+
+import (
+	"net/http"
+	urlpkg "net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	authpkg "github.com/rickb777/httpclient/auth"
+)
+
+// authState holds the client's authenticators. Once a challenge has been met, the resulting
+// authenticator is kept for that protection space, i.e. the origin and realm. So concurrent
+// requests to different origins or realms do not replace each other's authenticators (and
+// Digest nonces).
+//
+// Requests within a known protection space are authenticated preemptively, avoiding the 401
+// round trip. The space includes all the paths at or deeper than the last "/" in the path
+// of each request that was challenged (see RFC-7617 section 2.2).
+type authState struct {
+	mu       sync.Mutex // guards the following
+	initial  authpkg.Authenticator
+	proxy    authpkg.Authenticator
+	provider authpkg.CredentialsProvider
+	spaces   map[string][]*protectionSpace // keyed by origin
+}
+
+type protectionSpace struct {
+	realm string
+	paths []string // each ends with "/"
+	auth  authpkg.Authenticator
+}
+
+// authenticators gets the authenticators for a request: the one for the protection space that
+// contains the URL, if any, otherwise the initial one; also the proxy authenticator.
+func (s *authState) authenticators(u *urlpkg.URL) (space *protectionSpace, auth, proxy authpkg.Authenticator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	space = s.find(u)
+	if space == nil {
+		return nil, s.initial, s.proxy
+	}
+	return space, space.auth, s.proxy
+}
+
+// find gets the protection space with the longest path that contains the URL; s.mu must be held.
+func (s *authState) find(u *urlpkg.URL) *protectionSpace {
+	var found *protectionSpace
+	longest := -1
+	for _, sp := range s.spaces[origin(u)] {
+		for _, p := range sp.paths {
+			if strings.HasPrefix(u.EscapedPath(), p) && len(p) > longest {
+				found, longest = sp, len(p)
+			}
+		}
+	}
+	return found
+}
+
+// challenge meets the "WWW-Authenticate" challenges in a 401 response, or the "Proxy-Authenticate"
+// challenges in a 407 response, to a request sent to u using the authenticator 'used'. If another
+// request has already met the challenge, the authenticator is not changed again. It returns false
+// if the challenge cannot be met.
+func (s *authState) challenge(u *urlpkg.URL, res *http.Response, used authpkg.Authenticator) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if res.StatusCode == http.StatusProxyAuthRequired {
+		if s.proxy != used {
+			return true
+		}
+		return meetChallenge(&s.proxy, authpkg.ParseChallenges(res.Header.Values("Proxy-Authenticate")), "", nil)
+	}
+
+	offered := authpkg.ParseChallenges(res.Header.Values("Www-Authenticate"))
+	dir := u.EscapedPath()[:strings.LastIndexByte(u.EscapedPath(), '/')+1]
+	if dir == "" {
+		dir = "/"
+	}
+
+	// is this a known realm? prefer the space that was used, if any
+	var space *protectionSpace
+	containing := s.find(u)
+	for _, sp := range s.spaces[origin(u)] {
+		if offersRealm(offered, sp.realm) && (space == nil || sp == containing) {
+			space = sp
+		}
+	}
+
+	if space == nil {
+		auth := s.initial
+		if !meetChallenge(&auth, offered, u.Host, s.provider) {
+			return false
+		}
+
+		space = &protectionSpace{auth: auth}
+		for _, c := range offered {
+			if c.Is(auth.Type()) {
+				space.realm = c.Params["realm"]
+				break
+			}
+		}
+
+		if s.spaces == nil {
+			s.spaces = make(map[string][]*protectionSpace)
+		}
+		s.spaces[origin(u)] = append(s.spaces[origin(u)], space)
+	} else if space.auth == used && !meetChallenge(&space.auth, offered, u.Host, s.provider) {
+		return false
+	}
+
+	if s.find(u) != space {
+		// the directory now belongs to this space, not to any other
+		for _, sp := range s.spaces[origin(u)] {
+			sp.paths = slices.DeleteFunc(sp.paths, func(p string) bool { return p == dir })
+		}
+		space.paths = append(space.paths, dir)
+	}
+
+	return true
+}
+
+// deferred is true if an authenticator awaits a challenge to choose its scheme, with credentials
+// from the authenticator or from the provider.
+func (s *authState) deferred(auth authpkg.Authenticator) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return deferred(auth) || (auth.Type() == authpkg.None && s.provider != nil)
+}
+
+func origin(u *urlpkg.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// meetChallenge substitutes an authenticator. The current authenticator handles challenges for its
+// own scheme, such as a stale Digest nonce; otherwise the most preferred registered scheme is chosen
+// (see auth.Register). If there is no user, the credentials are obtained from the provider, if any.
+// It returns false if the challenge cannot be met.
+func meetChallenge(auth *authpkg.Authenticator, offered []authpkg.Challenge, host string, provider authpkg.CredentialsProvider) bool {
+	current := *auth
+
+	if own := authpkg.ChallengesFor(offered, current.Type()); current.Type() != authpkg.None && len(own) > 0 {
+		*auth = current.Challenge(own)
+	} else if current.User() == "" && provider != nil {
+		chosen := authpkg.ChooseCredentials(offered, host, provider)
+		if chosen == nil {
+			return false
+		}
+		*auth = chosen
+	} else if chosen := authpkg.Choose(offered, current.User(), current.Password()); chosen != nil {
+		*auth = chosen
+	} else {
+		return false
+	}
+
+	return true
+}
+
+// retryAuthentication is true if a 401 or 407 response can be met by repeating the request.
+// This includes a request that was authenticated preemptively but belongs to another realm, and
+// a Digest request outside any known protection space, which needs the server's nonce.
+// Proxy challenges are only met when there are proxy credentials.
+func retryAuthentication(res *http.Response, space *protectionSpace, auth, proxyAuth authpkg.Authenticator, depth int) bool {
+	switch res.StatusCode {
+	case http.StatusUnauthorized:
+		return auth.Type() == authpkg.None || staleNonce(res, "Www-Authenticate") || invalidToken(res, auth, depth) ||
+			(space == nil && auth.Type() == "Digest") ||
+			(space != nil && !offersRealm(authpkg.ParseChallenges(res.Header.Values("Www-Authenticate")), space.realm))
+	case http.StatusProxyAuthRequired:
+		return deferred(proxyAuth) || staleNonce(res, "Proxy-Authenticate")
+	}
+	return false
+}
+
+// deferred is true if an authenticator has credentials but awaits a challenge to choose its scheme.
+func deferred(auth authpkg.Authenticator) bool {
+	return auth.Type() == authpkg.None && auth.User() != ""
+}
+
+func offersRealm(offered []authpkg.Challenge, realm string) bool {
+	for _, c := range offered {
+		if c.Params["realm"] == realm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	hdr "github.com/rickb777/acceptable/headername"
+	"github.com/rickb777/expect"
+	"github.com/rickb777/httpclient/auth"
+	"github.com/rickb777/httpclient/internal/mytesting"
+)
+
+func TestAuthState_preemptive_within_protection_space(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Basic realm="WallyWorld"
+
+`).ThenWithBody("HTTP/1.1 204 No Content\n\n").
+		ThenWithBody("HTTP/1.1 204 No Content\n\n").
+		ThenWithBody("HTTP/1.1 204 No Content\n\n")
+
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient),
+		SetAuthentication(auth.Deferred("fred", "password")))
+
+	for _, path := range []string{"/a/b", "/a/c/d", "/x"} {
+		_, err := cl.Get(context.Background(), path)
+		expect.Error(err).Info(path).Not().ToHaveOccurred(t)
+	}
+
+	expect.Slice(testClient.Captured).ToHaveLength(t, 4)
+	expect.String(testClient.Captured[0].Header.Get(hdr.Authorization)).ToBe(t, "")
+	expect.String(testClient.Captured[1].Header.Get(hdr.Authorization)).ToBe(t, "Basic ZnJlZDpwYXNzd29yZA==")
+
+	// "/foo/a/c/d" is deeper than "/foo/a/", so it is authenticated preemptively
+	expect.String(testClient.Captured[2].URL.Path).ToBe(t, "/foo/a/c/d")
+	expect.String(testClient.Captured[2].Header.Get(hdr.Authorization)).ToBe(t, "Basic ZnJlZDpwYXNzd29yZA==")
+
+	// "/foo/x" is outside the protection space
+	expect.String(testClient.Captured[3].URL.Path).ToBe(t, "/foo/x")
+	expect.String(testClient.Captured[3].Header.Get(hdr.Authorization)).ToBe(t, "")
+}
+
+func TestAuthState_preemptive_digest(t *testing.T) {
+	ds := &mytesting.DigestServer{
+		Realm: "test@example.org",
+		Qop:   "auth",
+		Users: map[string]string{"fred": "password"},
+	}
+	svr := httptest.NewServer(ds)
+	defer svr.Close()
+
+	cl := NewClient(svr.URL, SetAuthentication(auth.Deferred("fred", "password")))
+
+	for i := 0; i < 5; i++ {
+		res, err := cl.Get(context.Background(), fmt.Sprintf("/bar/%d", i))
+		expect.Error(err).Info(i).Not().ToHaveOccurred(t)
+		expect.Number(res.StatusCode).Info(i).ToBe(t, http.StatusNoContent)
+	}
+
+	// only the first request was challenged
+	expect.Number(ds.Challenges).ToBe(t, 1)
+	expect.Slice(ds.Authorized).ToHaveLength(t, 5)
+	expect.String(ds.Authorized[4]["nc"]).ToBe(t, "00000005")
+}
+
+func TestAuthState_concurrent_realms(t *testing.T) {
+	one := &mytesting.DigestServer{Realm: "one", Users: map[string]string{"fred": "password"}}
+	two := &mytesting.DigestServer{Realm: "two", Users: map[string]string{"fred": "password"}}
+
+	mux := http.NewServeMux()
+	mux.Handle("/one/", one)
+	mux.Handle("/two/", two)
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	cl := NewClient(svr.URL, SetAuthentication(auth.Deferred("fred", "password")))
+
+	// each request to either realm needs its own nonce
+	get := func(path string) {
+		res, err := cl.Get(context.Background(), path)
+		expect.Error(err).Info(path).Not().ToHaveOccurred(t)
+		expect.Number(res.StatusCode).Info(path).ToBe(t, http.StatusNoContent)
+	}
+
+	get("/one/a")
+	get("/two/a")
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); get(fmt.Sprintf("/one/%d", i)) }()
+		go func() { defer wg.Done(); get(fmt.Sprintf("/two/%d", i)) }()
+	}
+	wg.Wait()
+
+	// each realm was challenged once, not every time the other realm was used
+	expect.Number(one.Challenges).ToBe(t, 1)
+	expect.Number(two.Challenges).ToBe(t, 1)
+	expect.Slice(one.Authorized).ToHaveLength(t, 11)
+	expect.Slice(two.Authorized).ToHaveLength(t, 11)
+}
+
+func TestAuthState_digest_realms_have_separate_nonces(t *testing.T) {
+	one := &mytesting.DigestServer{Realm: "one", Qop: "auth", Users: map[string]string{"fred": "password"}}
+	two := &mytesting.DigestServer{Realm: "two", Qop: "auth", Users: map[string]string{"fred": "password"}}
+
+	mux := http.NewServeMux()
+	mux.Handle("/one/", one)
+	mux.Handle("/two/", two)
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	cl := NewClient(svr.URL, SetAuthentication(auth.Digest("fred", "password")))
+
+	for _, path := range []string{"/one/a", "/two/a", "/one/b", "/two/b", "/one/c"} {
+		res, err := cl.Get(context.Background(), path)
+		expect.Error(err).Info(path).Not().ToHaveOccurred(t)
+		expect.Number(res.StatusCode).Info(path).ToBe(t, http.StatusNoContent)
+	}
+
+	// meeting the challenge from realm "two" did not replace the nonce for realm "one"
+	expect.Number(one.Challenges).ToBe(t, 1)
+	expect.Number(two.Challenges).ToBe(t, 1)
+	expect.Slice(one.Authorized).ToHaveLength(t, 3)
+	expect.String(one.Authorized[2]["nc"]).ToBe(t, "00000003")
+	expect.String(two.Authorized[1]["nc"]).ToBe(t, "00000002")
+	expect.String(one.Authorized[0]["nonce"]).Not().ToBe(t, two.Authorized[0]["nonce"])
+}
+
+func TestAuthState_realm_changes(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Basic realm="one"
+
+`).ThenWithBody("HTTP/1.1 204 No Content\n\n").
+		ThenWithBody(`HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Basic realm="two"
+
+`).ThenWithBody("HTTP/1.1 204 No Content\n\n")
+
+	provider := auth.CredentialsMap{
+		{Realm: "one"}: {User: "alice", Password: "pw1"},
+		{Realm: "two"}: {User: "bob", Password: "pw2"},
+	}
+	cl := NewClient("http://example.test/", SetHttpClient(testClient), SetCredentials(provider))
+
+	_, err := cl.Get(context.Background(), "/a")
+	expect.Error(err).Not().ToHaveOccurred(t)
+
+	// "/b" is assumed to be in realm "one" but the server says otherwise
+	_, err = cl.Get(context.Background(), "/b")
+	expect.Error(err).Not().ToHaveOccurred(t)
+
+	expect.Slice(testClient.Captured).ToHaveLength(t, 4)
+	expect.String(testClient.Captured[2].Header.Get(hdr.Authorization)).ToBe(t, "Basic YWxpY2U6cHcx")
+	expect.String(testClient.Captured[3].Header.Get(hdr.Authorization)).ToBe(t, "Basic Ym9iOnB3Mg==")
+}
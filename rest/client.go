@@ -5,7 +5,6 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"os"
-	"sync"
 
 	"github.com/rickb777/acceptable/contenttype"
 	"github.com/rickb777/acceptable/header"
@@ -33,6 +32,19 @@ type RestClient interface {
 	ClearCookies()
 }
 
+// Patcher performs PATCH requests. It is implemented by the [RestClient] returned by [NewClient];
+// it is separate from RestClient so that other implementations of RestClient need not provide it.
+//
+// The Patch method returns a [Response] containing a buffered body, like Put. The request body
+// would normally be a [MergePatch] or a [JSONPatch]. Combine this with [IfMatch] to avoid lost updates.
+//
+// Use the [Patch] function when the client might not implement Patcher, e.g. a decorated client:
+//
+//	res, err := rest.Patch(ctx, client, "/items/1", rest.MergePatch{Patch: changes})
+type Patcher interface {
+	Patch(ctx context.Context, path string, reqBody any, opts ...ReqOpt) (*Response, error)
+}
+
 // Response holds an HTTP response with the entity in a buffer.
 type Response struct {
 	// StatusCode the HTTP status code
@@ -47,14 +59,15 @@ type Response struct {
 	Request *http.Request
 }
 
+var _ Patcher = &client{}
+
 // client defines our structure
 type client struct {
-	root      string
-	headers   http.Header
-	hc        httpclient.HttpClient
-	authMutex sync.Mutex
-	auth      authpkg.Authenticator
-	cookies   *cookiejar.Jar
+	root    string
+	headers http.Header
+	hc      httpclient.HttpClient
+	auth    authState
+	cookies *cookiejar.Jar
 }
 
 //-------------------------------------------------------------------------------------------------
@@ -65,7 +78,10 @@ func NewClient(uri string, opts ...ClientOpt) RestClient {
 		root:    withoutTrailingSlash(uri),
 		headers: make(http.Header),
 		hc:      http.DefaultClient,
-		auth:    authpkg.Anonymous,
+		auth: authState{
+			initial: authpkg.Anonymous,
+			proxy:   authpkg.Anonymous,
+		},
 	}
 	cl.ClearCookies()
 
@@ -100,9 +116,37 @@ func AddHeader(key, value string) ClientOpt {
 // SetAuthentication sets the authentication credentials and method.
 // Leave the authenticator method blank to allow HTTP challenges to
 // select an appropriate method. Otherwise it should be "basic".
+//
+// Once a challenge has been met, the resulting authenticator is kept for that origin
+// and realm, and later requests to the same protection space are authenticated
+// preemptively, without waiting for another challenge.
 func SetAuthentication(authenticator authpkg.Authenticator) ClientOpt {
 	return func(c RestClient) {
-		c.(*client).auth = authenticator
+		c.(*client).auth.initial = authenticator
+	}
+}
+
+// SetCredentials sets a provider of credentials, which are looked up for the client's host and
+// the scheme and realm of each challenge, e.g. using [authpkg.Netrc]. This is used when no user
+// has been set via [SetAuthentication]. Credentials for other hosts are never sent, unless the
+// provider supplies fallback credentials for any host, as [authpkg.NetrcWithDefault] does.
+func SetCredentials(provider authpkg.CredentialsProvider) ClientOpt {
+	return func(c RestClient) {
+		c.(*client).auth.provider = provider
+	}
+}
+
+// SetProxyAuthentication sets the credentials and method used to authenticate with a proxy,
+// which requires authentication by responding "407 Proxy Authentication Required". As with
+// [SetAuthentication], a deferred authenticator allows the "Proxy-Authenticate" challenge
+// to select an appropriate method; the proxy credentials are then sent in the
+// "Proxy-Authorization" header.
+//
+// The proxy itself is configured in the http.Transport (see [SetHttpClient]). Only plain "http"
+// requests are authenticated this way; for "https", see [http.Transport.ProxyConnectHeader].
+func SetProxyAuthentication(authenticator authpkg.Authenticator) ClientOpt {
+	return func(c RestClient) {
+		c.(*client).auth.proxy = authenticator
 	}
 }
 
@@ -0,0 +1,356 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	hdr "github.com/rickb777/acceptable/headername"
+	"github.com/rickb777/httpclient/rest/temperror"
+	"github.com/spf13/afero"
+)
+
+// DownloadConfig controls [Download] and [DownloadFile]. The zero value downloads using a single
+// request, resuming up to 5 times after network errors.
+type DownloadConfig struct {
+	// Segments, if greater than one, splits the download into this many ranges that are
+	// fetched in parallel. This is only done if the server supports byte ranges and
+	// provides the content length; otherwise a single request is used.
+	Segments int
+
+	// MaxResumptions limits how many times each transfer is resumed after a network error.
+	// Zero means the default, 5; a negative value disables resumption.
+	MaxResumptions int
+
+	// RetryDelay is the pause before the first resumption; it doubles after each attempt.
+	RetryDelay time.Duration
+
+	// Progress, if not nil, is called as the entity is received. Total is -1 if the length is
+	// not known. Calls are never concurrent.
+	Progress func(written, total int64)
+}
+
+// ErrResourceChanged is returned when a resource changes while a segmented download is in
+// progress, so the segments can no longer be combined.
+var ErrResourceChanged = errors.New("resource changed during download")
+
+// DownloadFile downloads the entity at path into a file on the filesystem fs, which is the
+// OS filesystem if nil. Any existing file is overwritten. See [Download].
+func DownloadFile(ctx context.Context, c RestClient, path string, fs afero.Fs, name string, cfg DownloadConfig, opts ...ReqOpt) (*Response, error) {
+	if fs == nil {
+		fs = afero.NewOsFs()
+	}
+
+	f, err := fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	res, total, err := download(ctx, c, path, f, cfg, opts...)
+	if err == nil && total >= 0 {
+		// a restarted download may have left surplus bytes beyond the end
+		err = f.Truncate(total)
+	}
+
+	return res, errors.Join(err, f.Close())
+}
+
+// Download downloads the entity at path using GET requests, writing it to dst.
+//
+// If the connection fails part-way through, the transfer is resumed using a "Range" request. The
+// "If-Range" header is set using the ETag (or Last-Modified) of the first response so that, if the
+// resource changed in the meantime, the download restarts from the beginning. Optionally, the
+// entity is fetched in several parallel segments (see [DownloadConfig]).
+//
+// The response is returned without its entity; its Content-Length header holds the total size
+// written, when known.
+func Download(ctx context.Context, c RestClient, path string, dst io.WriterAt, cfg DownloadConfig, opts ...ReqOpt) (*Response, error) {
+	res, _, err := download(ctx, c, path, dst, cfg, opts...)
+	return res, err
+}
+
+// download implements Download, also returning the total size, which is -1 if not known.
+func download(ctx context.Context, c RestClient, path string, dst io.WriterAt, cfg DownloadConfig, opts ...ReqOpt) (*Response, int64, error) {
+	d := &downloader{
+		c:     c,
+		path:  path,
+		dst:   dst,
+		cfg:   cfg,
+		opts:  opts,
+		total: -1,
+	}
+
+	if d.cfg.MaxResumptions == 0 {
+		d.cfg.MaxResumptions = 5
+	}
+
+	if cfg.Segments > 1 {
+		res, err := c.Head(ctx, path, opts...)
+		if err != nil {
+			return res, -1, err
+		}
+
+		size, err := strconv.ParseInt(res.Header.Get(hdr.ContentLength), 10, 64)
+		if err == nil && size > 0 && strings.EqualFold(res.Header.Get("Accept-Ranges"), "bytes") {
+			d.total = size
+			d.validator = validatorOf(res.Header)
+			return res, d.total, d.segmented(ctx)
+		}
+	}
+
+	res, err := d.fetch(ctx, 0, -1)
+	if res != nil {
+		if d.total >= 0 {
+			res.Header.Set(hdr.ContentLength, strconv.FormatInt(d.total, 10))
+		} else {
+			// the header may hold the length of the last part only
+			res.Header.Del(hdr.ContentLength)
+		}
+	}
+	return res, d.total, err
+}
+
+//-------------------------------------------------------------------------------------------------
+
+type downloader struct {
+	c         RestClient
+	path      string
+	dst       io.WriterAt
+	cfg       DownloadConfig
+	opts      []ReqOpt
+	validator string
+
+	mu      sync.Mutex // guards the following
+	total   int64
+	written int64
+}
+
+func (d *downloader) segmented(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	n := int64(d.cfg.Segments)
+	size := (d.total + n - 1) / n
+
+	errs := make([]error, n)
+	wg := sync.WaitGroup{}
+	for i := int64(0); i < n; i++ {
+		start, end := i*size, min((i+1)*size, d.total)-1
+		if start > end {
+			break
+		}
+		wg.Go(func() {
+			_, errs[i] = d.fetch(ctx, start, end)
+			if errs[i] != nil {
+				cancel()
+			}
+		})
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fetch downloads the range from start to end inclusive; end is -1 for the rest of the entity.
+func (d *downloader) fetch(ctx context.Context, start, end int64) (*Response, error) {
+	offset := start
+	delay := d.cfg.RetryDelay
+
+	for attempt := 0; ; attempt++ {
+		opts := d.opts
+		if offset > 0 || end >= 0 {
+			opts = append(opts[:len(opts):len(opts)], d.rangeHeaders(offset, end))
+		}
+
+		var res *Response
+		httpRes, err := d.c.Request(ctx, http.MethodGet, d.path, nil, opts...)
+		if err == nil {
+			res, err = d.receive(httpRes, start, end, &offset)
+			if err == nil {
+				return res, nil
+			}
+		} else if httpRes != nil {
+			_ = httpRes.Body.Close()
+		}
+
+		if attempt >= d.cfg.MaxResumptions || ctx.Err() != nil ||
+			!(temperror.NetworkConnectionError(err) || temperror.InterruptedTransferError(err)) {
+			return res, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (d *downloader) rangeHeaders(offset, end int64) ReqOpt {
+	return func(req *http.Request) {
+		if end >= 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, end))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		if d.validator != "" {
+			req.Header.Set("If-Range", d.validator)
+		}
+	}
+}
+
+// receive copies one response entity to the destination, advancing the offset.
+func (d *downloader) receive(res *http.Response, start, end int64, offset *int64) (*Response, error) {
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		if *offset > start || end >= 0 {
+			if d.cfg.Segments > 1 {
+				return nil, ErrResourceChanged
+			}
+			d.addProgress(-*offset) // start again from the beginning
+			*offset = 0
+		}
+		d.validator = validatorOf(res.Header)
+		d.setTotal(res.ContentLength)
+
+	case http.StatusPartialContent:
+		first, total, ok := parseContentRange(res.Header.Get("Content-Range"))
+		if !ok || first != *offset {
+			return nil, fmt.Errorf("%s: unexpected Content-Range %q", d.path, res.Header.Get("Content-Range"))
+		}
+		d.setTotal(total)
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		_, total, _ := parseContentRange(res.Header.Get("Content-Range"))
+		if total < 0 || *offset != total {
+			return responseOf(res, nil)
+		}
+		// there was nothing left to fetch; the entity is an error message
+		d.setTotal(total)
+		r, _ := copyResponse(res, nil)
+		r.Body = nil
+		return &r, nil
+
+	default:
+		return responseOf(res, nil)
+	}
+
+	w := &progressWriter{w: io.NewOffsetWriter(d.dst, *offset), d: d}
+	n, err := io.Copy(w, res.Body)
+	*offset += n
+	if err != nil {
+		return nil, err
+	}
+
+	if expected := d.expectedEnd(end); expected >= 0 && *offset < expected {
+		return nil, io.ErrUnexpectedEOF // the connection was closed early
+	}
+
+	if end < 0 {
+		d.setTotal(*offset) // the whole entity has now been received
+	}
+
+	r, _ := copyResponse(res, nil)
+	r.Body = nil
+	return &r, nil
+}
+
+// expectedEnd is the offset at which the range should finish, or -1 if unknown.
+func (d *downloader) expectedEnd(end int64) int64 {
+	if end >= 0 {
+		return end + 1
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.total
+}
+
+func (d *downloader) setTotal(total int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if total >= 0 {
+		d.total = total
+	}
+}
+
+func (d *downloader) addProgress(n int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.written += n
+	if d.cfg.Progress != nil && n != 0 {
+		d.cfg.Progress(d.written, d.total)
+	}
+}
+
+type progressWriter struct {
+	w io.Writer
+	d *downloader
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.d.addProgress(int64(n))
+	return n, err
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// validatorOf gets the strong ETag, or else the Last-Modified date, for use with If-Range.
+func validatorOf(h http.Header) string {
+	etag := h.Get("ETag")
+	if etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	if lm := h.Get("Last-Modified"); lm != "" {
+		if _, err := http.ParseTime(lm); err == nil {
+			return lm
+		}
+	}
+	return ""
+}
+
+// parseContentRange parses "bytes first-last/total", in which total may be "*" (unknown),
+// or "bytes */total". Unknown values are -1.
+func parseContentRange(s string) (first, total int64, ok bool) {
+	unit, spec, found := strings.Cut(s, " ")
+	if !found || unit != "bytes" {
+		return -1, -1, false
+	}
+
+	rng, size, found := strings.Cut(spec, "/")
+	if !found {
+		return -1, -1, false
+	}
+
+	total = -1
+	if size != "*" {
+		var err error
+		total, err = strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return -1, -1, false
+		}
+	}
+
+	if rng == "*" {
+		return -1, total, true
+	}
+
+	f, _, _ := strings.Cut(rng, "-")
+	first, err := strconv.ParseInt(f, 10, 64)
+	return first, total, err == nil
+}
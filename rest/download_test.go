@@ -0,0 +1,253 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	hdr "github.com/rickb777/acceptable/headername"
+	"github.com/rickb777/expect"
+	"github.com/spf13/afero"
+)
+
+var downloadContent = bytes.Repeat([]byte("0123456789"), 1000)
+
+// downloadServer serves downloadContent, dropping the connection part-way through the first response.
+// It records the Range and If-Range headers it receives.
+func downloadServer(etags ...string) (*httptest.Server, func() []string) {
+	mu := sync.Mutex{}
+	var requests []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+		n := len(requests)
+		mu.Unlock()
+
+		w.Header().Set("ETag", etags[min(n, len(etags))-1])
+
+		if n == 1 && r.Method == http.MethodGet {
+			w.Header().Set(hdr.ContentLength, "10000")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(downloadContent[:4000])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(downloadContent))
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(requests)
+	}
+}
+
+func TestDownload_resumes_after_connection_drop(t *testing.T) {
+	server, requests := downloadServer(`"v1"`)
+	defer server.Close()
+
+	cl := NewClient(server.URL)
+	fs := afero.NewMemMapFs()
+	var progress []int64
+	cfg := DownloadConfig{Progress: func(written, total int64) {
+		progress = append(progress, written)
+		expect.Number(total).ToBe(t, 10000)
+	}}
+
+	res, err := DownloadFile(context.Background(), cl, "/data.bin", fs, "data.bin", cfg)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusPartialContent)
+	expect.String(res.Header.Get(hdr.ContentLength)).ToBe(t, "10000")
+	b, err := afero.ReadFile(fs, "data.bin")
+	expect.String(b, err).ToEqual(t, string(downloadContent))
+	expect.Slice(requests()).ToBe(t, "GET  ", `GET bytes=4000- "v1"`)
+	expect.Number(progress[len(progress)-1]).ToBe(t, 10000)
+}
+
+func TestDownload_restarts_when_resource_changed(t *testing.T) {
+	server, requests := downloadServer(`"v1"`, `"v2"`)
+	defer server.Close()
+
+	cl := NewClient(server.URL)
+	fs := afero.NewMemMapFs()
+	expect.Error(afero.WriteFile(fs, "data.bin", bytes.Repeat([]byte("x"), 20000), 0644)).Not().ToHaveOccurred(t)
+
+	res, err := DownloadFile(context.Background(), cl, "/data.bin", fs, "data.bin", DownloadConfig{})
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusOK)
+	b, err := afero.ReadFile(fs, "data.bin")
+	expect.String(b, err).ToEqual(t, string(downloadContent))
+	expect.Slice(requests()).ToBe(t, "GET  ", `GET bytes=4000- "v1"`)
+}
+
+func TestDownload_segmented(t *testing.T) {
+	mu := sync.Mutex{}
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Method+" "+r.Header.Get("Range"))
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(downloadContent))
+	}))
+	defer server.Close()
+
+	cl := NewClient(server.URL)
+	fs := afero.NewMemMapFs()
+
+	_, err := DownloadFile(context.Background(), cl, "/data.bin", fs, "data.bin", DownloadConfig{Segments: 3})
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	b, err := afero.ReadFile(fs, "data.bin")
+	expect.String(b, err).ToEqual(t, string(downloadContent))
+	slices.Sort(ranges)
+	expect.Slice(ranges).ToBe(t, "GET bytes=0-3333", "GET bytes=3334-6667", "GET bytes=6668-9999", "HEAD ")
+}
+
+func TestDownload_not_found(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	cl := NewClient(server.URL)
+	buf := &writerAtBuffer{}
+
+	res, err := Download(context.Background(), cl, "/data.bin", buf, DownloadConfig{})
+
+	expect.Error(err).ToContain(t, "404")
+	expect.Number(res.StatusCode).ToBe(t, http.StatusNotFound)
+}
+
+func TestDownload_resumes_when_length_unknown(t *testing.T) {
+	n := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.Header().Set("ETag", `"v1"`)
+		if n == 1 {
+			// chunked, so the length is not known
+			_, _ = w.Write(downloadContent[:4000])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+		w.Header().Set("Content-Range", "bytes 4000-9999/*")
+		w.Header().Set(hdr.ContentLength, "6000")
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(downloadContent[4000:])
+	}))
+	defer server.Close()
+
+	cl := NewClient(server.URL)
+	fs := afero.NewMemMapFs()
+
+	res, err := DownloadFile(context.Background(), cl, "/data.bin", fs, "data.bin", DownloadConfig{})
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusPartialContent)
+	expect.String(res.Header.Get(hdr.ContentLength)).ToBe(t, "10000")
+	b, err := afero.ReadFile(fs, "data.bin")
+	expect.String(b, err).ToEqual(t, string(downloadContent))
+}
+
+func TestDownload_resumes_when_already_complete(t *testing.T) {
+	n := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.Header().Set("ETag", `"v1"`)
+		if n == 1 {
+			// chunked, then dropped after the whole entity was sent
+			_, _ = w.Write(downloadContent)
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(downloadContent))
+	}))
+	defer server.Close()
+
+	cl := NewClient(server.URL)
+	fs := afero.NewMemMapFs()
+
+	res, err := DownloadFile(context.Background(), cl, "/data.bin", fs, "data.bin", DownloadConfig{})
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusRequestedRangeNotSatisfiable)
+	expect.String(res.Header.Get(hdr.ContentLength)).ToBe(t, "10000")
+	expect.Number(n).ToBe(t, 2)
+	b, err := afero.ReadFile(fs, "data.bin")
+	expect.String(b, err).ToEqual(t, string(downloadContent))
+}
+
+func TestDownload_closes_body_after_error(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader("denied")}
+	cl := failingRestClient{res: &http.Response{StatusCode: http.StatusUnauthorized, Body: body}, err: errors.New("401")}
+
+	_, err := Download(context.Background(), cl, "/data.bin", &writerAtBuffer{}, DownloadConfig{})
+
+	expect.Error(err).ToContain(t, "401")
+	expect.Bool(body.closed).ToBeTrue(t)
+}
+
+func TestParseContentRange(t *testing.T) {
+	cases := map[string][3]int64{
+		"bytes 10-19/100": {10, 100, 1},
+		"bytes 10-19/*":   {10, -1, 1},
+		"bytes */100":     {-1, 100, 1},
+		"items 1-2/3":     {-1, -1, 0},
+		"bytes 1-2":       {-1, -1, 0},
+	}
+
+	for s, exp := range cases {
+		first, total, ok := parseContentRange(s)
+		expect.Number(first).I(s).ToBe(t, exp[0])
+		expect.Number(total).I(s).ToBe(t, exp[1])
+		expect.Bool(ok).I(s).ToBe(t, exp[2] == 1)
+	}
+}
+
+type writerAtBuffer struct {
+	b []byte
+}
+
+func (w *writerAtBuffer) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(w.b) {
+		w.b = append(w.b, make([]byte, end-len(w.b))...)
+	}
+	return copy(w.b[off:], p), nil
+}
+
+// failingRestClient is a RestClient whose requests fail, though with a response.
+type failingRestClient struct {
+	RestClient
+	res *http.Response
+	err error
+}
+
+func (c failingRestClient) Request(context.Context, string, string, any, ...ReqOpt) (*http.Response, error) {
+	return c.res, c.err
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
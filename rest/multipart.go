@@ -0,0 +1,202 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Multipart is a request entity for "multipart/form-data" (see RFC-7578), typically used for
+// uploading files along with form fields. Build it using [NewMultipart] and the Add methods.
+//
+// The parts are streamed rather than buffered in memory. The Content-Length is sent if the size
+// of every part is known. The entity can be sent again after an authentication challenge as long
+// as every part can be re-read, which is the case for fields, files added by name, and readers
+// that are [io.ReaderAt] of known size. See also [Stream].
+type Multipart struct {
+	boundary string
+	parts    []multipartPart
+	err      error
+}
+
+type multipartPart struct {
+	header   textproto.MIMEHeader
+	size     int64 // -1 if unknown
+	open     func() (io.ReadCloser, error)
+	reusable bool
+}
+
+// NewMultipart creates an empty multipart/form-data entity with a random boundary.
+func NewMultipart() *Multipart {
+	return &Multipart{boundary: multipart.NewWriter(io.Discard).Boundary()}
+}
+
+// ContentType returns the Content-Type header value, which includes the boundary parameter.
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// AddField adds a form field.
+func (m *Multipart) AddField(name, value string) *Multipart {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(name)))
+	return m.add(h, strings.NewReader(value))
+}
+
+// AddFile adds a part containing the file with the given path; the filename in the part is the
+// base name of the path. The content type is determined from the file extension. The file is
+// opened each time the entity is sent.
+func (m *Multipart) AddFile(fieldName, path string) *Multipart {
+	info, err := os.Stat(path)
+	if err != nil {
+		m.err = err
+		return m
+	}
+
+	h := fileHeader(fieldName, filepath.Base(path), "")
+	m.parts = append(m.parts, multipartPart{
+		header:   h,
+		size:     info.Size(),
+		open:     func() (io.ReadCloser, error) { return os.Open(path) },
+		reusable: true,
+	})
+	return m
+}
+
+// AddReader adds a part containing a file supplied by a reader. If contentType is blank, it is
+// determined from the fileName extension where possible, or "application/octet-stream" otherwise.
+func (m *Multipart) AddReader(fieldName, fileName, contentType string, rdr io.Reader) *Multipart {
+	return m.add(fileHeader(fieldName, fileName, contentType), rdr)
+}
+
+// AddPart adds a part with arbitrary headers, which should include Content-Disposition.
+func (m *Multipart) AddPart(header textproto.MIMEHeader, rdr io.Reader) *Multipart {
+	return m.add(header, rdr)
+}
+
+func (m *Multipart) add(h textproto.MIMEHeader, rdr io.Reader) *Multipart {
+	if ra, offset, size, ok := readerAtSize(rdr); ok {
+		m.parts = append(m.parts, multipartPart{header: h, size: size, open: sectionGetter(ra, offset, size), reusable: true})
+		return m
+	}
+
+	m.parts = append(m.parts, multipartPart{
+		header: h,
+		size:   -1,
+		open: func() (io.ReadCloser, error) {
+			return io.NopCloser(rdr), nil
+		},
+	})
+	return m
+}
+
+func fileHeader(fieldName, fileName, contentType string) textproto.MIMEHeader {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(fileName))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		escapeQuotes(fieldName), escapeQuotes(fileName)))
+	h.Set("Content-Type", contentType)
+	return h
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func (m *Multipart) prepare() (*streamEntity, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	reusable := true
+	for _, p := range m.parts {
+		reusable = reusable && p.reusable
+	}
+
+	if reusable {
+		return &streamEntity{length: m.length(), getBody: m.open}, nil
+	}
+
+	rdr, err := m.open()
+	return &streamEntity{first: rdr, length: m.length()}, err
+}
+
+// length computes the total size of the entity, or -1 if any part has unknown size.
+func (m *Multipart) length() int64 {
+	counter := &countingWriter{}
+	mw := multipart.NewWriter(counter)
+	_ = mw.SetBoundary(m.boundary)
+
+	var n int64
+	for _, p := range m.parts {
+		if p.size < 0 {
+			return -1
+		}
+		_, _ = mw.CreatePart(p.header)
+		n += p.size
+	}
+	_ = mw.Close()
+
+	return n + counter.n
+}
+
+// open streams the parts through a pipe.
+func (m *Multipart) open() (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+
+	go func() {
+		mw := multipart.NewWriter(pw)
+		_ = mw.SetBoundary(m.boundary)
+
+		for _, p := range m.parts {
+			if err := writePart(mw, p); err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+
+		_ = pw.CloseWithError(mw.Close())
+	}()
+
+	return pr, nil
+}
+
+func writePart(mw *multipart.Writer, p multipartPart) error {
+	w, err := mw.CreatePart(p.header)
+	if err != nil {
+		return err
+	}
+
+	rdr, err := p.open()
+	if err != nil {
+		return err
+	}
+	defer rdr.Close()
+
+	_, err = io.Copy(w, rdr)
+	return err
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += int64(len(p))
+	return len(p), nil
+}
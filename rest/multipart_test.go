@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	hdr "github.com/rickb777/acceptable/headername"
+	"github.com/rickb777/expect"
+	"github.com/rickb777/httpclient/auth"
+	bodypkg "github.com/rickb777/httpclient/body"
+	"github.com/rickb777/httpclient/internal/mytesting"
+)
+
+func TestMultipart_replayed_after_challenge(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "notes.txt")
+	expect.Error(os.WriteFile(name, []byte("some notes\n"), 0644)).Not().ToHaveOccurred(t)
+
+	ph := make(textproto.MIMEHeader)
+	ph.Set("Content-Disposition", `form-data; name="meta"`)
+	ph.Set("Content-Type", "application/json")
+	ph.Set("X-Extra", "1")
+
+	m := NewMultipart().
+		AddField("title", `a "quoted" title`).
+		AddFile("notes", name).
+		AddReader("data", "data.bin", "", strings.NewReader("\x00\x01\x02")).
+		AddPart(ph, strings.NewReader(`{"a":1}`))
+
+	testClient := mytesting.StubHttpWithBody(basicChallenge).ThenWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient),
+		SetAuthentication(auth.Deferred("fred", "password")))
+
+	_, err := cl.Post(context.Background(), "/upload", m)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 2)
+
+	req := testClient.Captured[1]
+	body := req.Body.(*bodypkg.Body).String()
+	expect.Number(req.ContentLength).ToBe(t, len(body))
+	expect.String(req.Header.Get(hdr.ContentLength)).ToBe(t, strconv.Itoa(len(body)))
+	expect.String(req.Header.Get(hdr.Authorization)).ToBe(t, "Basic ZnJlZDpwYXNzd29yZA==")
+
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get(hdr.ContentType))
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(mediaType).ToBe(t, "multipart/form-data")
+
+	mr := multipart.NewReader(strings.NewReader(body), params["boundary"])
+	expectPart(t, mr, "title", "", "", `a "quoted" title`)
+	expectPart(t, mr, "notes", "notes.txt", "text/plain; charset=utf-8", "some notes\n")
+	expectPart(t, mr, "data", "data.bin", "application/octet-stream", "\x00\x01\x02")
+	p := expectPart(t, mr, "meta", "", "application/json", `{"a":1}`)
+	expect.String(p.Header.Get("X-Extra")).ToBe(t, "1")
+	_, err = mr.NextPart()
+	expect.Error(err).ToBe(t, io.EOF)
+}
+
+func TestMultipart_one_shot_reader(t *testing.T) {
+	m := NewMultipart().
+		AddField("a", "1").
+		AddReader("data", "data.txt", "text/plain", io.MultiReader(strings.NewReader("xyz")))
+
+	testClient := mytesting.StubHttpWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	res, err := cl.Post(context.Background(), "/upload", m)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusNoContent)
+	expect.Number(testClient.Captured[0].ContentLength).ToBe(t, -1)
+	expect.String(testClient.Captured[0].Body.(*bodypkg.Body).String()).ToContain(t, "xyz")
+}
+
+func TestMultipart_missing_file(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	_, err := cl.Post(context.Background(), "/upload", NewMultipart().AddFile("f", "/no/such/file"))
+
+	expect.Error(err).ToHaveOccurred(t)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 0)
+}
+
+func expectPart(t *testing.T, mr *multipart.Reader, name, fileName, contentType, content string) *multipart.Part {
+	t.Helper()
+	p, err := mr.NextPart()
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(p.FormName()).ToBe(t, name)
+	expect.String(p.FileName()).ToBe(t, fileName)
+	expect.String(p.Header.Get("Content-Type")).ToBe(t, contentType)
+	b, err := io.ReadAll(p)
+	expect.String(b, err).ToBe(t, content)
+	return p
+}
@@ -0,0 +1,243 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	urlpkg "net/url"
+	"strings"
+
+	bodypkg "github.com/rickb777/httpclient/body"
+)
+
+// PageConfig controls how [Pages] and [PagesOf] iterate through a paginated collection.
+// The zero value follows RFC-8288 `Link: <...>; rel="next"` headers until there are no more pages.
+type PageConfig struct {
+	// PageSize, if non-zero, is sent as the PageSizeParam query parameter on the first request.
+	// Next links provided by the server are expected to carry it thereafter; cursor requests
+	// always carry it.
+	PageSize int
+	// PageSizeParam is the name of the page size query parameter, e.g. "limit".
+	PageSizeParam string
+
+	// MaxPages, if non-zero, limits the number of pages fetched.
+	MaxPages int
+
+	// Cursor, if not nil, extracts the cursor for the next page from the current page; a
+	// blank cursor, or the same cursor again, ends the iteration. The cursor is sent as the CursorParam query parameter.
+	// If Cursor is nil, Link headers are followed instead. See also [JSONCursor].
+	Cursor func(*Response) (string, error)
+	// CursorParam is the name of the cursor query parameter, e.g. "cursor".
+	CursorParam string
+}
+
+// Pages returns an iterator over the pages of a paginated collection, starting at path. Each page
+// is fetched using [RestClient.Get], so the client's headers, cookies and authenticator are used for
+// every page, as are the request options opts.
+//
+// Next links are resolved against the URL of the page that contained them. They must lie within the
+// client's root URL; otherwise the iteration stops with an error, so that credentials are not sent
+// elsewhere.
+//
+// The iteration stops after the first error, which is yielded along with the response (if any).
+// It also stops if the context is cancelled. Next links can only be followed if the client c was
+// created by [NewClient], because its root URL is needed; any other [RestClient] can be used with a
+// Cursor.
+func Pages(ctx context.Context, c RestClient, path string, cfg PageConfig, opts ...ReqOpt) iter.Seq2[*Response, error] {
+	cl, isClient := c.(*client)
+
+	return func(yield func(*Response, error) bool) {
+		next := path
+		firstOpts := opts
+		if cfg.PageSize > 0 && cfg.PageSizeParam != "" {
+			firstOpts = append(opts[:len(opts):len(opts)], Query(cfg.PageSizeParam, fmt.Sprintf("%d", cfg.PageSize)))
+		}
+		pageOpts := firstOpts
+		current := ""
+
+		for count := 1; ; count++ {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			res, err := c.Get(ctx, next, pageOpts...)
+			if err != nil {
+				yield(res, err)
+				return
+			}
+
+			if !yield(res, nil) {
+				return
+			}
+
+			if cfg.MaxPages > 0 && count >= cfg.MaxPages {
+				return
+			}
+
+			if cfg.Cursor != nil {
+				cursor, err := cfg.Cursor(res)
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				if cursor == "" || cursor == current {
+					return // no more pages, or the server is misbehaving
+				}
+				current = cursor
+				pageOpts = append(firstOpts[:len(firstOpts):len(firstOpts)], Query(cfg.CursorParam, cursor))
+
+			} else {
+				link := FindLink(res.Header, "next")
+				if link == "" {
+					return
+				}
+
+				if !isClient {
+					yield(nil, fmt.Errorf("next link %s cannot be followed by %T", link, c))
+					return
+				}
+
+				following, err := relativeToRoot(cl.root, res.Request.URL, link)
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				if following == next {
+					return // the server is misbehaving
+				}
+				next = following
+				pageOpts = opts
+			}
+		}
+	}
+}
+
+// PagesOf returns an iterator over the pages of a paginated collection, decoding each page into
+// a value of type T in the same way as [Do]. See [Pages].
+func PagesOf[T any](ctx context.Context, c RestClient, path string, cfg PageConfig, opts ...ReqOpt) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for res, err := range Pages(ctx, c, path, cfg, opts...) {
+			var value T
+			if err == nil {
+				err = decodeEntity(res, &value)
+				if err != nil {
+					err = &DecodeError{Response: *res, Cause: err}
+				}
+			}
+			if !yield(value, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// JSONCursor returns a cursor extractor for [PageConfig] that reads a string or numeric field from
+// a JSON response entity. The field is located by following the names of nested objects, e.g.
+// JSONCursor("meta", "next_cursor"). A missing or null field yields a blank cursor.
+func JSONCursor(fields ...string) func(*Response) (string, error) {
+	return func(res *Response) (string, error) {
+		if len(res.Body.Bytes()) == 0 {
+			return "", nil
+		}
+
+		var v any
+		err := bodypkg.JsonUnmarshal(res.Body.Rewind(), &v)
+		res.Body.Rewind()
+		if err != nil {
+			return "", err
+		}
+
+		for _, f := range fields {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return "", nil
+			}
+			v = obj[f]
+		}
+
+		switch cursor := v.(type) {
+		case nil:
+			return "", nil
+		case string:
+			return cursor, nil
+		case fmt.Stringer: // json.Number
+			return cursor.String(), nil
+		}
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// FindLink finds the target of the first web link having a given relation type in the "Link"
+// headers, which may contain several comma-separated links. It returns a blank string if there
+// is no such link. See RFC-8288.
+func FindLink(header http.Header, rel string) string {
+	for _, line := range header.Values("Link") {
+		for line != "" {
+			var target, params string
+			target, params, line = nextWebLink(line)
+			for _, t := range strings.Fields(linkParam(params, "rel")) {
+				if strings.EqualFold(t, rel) {
+					return target
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// nextWebLink splits off the first link in s, returning its target, its parameters and the
+// rest of s.
+func nextWebLink(s string) (target, params, rest string) {
+	s = strings.TrimLeft(s, " \t,")
+	if !strings.HasPrefix(s, "<") {
+		return "", "", ""
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 0 {
+		return "", "", ""
+	}
+	target, s = s[1:end], s[end+1:]
+
+	// find the comma that ends this link, skipping any inside quoted strings
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			i++
+		case ',':
+			if !quoted {
+				return target, s[:i], s[i+1:]
+			}
+		}
+	}
+	return target, s, ""
+}
+
+func linkParam(params, name string) string {
+	for _, p := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(p, "=")
+		if strings.EqualFold(strings.TrimSpace(k), name) {
+			return strings.Trim(strings.TrimSpace(v), `"`)
+		}
+	}
+	return ""
+}
+
+func relativeToRoot(root string, base *urlpkg.URL, link string) (string, error) {
+	u, err := base.Parse(link)
+	if err != nil {
+		return "", err
+	}
+
+	s := u.String()
+	if !strings.HasPrefix(s, root+"/") && s != root {
+		return "", fmt.Errorf("next link %s is outside %s", s, root)
+	}
+	return s[len(root):], nil
+}
@@ -0,0 +1,199 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/rickb777/expect"
+	"github.com/rickb777/httpclient/internal/mytesting"
+)
+
+func TestFindLink(t *testing.T) {
+	h := make(http.Header)
+	h.Add("Link", `<https://x.te/a?page=1>; rel="prev first", <https://x.te/a?page=3>; title="a, b"; rel=next`)
+	h.Add("Link", `<https://x.te/a?page=9>; rel="last"`)
+
+	expect.String(FindLink(h, "next")).ToBe(t, "https://x.te/a?page=3")
+	expect.String(FindLink(h, "first")).ToBe(t, "https://x.te/a?page=1")
+	expect.String(FindLink(h, "LAST")).ToBe(t, "https://x.te/a?page=9")
+	expect.String(FindLink(h, "self")).ToBe(t, "")
+}
+
+func TestPages_link_header(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Link: <http://example.test/foo/bar?page=2&size=2>; rel="next"
+Content-Length: 18
+
+[{"A":"a","B":1}]
+`).ThenWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Link: </foo/bar?page=3&size=2>; rel="next"
+Content-Length: 18
+
+[{"A":"b","B":2}]
+`).ThenWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Content-Length: 18
+
+[{"A":"c","B":3}]
+`)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	var all []data
+	for page, err := range PagesOf[[]data](context.Background(), cl, "/bar", PageConfig{PageSize: 2, PageSizeParam: "size"}, HeadersKV("X-Extra", "x")) {
+		expect.Error(err).Not().ToHaveOccurred(t)
+		all = append(all, page...)
+	}
+
+	expect.Slice(all).ToBe(t, data{A: "a", B: 1}, data{A: "b", B: 2}, data{A: "c", B: 3})
+	expect.Slice(testClient.Captured).ToHaveLength(t, 3)
+	expect.String(testClient.Captured[0].URL.String()).ToBe(t, "http://example.test/foo/bar?size=2")
+	expect.String(testClient.Captured[1].URL.String()).ToBe(t, "http://example.test/foo/bar?page=2&size=2")
+	expect.String(testClient.Captured[2].URL.String()).ToBe(t, "http://example.test/foo/bar?page=3&size=2")
+	expect.String(testClient.Captured[2].Header.Get("X-Extra")).ToBe(t, "x")
+}
+
+func TestPages_cursor_with_max_pages(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Content-Length: 37
+
+{"items":[1,2],"meta":{"next":"c2"}}
+`).ThenWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Content-Length: 37
+
+{"items":[3,4],"meta":{"next":"c3"}}
+`)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+	cfg := PageConfig{
+		PageSize:      2,
+		PageSizeParam: "limit",
+		MaxPages:      2,
+		Cursor:        JSONCursor("meta", "next"),
+		CursorParam:   "cursor",
+	}
+
+	n := 0
+	for res, err := range Pages(context.Background(), cl, "/bar", cfg) {
+		expect.Error(err).Not().ToHaveOccurred(t)
+		expect.Number(res.StatusCode).ToBe(t, http.StatusOK)
+		n++
+	}
+
+	expect.Number(n).ToBe(t, 2)
+	expect.String(testClient.Captured[0].URL.String()).ToBe(t, "http://example.test/foo/bar?limit=2")
+	expect.String(testClient.Captured[1].URL.String()).ToBe(t, "http://example.test/foo/bar?limit=2&cursor=c2")
+}
+
+func TestPages_repeated_cursor(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Content-Length: 23
+
+{"meta":{"next":"c2"}}
+`).ThenWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Content-Length: 23
+
+{"meta":{"next":"c2"}}
+`)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	n := 0
+	for _, err := range Pages(context.Background(), cl, "/bar", PageConfig{Cursor: JSONCursor("meta", "next"), CursorParam: "cursor"}) {
+		expect.Error(err).Not().ToHaveOccurred(t)
+		n++
+	}
+
+	expect.Number(n).ToBe(t, 2)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 2)
+}
+
+func TestPages_foreign_link(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Link: <http://elsewhere.test/bar?page=2>; rel="next"
+Content-Length: 3
+
+[]
+`)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	var errs []error
+	for _, err := range Pages(context.Background(), cl, "/bar", PageConfig{}) {
+		errs = append(errs, err)
+	}
+
+	expect.Slice(errs).ToHaveLength(t, 2)
+	expect.Error(errs[1]).ToContain(t, "outside http://example.test/foo")
+}
+
+func TestPages_cancelled(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Link: <http://example.test/foo/bar?page=2>; rel="next"
+Content-Length: 3
+
+[]
+`)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var errs []error
+	for _, err := range Pages(ctx, cl, "/bar", PageConfig{}) {
+		cancel()
+		errs = append(errs, err)
+	}
+
+	expect.Slice(errs).ToHaveLength(t, 2)
+	expect.Bool(errors.Is(errs[1], context.Canceled)).ToBeTrue(t)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 1)
+}
+
+func TestPages_other_rest_client(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Link: <http://example.test/foo/bar?page=2>; rel="next"
+Content-Length: 23
+
+{"meta":{"next":"c2"}}
+`).ThenWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Content-Length: 23
+
+{"meta":{"next":"c2"}}
+`).ThenWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Content-Length: 3
+
+{}
+`)
+	cl := decoratedClient{NewClient("http://example.test/foo", SetHttpClient(testClient))}
+
+	var errs []error
+	for _, err := range Pages(context.Background(), cl, "/bar", PageConfig{}) {
+		errs = append(errs, err)
+	}
+
+	expect.Slice(errs).ToHaveLength(t, 2)
+	expect.Error(errs[1]).ToContain(t, "cannot be followed by rest.decoratedClient")
+
+	n := 0
+	for _, err := range Pages(context.Background(), cl, "/bar", PageConfig{Cursor: JSONCursor("meta", "next"), CursorParam: "cursor"}) {
+		expect.Error(err).Not().ToHaveOccurred(t)
+		n++
+	}
+
+	expect.Number(n).ToBe(t, 2)
+	expect.String(testClient.Captured[2].URL.String()).ToBe(t, "http://example.test/foo/bar?cursor=c2")
+}
+
+// decoratedClient is a RestClient that is not a *client.
+type decoratedClient struct {
+	RestClient
+}
@@ -0,0 +1,214 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	bodypkg "github.com/rickb777/httpclient/body"
+)
+
+const (
+	// MergePatchJSON is the media type for JSON merge patch documents (RFC-7396).
+	MergePatchJSON = "application/merge-patch+json"
+
+	// JSONPatchJSON is the media type for JSON Patch documents (RFC-6902).
+	JSONPatchJSON = "application/json-patch+json"
+)
+
+// Patch performs a PATCH request using the request body supplied, which can be nil. The [Patcher]
+// method is used if c provides it; otherwise the request is made using [RestClient.Request], so
+// any RestClient can be used, including decorators of the client returned by [NewClient].
+func Patch(ctx context.Context, c RestClient, path string, reqBody any, opts ...ReqOpt) (*Response, error) {
+	if p, ok := c.(Patcher); ok {
+		return p.Patch(ctx, path, reqBody, opts...)
+	}
+	return responseOf(c.Request(ctx, http.MethodPatch, path, reqBody, opts...))
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// MergePatch is a request entity holding a JSON merge patch (see RFC-7396). It is sent with
+// the "application/merge-patch+json" content type.
+//
+// The Patch value is marshalled as JSON, unless it is a string or []byte, which is sent
+// verbatim. Remember that null members in a merge patch delete the corresponding members in
+// the target, so a map is often more suitable than a struct.
+type MergePatch struct {
+	Patch any
+}
+
+func (mp MergePatch) body() (*bodypkg.Body, error) {
+	switch v := mp.Patch.(type) {
+	case string:
+		return bodypkg.NewBodyString(v), nil
+	case []byte:
+		return bodypkg.NewBody(v), nil
+	}
+	return marshalEntity(mp.Patch)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// JSONPatch is a request entity holding a JSON Patch document, i.e. a sequence of operations
+// (see RFC-6902). It is sent with the "application/json-patch+json" content type.
+// Use [Diff] to compute the patch between two values.
+type JSONPatch []PatchOp
+
+// PatchOp is one JSON Patch operation. Op is one of "add", "remove", "replace", "move",
+// "copy" or "test". Path and From are JSON pointers (see RFC-6901).
+type PatchOp struct {
+	Op    string
+	Path  string
+	From  string
+	Value any
+}
+
+// MarshalJSON implements json.Marshaler. The value is included only for the operations that
+// require it, in which case it is included even if nil.
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `{"op":%q,"path":%s`, op.Op, quoteJSON(op.Path))
+
+	switch op.Op {
+	case "move", "copy":
+		fmt.Fprintf(buf, `,"from":%s`, quoteJSON(op.From))
+	case "add", "replace", "test":
+		v, err := bodypkg.JsonMarshal(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`,"value":`)
+		buf.Write(v)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func quoteJSON(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (jp JSONPatch) body() (*bodypkg.Body, error) {
+	if jp == nil {
+		jp = JSONPatch{} // an empty patch is an empty array, not null
+	}
+	return marshalEntity(jp)
+}
+
+func marshalEntity(v any) (*bodypkg.Body, error) {
+	rb, err := bodypkg.JsonMarshalToString(v)
+	if err != nil {
+		return nil, err
+	}
+	// "\n" is required for Posix compliance
+	return bodypkg.NewBodyString(rb + "\n"), nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Diff computes a JSON Patch that transforms the JSON representation of one value into that of
+// another. Both values are marshalled as JSON first, so struct tags etc. are honoured. Objects are
+// compared member by member and arrays element by element; any other difference results in
+// a "replace" operation.
+func Diff(from, to any) (JSONPatch, error) {
+	a, err := asJSONValue(from)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := asJSONValue(to)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffValues(JSONPatch{}, "", a, b), nil
+}
+
+func asJSONValue(v any) (any, error) {
+	bs, err := bodypkg.JsonMarshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var result any
+	err = bodypkg.JsonUnmarshal(bytes.NewReader(bs), &result)
+	return result, err
+}
+
+func diffValues(patch JSONPatch, path string, a, b any) JSONPatch {
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			return diffObjects(patch, path, av, bv)
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			return diffArrays(patch, path, av, bv)
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		patch = append(patch, PatchOp{Op: "replace", Path: path, Value: b})
+	}
+	return patch
+}
+
+func diffObjects(patch JSONPatch, path string, a, b map[string]any) JSONPatch {
+	for _, k := range sortedKeys(a) {
+		if _, exists := b[k]; !exists {
+			patch = append(patch, PatchOp{Op: "remove", Path: path + "/" + escapePointer(k)})
+		}
+	}
+
+	for _, k := range sortedKeys(b) {
+		if av, exists := a[k]; exists {
+			patch = diffValues(patch, path+"/"+escapePointer(k), av, b[k])
+		} else {
+			patch = append(patch, PatchOp{Op: "add", Path: path + "/" + escapePointer(k), Value: b[k]})
+		}
+	}
+
+	return patch
+}
+
+func diffArrays(patch JSONPatch, path string, a, b []any) JSONPatch {
+	n := min(len(a), len(b))
+
+	for i := 0; i < n; i++ {
+		patch = diffValues(patch, path+"/"+strconv.Itoa(i), a[i], b[i])
+	}
+
+	// remove from the end backwards so that the indexes remain valid
+	for i := len(a) - 1; i >= n; i-- {
+		patch = append(patch, PatchOp{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+
+	for i := n; i < len(b); i++ {
+		patch = append(patch, PatchOp{Op: "add", Path: path + "/-", Value: b[i]})
+	}
+
+	return patch
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// escapePointer escapes a JSON pointer reference token (see RFC-6901).
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
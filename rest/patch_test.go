@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/rickb777/acceptable/header"
+	hdr "github.com/rickb777/acceptable/headername"
+	"github.com/rickb777/expect"
+	bodypkg "github.com/rickb777/httpclient/body"
+	"github.com/rickb777/httpclient/internal/mytesting"
+)
+
+func TestPatch_merge_patch(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	patch := MergePatch{Patch: map[string]any{"A": "hello", "B": nil}}
+	res, err := cl.(Patcher).Patch(context.Background(), "/bar", patch, IfMatch(header.ETag{Hash: "v1"}))
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusNoContent)
+	expect.String(testClient.Captured[0].Method).ToBe(t, http.MethodPatch)
+	expect.String(testClient.Captured[0].Header.Get(hdr.ContentType)).ToBe(t, MergePatchJSON)
+	expect.String(testClient.Captured[0].Header.Get(hdr.ContentLength)).ToBe(t, "23")
+	expect.String(testClient.Captured[0].Header.Get(hdr.IfMatch)).ToBe(t, `"v1"`)
+	expect.String(testClient.Captured[0].Body.(*bodypkg.Body).String()).ToBe(t, `{"A":"hello","B":null}`+"\n")
+}
+
+func TestPatch_json_patch(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	patch := JSONPatch{
+		{Op: "test", Path: "/B", Value: nil},
+		{Op: "move", Path: "/C", From: "/A"},
+		{Op: "remove", Path: "/D"},
+	}
+	_, err := cl.(Patcher).Patch(context.Background(), "/bar", patch)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(testClient.Captured[0].Header.Get(hdr.ContentType)).ToBe(t, JSONPatchJSON)
+	expect.String(testClient.Captured[0].Body.(*bodypkg.Body).String()).ToBe(t,
+		`[{"op":"test","path":"/B","value":null},{"op":"move","path":"/C","from":"/A"},{"op":"remove","path":"/D"}]`+"\n")
+}
+
+func TestPatch_json_patch_pointer(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	patch := &JSONPatch{{Op: "remove", Path: "/D"}}
+	_, err := cl.(Patcher).Patch(context.Background(), "/bar", patch)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(testClient.Captured[0].Header.Get(hdr.ContentType)).ToBe(t, JSONPatchJSON)
+	expect.String(testClient.Captured[0].Body.(*bodypkg.Body).String()).ToBe(t, `[{"op":"remove","path":"/D"}]`+"\n")
+}
+
+func TestPatch_nil_pointer(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody("HTTP/1.1 204 No Content\n\n").
+		ThenWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	var mp *MergePatch
+	_, err := cl.(Patcher).Patch(context.Background(), "/bar", mp)
+	expect.Error(err).Not().ToHaveOccurred(t)
+
+	var jp *JSONPatch
+	_, err = cl.(Patcher).Patch(context.Background(), "/bar", jp)
+	expect.Error(err).Not().ToHaveOccurred(t)
+
+	for _, req := range testClient.Captured {
+		expect.String(req.Header.Get(hdr.ContentType)).ToBe(t, "")
+		expect.Any(req.Body).ToBeNil(t)
+	}
+}
+
+func TestPatch_decorated_client(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := decoratedClient{NewClient("http://example.test/foo", SetHttpClient(testClient))}
+
+	res, err := Patch(context.Background(), cl, "/bar", JSONPatch{{Op: "remove", Path: "/D"}})
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusNoContent)
+	expect.String(testClient.Captured[0].Method).ToBe(t, http.MethodPatch)
+	expect.String(testClient.Captured[0].Header.Get(hdr.ContentType)).ToBe(t, JSONPatchJSON)
+}
+
+func TestDiff(t *testing.T) {
+	type item struct {
+		Name  string            `json:"name"`
+		Tags  []string          `json:"tags"`
+		Attrs map[string]string `json:"attrs,omitempty"`
+		Count int               `json:"count"`
+	}
+
+	a := item{Name: "a", Tags: []string{"x", "y", "z"}, Attrs: map[string]string{"a/b": "1", "c": "2"}, Count: 1}
+	b := item{Name: "b", Tags: []string{"x", "q"}, Attrs: map[string]string{"c": "2", "d~": "3"}, Count: 1}
+
+	patch, err := Diff(a, b)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	s, err := bodypkg.JsonMarshalToString(patch)
+	expect.String(s, err).ToBe(t, `[`+
+		`{"op":"remove","path":"/attrs/a~1b"},`+
+		`{"op":"add","path":"/attrs/d~0","value":"3"},`+
+		`{"op":"replace","path":"/name","value":"b"},`+
+		`{"op":"replace","path":"/tags/1","value":"q"},`+
+		`{"op":"remove","path":"/tags/2"}]`)
+
+	same, err := Diff(a, a)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Slice(same).ToBeEmpty(t)
+}
@@ -14,6 +14,7 @@ import (
 	. "github.com/rickb777/acceptable/contenttype"
 	"github.com/rickb777/acceptable/header"
 	hdr "github.com/rickb777/acceptable/headername"
+	"github.com/rickb777/httpclient"
 	authpkg "github.com/rickb777/httpclient/auth"
 	bodypkg "github.com/rickb777/httpclient/body"
 )
@@ -44,34 +45,40 @@ func (c *client) request(ctx context.Context, depth int, method, path string, re
 	var req *http.Request
 
 	// Buffer the body because, if authorization fails, we will need to read from it again.
-	bodyBuf, hdrs, defaults, err := processRequestEntity(reqBody)
+	// Streams are not buffered; they can only be sent again if they can be re-read.
+	bodyBuf, stream, hdrs, defaults, err := processRequestEntity(reqBody)
 	if err != nil {
 		return nil, err
 	}
 
 	u := c.root + withLeadingSlash(path)
-	req, err = http.NewRequestWithContext(ctx, method, u, bodyBuf)
+	if stream != nil {
+		var rdr io.ReadCloser
+		rdr, err = stream.open()
+		if err != nil {
+			return nil, err
+		}
+		req, err = http.NewRequestWithContext(ctx, method, u, rdr)
+		if err != nil {
+			_ = rdr.Close()
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, u, bodyBuf)
+	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	cs := c.cookies.Cookies(req.URL)
-	for _, c := range cs {
-		req.Header.Add("Cookie", c.String())
+	if stream != nil {
+		req.ContentLength = stream.length
+		req.GetBody = stream.getBody
+	} else if bodyBuf != nil {
+		req.ContentLength = int64(len(bodyBuf.Bytes()))
+		req.GetBody = bodyBuf.Getter()
 	}
 
-	// client-scoped headers
-	for k, vals := range c.headers {
-		for _, v := range vals {
-			req.Header.Add(k, v)
-		}
-	}
-
-	// request-scoped options - mostly headers
-	for _, opt := range opts {
-		opt(req)
-	}
+	c.setHeaders(req, opts)
 
 	// headers determined by the request entity
 	for k, vs := range hdrs {
@@ -85,31 +92,48 @@ func (c *client) request(ctx context.Context, depth int, method, path string, re
 		}
 	}
 
-	// Make sure we read 'c.auth' only once because it may be substituted below,
-	// which is unsafe to do when multiple goroutines are running at the same time.
-	c.authMutex.Lock()
-	auth := c.auth // make a duplicate
-	c.authMutex.Unlock()
+	// The authenticators for this request's protection space; these may be substituted
+	// concurrently by other requests meeting challenges.
+	space, auth, proxyAuth := c.auth.authenticators(req.URL)
+
+	if stream != nil && !stream.replayable() && (c.auth.deferred(auth) || deferred(proxyAuth)) {
+		// the stream can only be sent once, so obtain any authentication challenge beforehand
+		space, auth, proxyAuth, err = c.preflight(ctx, u, opts)
+		if err != nil {
+			_ = req.Body.Close()
+			return nil, err
+		}
+	}
 
 	// set the authentication headers
-	auth.Authenticate(req)
+	if err = authenticate(req, auth, proxyAuth); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
 
 	res, err = c.hc.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	if res.StatusCode == http.StatusUnauthorized && auth.Type() == authpkg.None {
+	if retryAuthentication(res, space, auth, proxyAuth, depth) && (stream == nil || stream.replayable()) {
 		if depth > 3 {
 			r2, e2 := copyResponse(res, nil)
-			return nil, &RestError{
-				Response: r2,
-				Cause:    errors.Join(e2, fmt.Errorf("too many authentication retries")),
-			}
+			return nil, newRestError(r2, errors.Join(e2, fmt.Errorf("too many authentication retries")))
+		}
+		replay := replayEntity{required: hdrs, defaults: defaults}
+		if stream != nil {
+			replay.body = stream
+		} else {
+			replay.body = bodyBuf.Rewind()
 		}
-		return c.repeat(ctx, depth, res, method, path, bodyBuf, opts...)
+		return c.repeat(ctx, depth, req, res, auth, proxyAuth, method, path, replay, opts...)
 	} else if res.StatusCode == http.StatusUnauthorized {
 		return res, newPathError("Authorize", req.URL.Path, res.StatusCode)
+	} else if res.StatusCode == http.StatusProxyAuthRequired {
+		return res, newPathError("ProxyAuthorize", req.URL.Path, res.StatusCode)
 	}
 
 	c.cookies.SetCookies(req.URL, res.Cookies())
@@ -118,37 +142,131 @@ func (c *client) request(ctx context.Context, depth int, method, path string, re
 	return res, nil
 }
 
+// setHeaders sets the cookies, the client-scoped headers and the request-scoped options on a request.
+func (c *client) setHeaders(req *http.Request, opts []ReqOpt) {
+	cs := c.cookies.Cookies(req.URL)
+	for _, c := range cs {
+		req.Header.Add("Cookie", c.String())
+	}
+
+	// client-scoped headers
+	for k, vals := range c.headers {
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
+	}
+
+	// request-scoped options - mostly headers
+	for _, opt := range opts {
+		opt(req)
+	}
+}
+
 //-------------------------------------------------------------------------------------------------
 
-func (c *client) repeat(ctx context.Context, depth int, res *http.Response, method, path string, body *bodypkg.Body, opts ...ReqOpt) (req *http.Response, err error) {
-	wwwAuthenticateHeader := res.Header.Get("Www-Authenticate")
-	wwwAuthenticateHeaderLC := strings.ToLower(wwwAuthenticateHeader)
-
-	c.authMutex.Lock()
-	auth := c.auth
-	c.authMutex.Unlock()
-
-	if strings.Contains(wwwAuthenticateHeaderLC, "digest") {
-		c.authMutex.Lock()
-		c.auth = authpkg.Digest(auth.User(), auth.Password()).DigestParts(wwwAuthenticateHeader)
-		c.authMutex.Unlock()
-	} else if strings.Contains(wwwAuthenticateHeaderLC, "basic") {
-		c.authMutex.Lock()
-		c.auth = authpkg.Basic(auth.User(), auth.Password())
-		c.authMutex.Unlock()
-	} else {
+// preflight sends HEAD requests in order to obtain any authentication challenges in advance,
+// from the proxy and from the server. The resulting authenticators are returned.
+func (c *client) preflight(ctx context.Context, u string, opts []ReqOpt) (*protectionSpace, authpkg.Authenticator, authpkg.Authenticator, error) {
+	for depth := 1; depth <= 3; depth++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		c.setHeaders(req, opts)
+
+		space, auth, proxyAuth := c.auth.authenticators(req.URL)
+		if depth > 1 && !c.auth.deferred(auth) && !deferred(proxyAuth) {
+			break // there are no more challenges to obtain
+		}
+
+		if err = authenticate(req, auth, proxyAuth); err != nil {
+			return nil, nil, nil, err
+		}
+
+		res, err := c.hc.Do(req)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		_ = res.Body.Close()
+
+		if !retryAuthentication(res, space, auth, proxyAuth, depth) || !c.auth.challenge(req.URL, res, c.used(res, auth, proxyAuth)) {
+			break
+		}
+	}
+
+	target, err := urlpkg.Parse(u)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	space, auth, proxyAuth := c.auth.authenticators(target)
+	return space, auth, proxyAuth, nil
+}
+
+// repeat meets an authentication challenge and sends the request again. The new request's context
+// refers to the request that was challenged (see httpclient.Previous).
+func (c *client) repeat(ctx context.Context, depth int, req *http.Request, res *http.Response, auth, proxyAuth authpkg.Authenticator, method, path string, body any, opts ...ReqOpt) (*http.Response, error) {
+	if !c.auth.challenge(req.URL, res, c.used(res, auth, proxyAuth)) {
 		return res, newPathError("Authorize", c.root, res.StatusCode)
 	}
 
 	_ = res.Body.Close()
 
-	body.Rewind()
-	return c.request(ctx, depth+1, method, path, body, opts...)
+	previous := req
+	if res.Request != nil {
+		previous = res.Request // the last request sent, after any redirects
+	}
+
+	return c.request(httpclient.WithPrevious(ctx, previous), depth+1, method, path, body, opts...)
+}
+
+// authenticate sets the authentication headers. The request must not be sent if this fails,
+// e.g. because an access token could not be obtained.
+func authenticate(req *http.Request, auth, proxyAuth authpkg.Authenticator) error {
+	if err := authpkg.AuthenticateRequest(auth, req); err != nil {
+		return newPathErrorErr("Authenticate", req.URL.Path, err)
+	}
+	if err := authpkg.AuthenticateProxy(proxyAuth, req); err != nil {
+		return newPathErrorErr("ProxyAuthenticate", req.URL.Path, err)
+	}
+	return nil
+}
+
+// used gets the authenticator that was challenged by a response.
+func (c *client) used(res *http.Response, auth, proxyAuth authpkg.Authenticator) authpkg.Authenticator {
+	if res.StatusCode == http.StatusProxyAuthRequired {
+		return proxyAuth
+	}
+	return auth
+}
+
+// staleNonce is true if a Digest challenge indicates that the previous nonce had expired, in
+// which case the request can be repeated using the new nonce (see RFC-7616 section 3.3).
+func staleNonce(res *http.Response, challengeHeader string) bool {
+	for _, ch := range authpkg.ParseChallenges(res.Header.Values(challengeHeader)) {
+		if ch.Is("Digest") && strings.EqualFold(ch.Params["stale"], "true") {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidToken is true if a Bearer token was rejected on the first attempt, in which case the
+// request can be repeated once using a new token.
+func invalidToken(res *http.Response, auth authpkg.Authenticator, depth int) bool {
+	return depth == 1 && auth.Type() == "Bearer" &&
+		authpkg.InvalidToken(authpkg.ChallengesFor(authpkg.ParseChallenges(res.Header.Values("Www-Authenticate")), "Bearer"))
 }
 
 //-------------------------------------------------------------------------------------------------
 
-func processRequestEntity(input any) (requestBody *bodypkg.Body, required, defaults http.Header, err error) {
+// replayEntity holds a request entity and its headers so that the request can be repeated.
+type replayEntity struct {
+	body               any // *bodypkg.Body or *streamEntity
+	required, defaults http.Header
+}
+
+func processRequestEntity(input any) (requestBody *bodypkg.Body, stream *streamEntity, required, defaults http.Header, err error) {
 	required = make(http.Header)
 	defaults = make(http.Header)
 
@@ -167,15 +285,50 @@ func processRequestEntity(input any) (requestBody *bodypkg.Body, required, defau
 		// ContentType must set elsewhere
 		required.Set(hdr.ContentLength, strconv.Itoa(len(data)))
 		requestBody = bodypkg.NewBody(data)
+	case Stream:
+		// ContentType must set elsewhere
+		stream, err = data.prepare()
+	case *Stream:
+		// ContentType must set elsewhere
+		stream, err = data.prepare()
+	case replayEntity:
+		// repeating a request
+		requestBody, stream, _, _, err = processRequestEntity(data.body)
+		return requestBody, stream, data.required, data.defaults, err
+	case *Multipart:
+		required.Set(hdr.ContentType, data.ContentType())
+		stream, err = data.prepare()
+	case *streamEntity:
+		stream = data
 	case io.Reader:
 		// ContentType must set elsewhere
-		requestBody, err = bodypkg.Copy(data)
+		if ra, offset, size, ok := readerAtSize(data); ok && StreamThreshold >= 0 && size > StreamThreshold {
+			stream = &streamEntity{length: size, getBody: sectionGetter(ra, offset, size)}
+		} else {
+			requestBody, err = bodypkg.Copy(data)
+		}
 	case bodypkg.Body:
 		// ContentType must set elsewhere
 		requestBody = &data
 	case *bodypkg.Body:
 		// ContentType must set elsewhere
 		requestBody = data
+	case MergePatch:
+		required.Set(hdr.ContentType, MergePatchJSON)
+		requestBody, err = data.body()
+	case *MergePatch:
+		if data != nil { // nil means no entity
+			required.Set(hdr.ContentType, MergePatchJSON)
+			requestBody, err = data.body()
+		}
+	case JSONPatch:
+		required.Set(hdr.ContentType, JSONPatchJSON)
+		requestBody, err = data.body()
+	case *JSONPatch:
+		if data != nil { // nil means no entity
+			required.Set(hdr.ContentType, JSONPatchJSON)
+			requestBody, err = data.body()
+		}
 	case ReqOpt:
 		panic("ReqOpt passed instead of a body - please fix this")
 	default:
@@ -190,9 +343,11 @@ func processRequestEntity(input any) (requestBody *bodypkg.Body, required, defau
 
 	if requestBody != nil {
 		required.Set(hdr.ContentLength, strconv.Itoa(len(requestBody.Bytes())))
+	} else if stream != nil && stream.length >= 0 {
+		required.Set(hdr.ContentLength, strconv.FormatInt(stream.length, 10))
 	}
 
-	return requestBody, required, defaults, err
+	return requestBody, stream, required, defaults, err
 }
 
 //-------------------------------------------------------------------------------------------------
@@ -225,6 +380,13 @@ func (c *client) Put(ctx context.Context, path string, reqBody any, opts ...ReqO
 
 //-------------------------------------------------------------------------------------------------
 
+// Patch implements [Patcher].
+func (c *client) Patch(ctx context.Context, path string, reqBody any, opts ...ReqOpt) (response *Response, err error) {
+	return responseOf(c.Request(ctx, http.MethodPatch, path, reqBody, opts...))
+}
+
+//-------------------------------------------------------------------------------------------------
+
 // Delete performs a DELETE request. The request body can be supplied but should normally be nil (see RFC-9110).
 func (c *client) Delete(ctx context.Context, path string, reqBody any, opts ...ReqOpt) (response *Response, err error) {
 	return responseOf(c.Request(ctx, http.MethodDelete, path, reqBody, opts...))
@@ -271,10 +433,7 @@ func responseOf(res *http.Response, err error) (*Response, error) {
 	// response will also be available in the RESTError.
 
 	if r.StatusCode >= 400 {
-		return &r, &RestError{
-			Response: r,
-			Cause:    err2,
-		}
+		return &r, newRestError(r, err2)
 	} else if r.StatusCode >= 300 {
 		return &r, &RestError{
 			Response: r,
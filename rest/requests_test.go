@@ -2,8 +2,11 @@ package rest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
 	"net/url"
 	"strings"
 	"testing"
@@ -290,3 +293,273 @@ Content-Length: 21
 	expect.String(testClient.Captured[1].Method).ToBe(t, http.MethodGet)
 	expect.String(testClient.Captured[1].Header.Get(hdr.Authorization)).ToBe(t, "Basic ZnJlZDpwYXNzd29yZA==")
 }
+
+func TestAuthenticationChallenge_with_entity(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Basic realm="WallyWorld"
+
+`).ThenWithBody("HTTP/1.1 204 No Content\n\n")
+
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient),
+		SetAuthentication(auth.Deferred("fred", "password")))
+
+	_, err := cl.Post(context.Background(), "/bar", &data{A: "hello", B: 10})
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 2)
+	expect.String(testClient.Captured[1].Header.Get(hdr.ContentType)).ToBe(t, contenttype.ApplicationJSON)
+	expect.String(testClient.Captured[1].Header.Get(hdr.ContentLength)).ToBe(t, "21")
+	expect.String(testClient.Captured[1].Body.(*bodypkg.Body).String()).ToBe(t, `{"A":"hello","B":10}`+"\n")
+}
+
+func TestAuthenticationChallenge_several_in_one_field(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Newauth realm="apps", type=1, title="Login to \"apps\", please", Basic realm="simple, really", Digest realm="x", algorithm=SHA-512, nonce="abc", Digest realm="basic digest", qop="auth", nonce="def"
+
+`).ThenWithBody("HTTP/1.1 204 No Content\n\n")
+
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient),
+		SetAuthentication(auth.Deferred("fred", "password")))
+
+	_, err := cl.Get(context.Background(), "/bar")
+
+	// the Digest challenge with an unsupported algorithm is ignored
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 2)
+	expect.String(testClient.Captured[1].Header.Get(hdr.Authorization)).ToContain(t, `Digest username="fred", realm="basic digest", `)
+	expect.String(testClient.Captured[1].Header.Get(hdr.Authorization)).ToContain(t, `nonce="def"`)
+}
+
+func TestAuthenticationChallenge_unsupported(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Negotiate, NTLM
+
+`)
+
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient),
+		SetAuthentication(auth.Deferred("fred", "password")))
+
+	_, err := cl.Get(context.Background(), "/bar")
+
+	expect.Error(err).ToHaveOccurred(t)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 1)
+}
+
+func TestDigestChallenge_stale_nonce(t *testing.T) {
+	ds := &mytesting.DigestServer{
+		Realm:      "test@example.org",
+		Algorithms: []string{"MD5", "SHA-256"},
+		Qop:        "auth-int, auth",
+		Users:      map[string]string{"fred": "password"},
+		StaleAfter: 2,
+	}
+	svr := httptest.NewServer(ds)
+	defer svr.Close()
+
+	cl := NewClient(svr.URL, SetAuthentication(auth.Deferred("fred", "password")))
+
+	for i := 0; i < 5; i++ {
+		res, err := cl.Post(context.Background(), "/bar", &data{A: "hello", B: i})
+		expect.Error(err).Info(i).Not().ToHaveOccurred(t)
+		expect.Number(res.StatusCode).Info(i).ToBe(t, http.StatusNoContent)
+	}
+
+	// the initial challenge plus two stale nonces
+	expect.Number(ds.Challenges).ToBe(t, 3)
+	expect.Slice(ds.Authorized).ToHaveLength(t, 5)
+	expect.String(ds.Authorized[4]["algorithm"]).ToBe(t, "SHA-256")
+	expect.String(ds.Authorized[4]["qop"]).ToBe(t, "auth-int")
+}
+
+func TestBearerChallenge_invalid_token(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Bearer realm="example", error="invalid_token"
+
+`).ThenWithBody("HTTP/1.1 204 No Content\n\n").
+		ThenWithBody(`HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Bearer realm="example", error="invalid_token"
+
+`).ThenWithBody(`HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Bearer realm="example", error="invalid_token"
+
+`)
+
+	src := &tokens{}
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient),
+		SetAuthentication(auth.Bearer(src)))
+
+	_, err := cl.Post(context.Background(), "/bar", &data{A: "hello", B: 10})
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 2)
+	expect.String(testClient.Captured[0].Header.Get(hdr.Authorization)).ToBe(t, "Bearer token1")
+	expect.String(testClient.Captured[1].Header.Get(hdr.Authorization)).ToBe(t, "Bearer token2")
+	expect.String(testClient.Captured[1].Body.(*bodypkg.Body).String()).ToBe(t, `{"A":"hello","B":10}`+"\n")
+
+	// a token is only refreshed once per request
+	_, err = cl.Get(context.Background(), "/bar")
+
+	expect.Error(err).ToHaveOccurred(t)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 4)
+}
+
+func TestBearer_token_unavailable(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody("HTTP/1.1 204 No Content\n\n")
+	src := &tokens{err: errors.New("token endpoint unreachable")}
+
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient), SetAuthentication(auth.Bearer(src)))
+	_, err := cl.Post(context.Background(), "/bar", &data{A: "hello", B: 10})
+
+	expect.Error(err).ToContain(t, "Authenticate /foo/bar: token endpoint unreachable")
+	expect.Bool(errors.Is(err, src.err)).ToBeTrue(t)
+
+	cl = NewClient("http://example.test/foo", SetHttpClient(testClient), SetProxyAuthentication(auth.Bearer(src)))
+	_, err = cl.Get(context.Background(), "/bar")
+
+	expect.Error(err).ToContain(t, "ProxyAuthenticate /foo/bar: token endpoint unreachable")
+	expect.Slice(testClient.Captured).ToBeEmpty(t) // nothing was sent
+}
+
+func TestAuthenticationChallenge_credentials_provider(t *testing.T) {
+	ds := &mytesting.DigestServer{
+		Realm: "test@example.org",
+		Qop:   "auth",
+		Users: map[string]string{"fred": "password"},
+	}
+	svr := httptest.NewServer(ds)
+	defer svr.Close()
+	u, _ := url.Parse(svr.URL)
+
+	provider := auth.CredentialsMap{
+		{Host: u.Host, Realm: "test@example.org"}: {User: "fred", Password: "password"},
+		{Host: "other.example.com"}:               {User: "mallory", Password: "x"},
+	}
+
+	cl := NewClient(svr.URL, SetCredentials(provider))
+
+	res, err := cl.Get(context.Background(), "/bar")
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusNoContent)
+	expect.Slice(ds.Authorized).ToHaveLength(t, 1)
+	expect.String(ds.Authorized[0]["username"]).ToBe(t, "fred")
+}
+
+func TestAuthenticationChallenge_credentials_provider_other_host(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Basic realm="WallyWorld"
+
+`)
+
+	provider := auth.CredentialsMap{{Host: "other.example.com"}: {User: "fred", Password: "password"}}
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient), SetCredentials(provider))
+
+	_, err := cl.Get(context.Background(), "/bar")
+
+	expect.Error(err).ToHaveOccurred(t)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 1)
+}
+
+func TestProxyAuthenticationChallenge(t *testing.T) {
+	origin := &mytesting.DigestServer{
+		Realm: "test@example.org",
+		Qop:   "auth-int, auth",
+		Users: map[string]string{"fred": "password"},
+	}
+	svr := httptest.NewServer(origin)
+	defer svr.Close()
+	target, _ := url.Parse(svr.URL)
+
+	// a local forward proxy that requires its own credentials
+	proxy := &mytesting.DigestServer{
+		Realm:      "proxy@example.org",
+		Algorithms: []string{"SHA-256"},
+		Qop:        "auth",
+		Users:      map[string]string{"proxy-user": "proxy-pw"},
+		StaleAfter: 2,
+		Proxy:      true,
+		Next: &httputil.ReverseProxy{Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+		}},
+	}
+	psvr := httptest.NewServer(proxy)
+	defer psvr.Close()
+	proxyURL, _ := url.Parse(psvr.URL)
+
+	hc := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	cl := NewClient("http://example.test/foo", SetHttpClient(hc),
+		SetAuthentication(auth.Deferred("fred", "password")),
+		SetProxyAuthentication(auth.Deferred("proxy-user", "proxy-pw")))
+
+	for i := 0; i < 3; i++ {
+		res, err := cl.Post(context.Background(), "/bar", &data{A: "hello", B: i})
+		expect.Error(err).Info(i).Not().ToHaveOccurred(t)
+		expect.Number(res.StatusCode).Info(i).ToBe(t, http.StatusNoContent)
+	}
+
+	// the initial proxy challenge plus one stale nonce
+	expect.Number(proxy.Challenges).ToBe(t, 2)
+	expect.Number(origin.Challenges).ToBe(t, 1)
+	expect.Slice(origin.Authorized).ToHaveLength(t, 3)
+	expect.String(proxy.Authorized[0]["username"]).ToBe(t, "proxy-user")
+	expect.String(origin.Authorized[2]["username"]).ToBe(t, "fred")
+	expect.String(origin.Authorized[2]["qop"]).ToBe(t, "auth-int")
+}
+
+func TestProxyAuthenticationChallenge_without_credentials(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 407 Proxy Authentication Required
+Proxy-Authenticate: Basic realm="proxy"
+
+`)
+
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	res, err := cl.Request(context.Background(), http.MethodGet, "/bar", nil)
+
+	expect.Error(err).ToHaveOccurred(t)
+	expect.String(err.Error()).ToContain(t, "ProxyAuthorize")
+	expect.Number(res.StatusCode).ToBe(t, http.StatusProxyAuthRequired)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 1)
+}
+
+func TestProxyAuthenticationChallenge_basic(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 407 Proxy Authentication Required
+Proxy-Authenticate: Basic realm="proxy"
+
+`).ThenWithBody(`HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Basic realm="WallyWorld"
+
+`).ThenWithBody("HTTP/1.1 204 No Content\n\n")
+
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient),
+		SetAuthentication(auth.Deferred("fred", "password")),
+		SetProxyAuthentication(auth.Deferred("user", "password")))
+
+	_, err := cl.Get(context.Background(), "/bar")
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 3)
+	expect.String(testClient.Captured[1].Header.Get("Proxy-Authorization")).ToBe(t, "Basic dXNlcjpwYXNzd29yZA==")
+	expect.String(testClient.Captured[1].Header.Get(hdr.Authorization)).ToBe(t, "")
+	expect.String(testClient.Captured[2].Header.Get("Proxy-Authorization")).ToBe(t, "Basic dXNlcjpwYXNzd29yZA==")
+	expect.String(testClient.Captured[2].Header.Get(hdr.Authorization)).ToBe(t, "Basic ZnJlZDpwYXNzd29yZA==")
+}
+
+type tokens struct {
+	n   int
+	err error
+}
+
+func (ts *tokens) Token(context.Context) (string, error) {
+	if ts.err != nil {
+		return "", ts.err
+	}
+	if ts.n == 0 {
+		ts.n++
+	}
+	return fmt.Sprintf("token%d", ts.n), nil
+}
+
+func (ts *tokens) Invalidate(string) {
+	ts.n++
+}
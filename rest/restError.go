@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	bodypkg "github.com/rickb777/httpclient/body"
 	"github.com/rickb777/httpclient/rest/temperror"
 )
 
@@ -18,6 +19,26 @@ type Body interface {
 type RestError struct {
 	Response
 	Cause error
+	// ErrorBody holds the response entity decoded by one of the [ErrorBodyDecoders], if the
+	// response media type has a registered decoder. Otherwise it is nil.
+	ErrorBody any
+}
+
+// newRestError builds a RestError, decoding the response entity if there is a suitable decoder
+// for its media type.
+func newRestError(r Response, cause error) *RestError {
+	re := &RestError{
+		Response: r,
+		Cause:    cause,
+	}
+
+	if decoder, exists := ErrorBodyDecoders[strings.ToLower(r.Type.MediaType)]; exists && len(r.Body.Bytes()) > 0 {
+		// an entity that cannot be decoded is still available verbatim via re.Body
+		re.ErrorBody, _ = decoder(r.Body.Rewind())
+		r.Body.Rewind()
+	}
+
+	return re
 }
 
 // IsPermanent returns the opposite of [RestError.IsTransient]; the request should not be retried.
@@ -38,34 +59,141 @@ func (re *RestError) IsTransient() bool {
 	return false
 }
 
+// Problem returns the RFC-9457 problem details from the response, if any were provided.
+// Otherwise it returns nil.
+func (re *RestError) Problem() *Problem {
+	p, _ := re.ErrorBody.(*Problem)
+	return p
+}
+
 // Error makes it compatible with `error` interface.
 func (re *RestError) Error() string {
 	if 300 <= re.Response.StatusCode && re.Response.StatusCode < 400 {
 		return fmt.Sprintf(`%d: %s %s %s %s`, re.StatusCode, re.Request.Method, re.Request.URL,
 			strings.ToLower(http.StatusText(re.Response.StatusCode)), re.Header.Get("Location"))
 	}
+	if p := re.Problem(); p != nil && (p.Title != "" || p.Detail != "") {
+		return fmt.Sprintf(`%d: %s %s %s`, re.StatusCode, re.Request.Method, re.Request.URL, limit(p.String()))
+	}
 	if re.Type.MediaType == "" {
 		return fmt.Sprintf(`%d: %s %s`, re.StatusCode, re.Request.Method, re.Request.URL)
 	}
 	if re.Type.IsTextual() {
-		b := strings.TrimSpace(re.Response.Body.String())
-		if len(b) > RESTErrorStringLimit {
-			b = b[:RESTErrorStringLimit] + "..."
-		}
+		b := limit(strings.TrimSpace(re.Response.Body.String()))
 		return fmt.Sprintf(`%d: %s %s %s %s`, re.StatusCode, re.Request.Method, re.Request.URL, re.Type, b)
 	}
 	return fmt.Sprintf(`%d: %s %s %s`, re.StatusCode, re.Request.Method, re.Request.URL, re.Type)
 }
 
+func limit(s string) string {
+	if len(s) > RESTErrorStringLimit {
+		return s[:RESTErrorStringLimit] + "..."
+	}
+	return s
+}
+
 func (re *RestError) Unwrap() error {
 	return re.Cause
 }
 
-//func (re *RestError) UnmarshalJSONResponse(value any) error {
-//	if re.Response.Body == nil {
-//		return nil
-//	}
-//	return body.JsonUnmarshal(re.Response.Body, value)
-//}
+// UnmarshalJSONResponse decodes the buffered response entity as JSON into some value,
+// which should be a pointer. It does nothing if there is no response entity.
+func (re *RestError) UnmarshalJSONResponse(value any) error {
+	if len(re.Response.Body.Bytes()) == 0 {
+		return nil
+	}
+	defer re.Response.Body.Rewind()
+	return bodypkg.JsonUnmarshal(re.Response.Body.Rewind(), value)
+}
 
 var RESTErrorStringLimit = 100
+
+//-------------------------------------------------------------------------------------------------
+
+// ErrorBodyDecoder decodes the entity of an error response into a value that describes the error.
+type ErrorBodyDecoder func(r io.Reader) (any, error)
+
+// ErrorBodyDecoders holds the decoders used for the entities of 4xx and 5xx error responses,
+// keyed by media type (without parameters). The decoded value is available via
+// [RestError.ErrorBody]. By default, only "application/problem+json" (RFC-9457) is decoded,
+// yielding a [*Problem]. Further decoders can be added as required.
+var ErrorBodyDecoders = map[string]ErrorBodyDecoder{
+	ProblemJSON: DecodeProblemJSON,
+}
+
+// ProblemJSON is the media type for RFC-9457 problem details in JSON.
+const ProblemJSON = "application/problem+json"
+
+// Problem holds the problem details for an HTTP API error response.
+// See https://datatracker.ietf.org/doc/html/rfc9457
+type Problem struct {
+	// Type is a URI reference that identifies the problem type; "about:blank" by default.
+	Type string
+	// Title is a short, human-readable summary of the problem type.
+	Title string
+	// Status is the HTTP status code generated by the origin server; it is zero if absent.
+	Status int
+	// Detail is a human-readable explanation specific to this occurrence of the problem.
+	Detail string
+	// Instance is a URI reference that identifies the specific occurrence of the problem.
+	Instance string
+	// Extensions holds any other members of the problem details object.
+	Extensions map[string]any
+}
+
+// String returns the title and detail, separated by a colon if both are present.
+func (p *Problem) String() string {
+	switch {
+	case p.Title == "":
+		return p.Detail
+	case p.Detail == "":
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+// DecodeProblemJSON decodes a JSON problem details object into a [*Problem]. Standard members
+// that have the wrong JSON type are ignored, as required by RFC-9457.
+func DecodeProblemJSON(r io.Reader) (any, error) {
+	var members map[string]any
+	if err := bodypkg.JsonUnmarshal(r, &members); err != nil {
+		return nil, err
+	}
+
+	p := &Problem{Type: "about:blank"}
+
+	for k, v := range members {
+		switch k {
+		case "type":
+			if s, ok := v.(string); ok {
+				p.Type = s
+			}
+		case "title":
+			p.Title, _ = v.(string)
+		case "detail":
+			p.Detail, _ = v.(string)
+		case "instance":
+			p.Instance, _ = v.(string)
+		case "status":
+			p.Status = problemStatus(v)
+		default:
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]any)
+			}
+			p.Extensions[k] = v
+		}
+	}
+
+	return p, nil
+}
+
+func problemStatus(v any) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case interface{ Int64() (int64, error) }: // json.Number
+		i, _ := n.Int64()
+		return int(i)
+	}
+	return 0
+}
@@ -41,3 +41,69 @@ func TestRESTError_Error(t *testing.T) {
 		expect.String(input.Error()).ToBe(t, expected)
 	}
 }
+
+func TestRESTError_problem_details(t *testing.T) {
+	const problem = `{
+ "type": "https://example.com/probs/out-of-credit",
+ "title": "You do not have enough credit.",
+ "status": 403,
+ "detail": "Your current balance is 30, but that costs 50.",
+ "instance": "/account/12345/msgs/abc",
+ "balance": 30
+}`
+	r := Response{
+		StatusCode: 403,
+		Request:    httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil),
+		Type:       header.ContentType{MediaType: ProblemJSON},
+		Body:       body.NewBodyString(problem),
+	}
+
+	re := newRestError(r, nil)
+
+	p := re.Problem()
+	expect.Any(p).Not().ToBeNil(t)
+	expect.String(p.Type).ToBe(t, "https://example.com/probs/out-of-credit")
+	expect.String(p.Title).ToBe(t, "You do not have enough credit.")
+	expect.Number(p.Status).ToBe(t, 403)
+	expect.String(p.Detail).ToBe(t, "Your current balance is 30, but that costs 50.")
+	expect.String(p.Instance).ToBe(t, "/account/12345/msgs/abc")
+	expect.Map(p.Extensions).ToHaveLength(t, 1)
+	expect.String(re.Error()).ToBe(t, "403: GET http://localhost/foo You do not have enough credit.: Your current balance is 30, but that costs 50.")
+	expect.String(re.Body.String()).ToBe(t, problem)
+
+	var raw map[string]any
+	err := re.UnmarshalJSONResponse(&raw)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Map(raw).ToHaveLength(t, 6)
+}
+
+func TestRESTError_problem_details_defaults(t *testing.T) {
+	r := Response{
+		StatusCode: 500,
+		Request:    httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil),
+		Type:       header.ContentType{MediaType: ProblemJSON},
+		Body:       body.NewBodyString(`{"title": 123, "status": "bad"}`),
+	}
+
+	re := newRestError(r, nil)
+
+	p := re.Problem()
+	expect.String(p.Type).ToBe(t, "about:blank")
+	expect.String(p.Title).ToBe(t, "")
+	expect.Number(p.Status).ToBe(t, 0)
+	expect.String(re.Error()).ToBe(t, "500: GET http://localhost/foo application/problem+json {\"title\": 123, \"status\": \"bad\"}")
+}
+
+func TestRESTError_not_a_problem(t *testing.T) {
+	r := Response{
+		StatusCode: 404,
+		Request:    httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil),
+		Type:       header.ContentType{MediaType: "text/plain"},
+		Body:       body.NewBodyString("foo"),
+	}
+
+	re := newRestError(r, nil)
+
+	expect.Any(re.Problem()).ToBeNil(t)
+	expect.Any(re.ErrorBody).ToBeNil(t)
+}
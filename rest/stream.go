@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"io"
+	"os"
+)
+
+// StreamThreshold is the size above which request entities supplied as an [io.ReaderAt] of
+// known size, such as an *os.File, are streamed instead of being buffered in memory (see [Stream]).
+// Smaller entities, and readers of unknown size, are buffered. Set it to a negative value to
+// disable automatic streaming.
+var StreamThreshold int64 = 4 << 20
+
+// Stream is a request entity that is sent without buffering it in memory, which is preferable
+// for large uploads. The content type must be set elsewhere, e.g. using [HeadersKV].
+//
+// If the entity can be re-read (Reopen is set, or Reader is an [io.ReaderAt] of known size such
+// as an *os.File), it can be sent again after an authentication challenge. Otherwise it is sent
+// only once; for deferred authentication (see auth.Deferred), the challenge is first obtained
+// using a HEAD request without any entity.
+type Stream struct {
+	// Reader provides the entity. It is ignored if Reopen is set.
+	Reader io.Reader
+
+	// Reopen, if not nil, is called every time the entity is to be sent and must supply it
+	// from the start.
+	Reopen func() (io.ReadCloser, error)
+
+	// ContentLength is the length of the entity. If it is zero, the length is determined from
+	// Reader where possible (e.g. for an *os.File). If the length is unknown or negative, the
+	// entity is sent using chunked transfer encoding.
+	ContentLength int64
+}
+
+// streamEntity is a Stream that has been prepared for sending.
+type streamEntity struct {
+	first   io.Reader
+	length  int64
+	getBody func() (io.ReadCloser, error) // nil unless the entity can be re-read
+}
+
+func (s Stream) prepare() (*streamEntity, error) {
+	if s.Reopen != nil {
+		length := s.ContentLength
+		if length == 0 {
+			length = -1
+		}
+		return &streamEntity{length: length, getBody: s.Reopen}, nil
+	}
+
+	if ra, offset, size, ok := readerAtSize(s.Reader); ok {
+		return &streamEntity{length: size, getBody: sectionGetter(ra, offset, size)}, nil
+	}
+
+	length := s.ContentLength
+	if length == 0 {
+		length = -1
+	}
+	return &streamEntity{first: s.Reader, length: length}, nil
+}
+
+// open returns the reader for the next attempt to send the entity.
+func (se *streamEntity) open() (io.ReadCloser, error) {
+	if se.getBody != nil {
+		return se.getBody()
+	}
+
+	rdr := se.first
+	se.first = nil
+	if rdr == nil {
+		return nil, io.ErrUnexpectedEOF // already consumed
+	}
+	if rc, ok := rdr.(io.ReadCloser); ok {
+		return rc, nil
+	}
+	return io.NopCloser(rdr), nil
+}
+
+// replayable is true if the entity can be sent more than once.
+func (se *streamEntity) replayable() bool {
+	return se.getBody != nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// readerAtSize determines whether a reader can be read from its current offset repeatedly,
+// and how many bytes remain.
+func readerAtSize(rdr io.Reader) (ra io.ReaderAt, offset, size int64, ok bool) {
+	ra, ok = rdr.(io.ReaderAt)
+	if !ok {
+		return nil, 0, 0, false
+	}
+
+	var total int64
+	switch v := rdr.(type) {
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil, 0, 0, false
+		}
+		total = info.Size()
+	case interface{ Size() int64 }: // e.g. *bytes.Reader, *strings.Reader, *io.SectionReader
+		total = v.Size()
+	default:
+		return nil, 0, 0, false
+	}
+
+	if seeker, isSeeker := rdr.(io.Seeker); isSeeker {
+		offset, _ = seeker.Seek(0, io.SeekCurrent)
+	}
+
+	return ra, offset, max(total-offset, 0), true
+}
+
+func sectionGetter(ra io.ReaderAt, offset, size int64) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(ra, offset, size)), nil
+	}
+}
@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	hdr "github.com/rickb777/acceptable/headername"
+	"github.com/rickb777/expect"
+	"github.com/rickb777/httpclient/auth"
+	bodypkg "github.com/rickb777/httpclient/body"
+	"github.com/rickb777/httpclient/internal/mytesting"
+)
+
+const basicChallenge = `HTTP/1.1 401 Unauthorized
+WWW-Authenticate: Basic realm="WallyWorld"
+
+`
+
+func TestStream_reopen_replayed_after_challenge(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(basicChallenge).ThenWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient),
+		SetAuthentication(auth.Deferred("fred", "password")))
+
+	opened := 0
+	stream := Stream{
+		Reopen: func() (io.ReadCloser, error) {
+			opened++
+			return io.NopCloser(strings.NewReader("hello world")), nil
+		},
+		ContentLength: 11,
+	}
+
+	res, err := cl.Put(context.Background(), "/bar", stream)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusNoContent)
+	expect.Number(opened).ToBe(t, 2)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 2)
+	expect.Number(testClient.Captured[1].ContentLength).ToBe(t, 11)
+	expect.String(testClient.Captured[1].Header.Get(hdr.ContentLength)).ToBe(t, "11")
+	expect.String(testClient.Captured[1].Header.Get(hdr.Authorization)).ToBe(t, "Basic ZnJlZDpwYXNzd29yZA==")
+	expect.String(testClient.Captured[1].Body.(*bodypkg.Body).String()).ToBe(t, "hello world")
+}
+
+func TestStream_one_shot_uses_preflight(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(basicChallenge).ThenWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient),
+		SetAuthentication(auth.Deferred("fred", "password")))
+
+	rdr := io.MultiReader(strings.NewReader("hello "), strings.NewReader("world"))
+	res, err := cl.Post(context.Background(), "/bar", Stream{Reader: rdr})
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusNoContent)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 2)
+	expect.String(testClient.Captured[0].Method).ToBe(t, http.MethodHead)
+	expect.String(testClient.Captured[1].Method).ToBe(t, http.MethodPost)
+	expect.Number(testClient.Captured[1].ContentLength).ToBe(t, -1)
+	expect.String(testClient.Captured[1].Header.Get(hdr.ContentLength)).ToBe(t, "")
+	expect.String(testClient.Captured[1].Header.Get(hdr.Authorization)).ToBe(t, "Basic ZnJlZDpwYXNzd29yZA==")
+	expect.String(testClient.Captured[1].Body.(*bodypkg.Body).String()).ToBe(t, "hello world")
+}
+
+func TestStream_one_shot_not_repeated(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(basicChallenge)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	rdr := io.MultiReader(strings.NewReader("hello world"))
+	res, err := cl.Request(context.Background(), http.MethodPost, "/bar", Stream{Reader: rdr})
+
+	expect.Error(err).ToContain(t, "Authorize")
+	expect.Number(res.StatusCode).ToBe(t, http.StatusUnauthorized)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 1)
+}
+
+func TestStream_large_file_is_streamed(t *testing.T) {
+	defer func(v int64) { StreamThreshold = v }(StreamThreshold)
+	StreamThreshold = 4
+
+	name := filepath.Join(t.TempDir(), "upload.txt")
+	expect.Error(os.WriteFile(name, []byte("hello world"), 0644)).Not().ToHaveOccurred(t)
+	f, err := os.Open(name)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	defer f.Close()
+	_, _ = f.Seek(6, io.SeekStart)
+
+	testClient := mytesting.StubHttpWithBody(basicChallenge).ThenWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient),
+		SetAuthentication(auth.Deferred("fred", "password")))
+
+	_, err = cl.Put(context.Background(), "/bar", f)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Slice(testClient.Captured).ToHaveLength(t, 2)
+	expect.String(testClient.Captured[0].Method).ToBe(t, http.MethodPut)
+	expect.Number(testClient.Captured[1].ContentLength).ToBe(t, 5)
+	expect.String(testClient.Captured[1].Body.(*bodypkg.Body).String()).ToBe(t, "world")
+}
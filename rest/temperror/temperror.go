@@ -2,8 +2,10 @@ package temperror
 
 import (
 	"errors"
+	"io"
 	"net"
 	"os"
+	"syscall"
 )
 
 // NetworkConnectionError tests an error to see whether it
@@ -25,3 +27,21 @@ func NetworkConnectionError(err error) (matched bool) {
 
 	return false
 }
+
+// InterruptedTransferError tests an error to see whether it was caused by
+// an established connection failing part-way through a transfer, e.g. because
+// the connection was reset or closed prematurely. Depending on context, the
+// transfer could be resumed.
+func InterruptedTransferError(err error) (matched bool) {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var opError = &net.OpError{}
+	if errors.As(err, &opError) && (opError.Op == "read" || opError.Op == "write") {
+		return true
+	}
+
+	return false
+}
@@ -2,7 +2,11 @@ package temperror
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net"
+	"syscall"
 	"testing"
 	"time"
 
@@ -32,3 +36,19 @@ func TestConnect_failed(t *testing.T) {
 	matched := NetworkConnectionError(err)
 	expect.Bool(matched).ToBeTrue(t)
 }
+
+func TestInterruptedTransferError(t *testing.T) {
+	cases := map[error]bool{
+		io.ErrUnexpectedEOF:                               true,
+		fmt.Errorf("copy: %w", syscall.ECONNRESET):        true,
+		&net.OpError{Op: "read", Err: errors.New("bang")}: true,
+		&net.OpError{Op: "dial", Err: errors.New("bang")}: false,
+		io.EOF:                   false,
+		errors.New("bang"):       false,
+		context.DeadlineExceeded: false,
+	}
+
+	for err, expected := range cases {
+		expect.Bool(InterruptedTransferError(err)).I(err.Error()).ToBe(t, expected)
+	}
+}
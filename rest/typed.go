@@ -0,0 +1,148 @@
+package rest
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rickb777/acceptable/contenttype"
+	hdr "github.com/rickb777/acceptable/headername"
+	bodypkg "github.com/rickb777/httpclient/body"
+)
+
+// EntityDecoder decodes a response entity into some output value, which will be a pointer.
+type EntityDecoder func(r io.Reader, output any) error
+
+// EntityDecoders holds the decoders used by [Do] and related functions, keyed by media type
+// (without parameters). Media types using a structured syntax suffix such as "+json" or "+xml"
+// fall back to the "application/json" or "application/xml" decoders respectively (see RFC-6839).
+// Further decoders can be added as required.
+var EntityDecoders = map[string]EntityDecoder{
+	contenttype.ApplicationJSON: func(r io.Reader, output any) error { return bodypkg.JsonUnmarshal(r, output) },
+	contenttype.ApplicationXML:  xmlUnmarshal,
+	"text/xml":                  xmlUnmarshal,
+}
+
+func xmlUnmarshal(r io.Reader, output any) error {
+	return xml.NewDecoder(r).Decode(output)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// DecodeError is returned when a response was received successfully but its entity could not be
+// decoded into the required type. The buffered response is retained so that the raw entity
+// can be inspected or logged.
+type DecodeError struct {
+	Response
+	Cause error
+}
+
+// Error makes it compatible with `error` interface.
+func (de *DecodeError) Error() string {
+	if de.Request == nil {
+		return fmt.Sprintf(`%d: %s decode failed: %v`, de.StatusCode, de.Type, de.Cause)
+	}
+	return fmt.Sprintf(`%d: %s %s %s decode failed: %v`, de.StatusCode, de.Request.Method, de.Request.URL, de.Type, de.Cause)
+}
+
+func (de *DecodeError) Unwrap() error {
+	return de.Cause
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Do performs a request using [RestClient.Request] and decodes the response entity into a value of
+// type T according to the response Content-Type (see [EntityDecoders]). If T is string or []byte,
+// the entity is returned verbatim. An empty entity (e.g. from 204 No Content) yields the zero value.
+//
+// The buffered response is always returned when available. HTTP errors are reported as [*RestError]
+// as for [RestClient.Get] etc, whereas entities that cannot be decoded are reported as [*DecodeError].
+func Do[T any](ctx context.Context, c RestClient, method, path string, reqBody any, opts ...ReqOpt) (T, *Response, error) {
+	var value T
+
+	res, err := responseOf(c.Request(ctx, method, path, reqBody, opts...))
+	if err != nil {
+		return value, res, err
+	}
+
+	err = decodeEntity(res, &value)
+	if err != nil {
+		return value, res, &DecodeError{Response: *res, Cause: err}
+	}
+
+	return value, res, nil
+}
+
+// GetJSON performs a GET request and decodes the response entity into a value of type T.
+// The Accept header is set to "application/json" unless already set by the client or by opts.
+// See [Do].
+func GetJSON[T any](ctx context.Context, c RestClient, path string, opts ...ReqOpt) (T, *Response, error) {
+	return Do[T](ctx, c, http.MethodGet, path, nil, acceptJSON(opts)...)
+}
+
+// PostJSON performs a POST request with a JSON request entity and decodes the response entity into
+// a value of type Res. The Accept header is set to "application/json" unless already set by the
+// client or by opts. See [Do].
+func PostJSON[Req, Res any](ctx context.Context, c RestClient, path string, reqBody Req, opts ...ReqOpt) (Res, *Response, error) {
+	return Do[Res](ctx, c, http.MethodPost, path, reqBody, acceptJSON(opts)...)
+}
+
+// PutJSON performs a PUT request with a JSON request entity and decodes the response entity into
+// a value of type Res. The Accept header is set to "application/json" unless already set by the
+// client or by opts. See [Do].
+func PutJSON[Req, Res any](ctx context.Context, c RestClient, path string, reqBody Req, opts ...ReqOpt) (Res, *Response, error) {
+	return Do[Res](ctx, c, http.MethodPut, path, reqBody, acceptJSON(opts)...)
+}
+
+func acceptJSON(opts []ReqOpt) []ReqOpt {
+	return append(opts, func(req *http.Request) {
+		if req.Header.Get(hdr.Accept) == "" {
+			req.Header.Set(hdr.Accept, contenttype.ApplicationJSON)
+		}
+	})
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func decodeEntity(res *Response, output any) error {
+	if len(res.Body.Bytes()) == 0 {
+		return nil
+	}
+
+	switch v := output.(type) {
+	case *string:
+		*v = res.Body.String()
+		return nil
+	case *[]byte:
+		*v = res.Body.Bytes()
+		return nil
+	}
+
+	decoder := findDecoder(res.Type.MediaType)
+	if decoder == nil {
+		return fmt.Errorf("unsupported content type %q", res.Type.MediaType)
+	}
+
+	return decoder(res.Body.Rewind(), output)
+}
+
+func findDecoder(mediaType string) EntityDecoder {
+	mediaType = strings.ToLower(mediaType)
+	if decoder, exists := EntityDecoders[mediaType]; exists {
+		return decoder
+	}
+
+	if plus := strings.LastIndexByte(mediaType, '+'); plus >= 0 {
+		switch mediaType[plus+1:] {
+		case "json":
+			return EntityDecoders[contenttype.ApplicationJSON]
+		case "xml":
+			return EntityDecoders[contenttype.ApplicationXML]
+		}
+	}
+
+	return nil
+}
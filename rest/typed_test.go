@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/rickb777/acceptable/contenttype"
+	hdr "github.com/rickb777/acceptable/headername"
+	"github.com/rickb777/expect"
+	"github.com/rickb777/httpclient/internal/mytesting"
+)
+
+func TestGetJSON(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Content-Length: 21
+
+{"A":"hello","B":10}
+`)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	v, res, err := GetJSON[data](context.Background(), cl, "/bar")
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Any(v).ToBe(t, data{A: "hello", B: 10})
+	expect.Number(res.StatusCode).ToBe(t, http.StatusOK)
+	expect.String(testClient.Captured[0].Method).ToBe(t, http.MethodGet)
+	expect.String(testClient.Captured[0].Header.Get(hdr.Accept)).ToBe(t, contenttype.ApplicationJSON)
+}
+
+func TestPostJSON(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 201 Created
+Content-Type: application/vnd.example+json
+Content-Length: 21
+
+{"A":"hello","B":11}
+`)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	v, res, err := PostJSON[data, *data](context.Background(), cl, "/bar", data{A: "hello", B: 10},
+		HeadersKV(hdr.Accept, "application/vnd.example+json"))
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Any(*v).ToBe(t, data{A: "hello", B: 11})
+	expect.Number(res.StatusCode).ToBe(t, http.StatusCreated)
+	expect.String(testClient.Captured[0].Method).ToBe(t, http.MethodPost)
+	expect.String(testClient.Captured[0].Header.Get(hdr.Accept)).ToBe(t, "application/vnd.example+json")
+	expect.String(testClient.Captured[0].Header.Get(hdr.ContentType)).ToBe(t, contenttype.ApplicationJSON)
+}
+
+func TestDo_xml_and_text(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 200 OK
+Content-Type: application/xml
+Content-Length: 30
+
+<data><A>hi</A><B>3</B></data>
+`).ThenWithBody(`HTTP/1.1 200 OK
+Content-Type: text/plain
+Content-Length: 6
+
+hello
+`)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	v1, _, err := Do[data](context.Background(), cl, http.MethodGet, "/x", nil)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Any(v1).ToBe(t, data{A: "hi", B: 3})
+
+	v2, _, err := Do[string](context.Background(), cl, http.MethodGet, "/y", nil)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.String(v2).ToBe(t, "hello\n")
+}
+
+func TestDo_no_content(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody("HTTP/1.1 204 No Content\n\n")
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	v, res, err := PutJSON[data, *data](context.Background(), cl, "/bar", data{A: "hello"})
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Any(v).ToBeNil(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusNoContent)
+}
+
+func TestDo_decode_error(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 200 OK
+Content-Type: application/json
+Content-Length: 9
+
+not json
+`)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	_, res, err := GetJSON[data](context.Background(), cl, "/bar")
+
+	var de *DecodeError
+	expect.Bool(errors.As(err, &de)).ToBeTrue(t)
+	expect.String(de.Body.String()).ToBe(t, "not json\n")
+	expect.String(err.Error()).ToContain(t, "200: GET http://example.test/foo/bar application/json decode failed")
+	expect.Number(res.StatusCode).ToBe(t, http.StatusOK)
+}
+
+func TestDo_unsupported_content_type(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 200 OK
+Content-Type: image/png
+Content-Length: 4
+
+PNG
+`)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	_, _, err := GetJSON[data](context.Background(), cl, "/bar")
+
+	var de *DecodeError
+	expect.Bool(errors.As(err, &de)).ToBeTrue(t)
+	expect.Error(err).ToContain(t, `unsupported content type "image/png"`)
+}
+
+func TestDo_rest_error(t *testing.T) {
+	testClient := mytesting.StubHttpWithBody(`HTTP/1.1 404 Not Found
+Content-Type: text/plain
+Content-Length: 8
+
+missing
+`)
+	cl := NewClient("http://example.test/foo", SetHttpClient(testClient))
+
+	_, res, err := GetJSON[data](context.Background(), cl, "/bar")
+
+	var re *RestError
+	expect.Bool(errors.As(err, &re)).ToBeTrue(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusNotFound)
+}
@@ -1,32 +1,196 @@
 package retry
 
 import (
+	"github.com/cenkalti/backoff/v4"
 	"github.com/rickb777/httpclient"
+	"github.com/rickb777/httpclient/rest/temperror"
 	"github.com/rs/zerolog"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // retry is an HTTP client decorator that retries each request on network error.
 type retry struct {
-	inner httpclient.HttpClient
-	cfg   RetryConfig
-	lgr   zerolog.Logger
+	inner    httpclient.HttpClient
+	cfg      RetryConfig
+	notifier Notifier
 }
 
 // New creates an HTTP client decorator that retries each request on network error.
+// Retries and failures are logged using lgr, which replaces any Notifier in the config.
+// See [Wrap].
 func New(inner httpclient.HttpClient, cfg RetryConfig, lgr zerolog.Logger) httpclient.HttpClient {
+	cfg.Notifier = ZerologNotifier(lgr)
+	return Wrap(inner, cfg)
+}
+
+// Wrap creates an HTTP client decorator that retries each request on network error.
+// Each unsuccessful attempt is reported to the Notifier in the config.
+//
+// Requests that fail to connect are retried. Idempotent requests (see [Idempotent]) are also
+// retried when the connection fails part-way through, or when the response status is one of the
+// RetryStatuses in the config; the Retry-After header is honoured in this case. Requests that
+// have an entity are never retried, not even after a connection failure, unless the entity can
+// be re-read using http.Request.GetBody; this is because the transport closes the entity whenever
+// a request fails. Waiting between attempts stops if the request context is cancelled.
+func Wrap(inner httpclient.HttpClient, cfg RetryConfig) httpclient.HttpClient {
 	return &retry{
-		inner: inner,
-		cfg:   cfg,
-		lgr:   lgr,
+		inner:    inner,
+		cfg:      cfg,
+		notifier: cfg.notifier(),
+	}
+}
+
+func (r *retry) Do(req *http.Request) (*http.Response, error) {
+	boff := newBackOff(r.cfg)
+	idempotent := Idempotent(req)
+	rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	var response *http.Response
+	var previous *http.Request
+	attempt := 0
+
+	err := backoff.RetryNotify(
+		func() (err error) {
+			attempt++
+			if response != nil {
+				discard(response) // the previous attempt is being retried
+				response = nil
+			}
+
+			outbound, err := rewind(req, previous, attempt)
+			if err != nil {
+				return backoff.Permanent(err)
+			}
+			previous = outbound
+
+			response, err = r.inner.Do(outbound)
+			if response != nil && response.Request != nil {
+				previous = response.Request // the last request sent, including any decorations
+			}
+			if err != nil {
+				if !rewindable {
+					return backoff.Permanent(err)
+				}
+				if _, is := NetworkConnectionError(err); is {
+					return err
+				}
+				if idempotent && temperror.InterruptedTransferError(err) {
+					return err
+				}
+				return backoff.Permanent(err)
+			}
+
+			if !idempotent || !rewindable || !r.cfg.retryStatus(response.StatusCode) {
+				return nil
+			}
+
+			delay, _ := RetryAfter(response.Header, time.Now())
+			if delay > r.cfg.maxRetryAfter() {
+				return nil // the server is unavailable for too long
+			}
+			boff.delay = delay
+			return statusError{code: response.StatusCode, status: response.Status}
+		},
+		backoff.WithContext(boff, req.Context()),
+		func(err error, next time.Duration) {
+			r.notifier.Retrying(r.event(req, attempt, err, next))
+		},
+	)
+
+	if err != nil {
+		r.notifier.GaveUp(r.event(req, attempt, err, 0))
+	}
+
+	if _, is := err.(statusError); is {
+		return response, nil // retries exhausted; the last response is returned as-is
+	}
+
+	if err != nil {
+		if response != nil {
+			discard(response)
+		}
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (r *retry) event(req *http.Request, attempt int, err error, next time.Duration) Event {
+	e := Event{Target: req.URL.String(), Request: req, Attempt: attempt, Err: err, Next: next}
+	if se, is := err.(statusError); is {
+		e.Err = nil
+		e.StatusCode = se.code
+	}
+	return e
+}
+
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e statusError) Error() string {
+	return e.status
+}
+
+// rewind prepares the request for each attempt, obtaining a fresh copy of the entity if needed.
+// Each retry's context refers to the previous attempt (see httpclient.Previous).
+func rewind(req, previous *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 {
+		return req, nil
+	}
+
+	outbound := req.Clone(httpclient.WithPrevious(req.Context(), previous))
+	if req.Body == nil || req.Body == http.NoBody {
+		return outbound, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	outbound.Body = body
+	return outbound, nil
+}
+
+// discard drains and closes a response that will not be used, allowing the connection to be reused.
+func discard(res *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	_ = res.Body.Close()
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Idempotent is true if the request can safely be sent more than once (see RFC-9110 section 9.2.2).
+// This is so for the GET, HEAD, OPTIONS, TRACE, PUT and DELETE methods, and for other requests
+// that have an "Idempotency-Key" header.
+func Idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
 	}
+	return req.Header.Get("Idempotency-Key") != ""
 }
 
-func (r *retry) Do(req *http.Request) (response *http.Response, err error) {
-	err = NewExponentialBackOff(r.cfg, req.URL.String(), r.lgr,
-		func() error {
-			response, err = r.inner.Do(req)
-			return err
-		})
-	return response, err
+// RetryAfter gets the delay requested by the Retry-After header, which holds either a number
+// of seconds or an HTTP date (see RFC-9110 section 10.2.3).
+func RetryAfter(header http.Header, now time.Time) (delay time.Duration, ok bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
 }
@@ -0,0 +1,135 @@
+package retry
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Event describes an unsuccessful attempt to connect or to make a request.
+type Event struct {
+	// Target is the URL or address being contacted.
+	Target string
+
+	// Request is the request being retried; it is nil for connection attempts made via
+	// [NewExponentialBackOff] or [Connect].
+	Request *http.Request
+
+	// Attempt counts the attempts so far, starting at 1.
+	Attempt int
+
+	// Err is the error from the attempt, or nil if the response status is being retried.
+	Err error
+
+	// StatusCode is the response status, or zero if there was an error instead.
+	StatusCode int
+
+	// Next is the delay before the next attempt; it is zero when giving up.
+	Next time.Duration
+}
+
+// Notifier receives events as requests are retried. It can be used for logging and metrics.
+type Notifier interface {
+	// Retrying is called after each unsuccessful attempt that will be retried.
+	Retrying(Event)
+
+	// GaveUp is called after the last unsuccessful attempt.
+	GaveUp(Event)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// NoopNotifier is a Notifier that does nothing.
+type NoopNotifier struct{}
+
+func (NoopNotifier) Retrying(Event) {}
+func (NoopNotifier) GaveUp(Event)   {}
+
+//-------------------------------------------------------------------------------------------------
+
+type zerologNotifier struct {
+	lgr zerolog.Logger
+}
+
+// ZerologNotifier creates a Notifier that logs retries as warnings and failures as errors.
+func ZerologNotifier(lgr zerolog.Logger) Notifier {
+	return zerologNotifier{lgr: lgr}
+}
+
+func (zn zerologNotifier) Retrying(e Event) {
+	zn.event(zn.lgr.Warn(), e).
+		Stringer("next_retry", e.Next.Truncate(time.Millisecond)).
+		Msg(retryingMessage(e))
+}
+
+func (zn zerologNotifier) GaveUp(e Event) {
+	zn.event(zn.lgr.Error(), e).Msg(gaveUpMessage(e))
+}
+
+// event adds the same fields as slogNotifier.log.
+func (zn zerologNotifier) event(ev *zerolog.Event, e Event) *zerolog.Event {
+	ev = ev.Str("target", e.Target).Int("attempt", e.Attempt)
+	if e.StatusCode != 0 {
+		ev = ev.Int("status", e.StatusCode)
+	}
+	return ev.Err(e.Err)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+type slogNotifier struct {
+	lgr *slog.Logger
+}
+
+// SlogNotifier creates a Notifier that logs retries as warnings and failures as errors.
+// If lgr is nil, slog.Default() is used.
+func SlogNotifier(lgr *slog.Logger) Notifier {
+	if lgr == nil {
+		lgr = slog.Default()
+	}
+	return slogNotifier{lgr: lgr}
+}
+
+func (sn slogNotifier) Retrying(e Event) {
+	sn.log(slog.LevelWarn, retryingMessage(e), e,
+		slog.Duration("next_retry", e.Next.Truncate(time.Millisecond)))
+}
+
+func (sn slogNotifier) GaveUp(e Event) {
+	sn.log(slog.LevelError, gaveUpMessage(e), e)
+}
+
+func (sn slogNotifier) log(level slog.Level, msg string, e Event, extra ...slog.Attr) {
+	ctx := context.Background()
+	if e.Request != nil {
+		ctx = e.Request.Context()
+	}
+
+	attrs := []slog.Attr{slog.String("target", e.Target), slog.Int("attempt", e.Attempt)}
+	if e.StatusCode != 0 {
+		attrs = append(attrs, slog.Int("status", e.StatusCode))
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+
+	sn.lgr.LogAttrs(ctx, level, msg, append(attrs, extra...)...)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func retryingMessage(e Event) string {
+	if e.Request == nil {
+		return "Failed to open connection"
+	}
+	return "Retrying request"
+}
+
+func gaveUpMessage(e Event) string {
+	if e.Request == nil {
+		return "Connection failed"
+	}
+	return "Request failed"
+}
@@ -0,0 +1,103 @@
+package retry_test
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rickb777/expect"
+	"github.com/rickb777/httpclient/retry"
+	"github.com/rickb777/httpclient/testhttpclient"
+	"github.com/rs/zerolog"
+)
+
+type recorder struct {
+	mu       sync.Mutex
+	retrying []retry.Event
+	gaveUp   []retry.Event
+}
+
+func (r *recorder) Retrying(e retry.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retrying = append(r.retrying, e)
+}
+
+func (r *recorder) GaveUp(e retry.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gaveUp = append(r.gaveUp, e)
+}
+
+func TestWrap_notifies_events(t *testing.T) {
+	e1 := &net.OpError{Op: "dial", Err: errors.New("bang")}
+	stub := testhttpclient.New(t).
+		AddError("GET", "http://localhost/foo", e1).
+		AddResponse("GET", "http://localhost/foo", testhttpclient.MockResponse(503, nil, "")).
+		AddResponse("GET", "http://localhost/foo", testhttpclient.MockResponse(503, nil, ""))
+
+	rec := &recorder{}
+	r := retry.Wrap(stub, retry.RetryConfig{ConnectTries: 2, RetryStatuses: []int{503}, Notifier: rec})
+
+	res, err := r.Do(httptest.NewRequest("GET", "http://localhost/foo", nil))
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, 503)
+
+	expect.Slice(rec.retrying).ToHaveLength(t, 2)
+	expect.Any(rec.retrying[0].Err).ToBe(t, e1)
+	expect.Number(rec.retrying[0].Attempt).ToBe(t, 1)
+	expect.Number(rec.retrying[0].Next).ToBeGreaterThan(t, 0)
+	expect.Number(rec.retrying[1].StatusCode).ToBe(t, 503)
+	expect.Any(rec.retrying[1].Err).ToBeNil(t)
+	expect.String(rec.retrying[1].Target).ToBe(t, "http://localhost/foo")
+
+	expect.Slice(rec.gaveUp).ToHaveLength(t, 1)
+	expect.Number(rec.gaveUp[0].Attempt).ToBe(t, 3)
+	expect.Number(rec.gaveUp[0].StatusCode).ToBe(t, 503)
+}
+
+func TestSlogNotifier(t *testing.T) {
+	buf := &strings.Builder{}
+	lgr := slog.New(slog.NewJSONHandler(buf, nil))
+
+	err := retry.Connect(retry.RetryConfig{ConnectTries: 1, Notifier: retry.SlogNotifier(lgr)}, "TGT",
+		func() error {
+			return &net.OpError{Op: "dial", Err: errors.New("bang")}
+		})
+
+	expect.Error(err).ToHaveOccurred(t)
+	msg := buf.String()
+	expect.String(msg).ToContain(t, `"level":"WARN","msg":"Failed to open connection","target":"TGT","attempt":1,"error":"dial: bang"`)
+	expect.String(msg).ToContain(t, `"level":"ERROR","msg":"Connection failed","target":"TGT","attempt":2,"error":"dial: bang"`)
+}
+
+func TestZerologNotifier(t *testing.T) {
+	buf := &strings.Builder{}
+	lgr := zerolog.New(buf)
+
+	err := retry.Connect(retry.RetryConfig{ConnectTries: 1, Notifier: retry.ZerologNotifier(lgr)}, "TGT",
+		func() error {
+			return &net.OpError{Op: "dial", Err: errors.New("bang")}
+		})
+
+	expect.Error(err).ToHaveOccurred(t)
+	msg := buf.String()
+	expect.String(msg).ToContain(t, `{"level":"warn","target":"TGT","attempt":1,"error":"dial: bang","next_retry":`)
+	expect.String(msg).ToContain(t, `{"level":"error","target":"TGT","attempt":2,"error":"dial: bang","message":"Connection failed"}`)
+}
+
+func TestNoopNotifier(t *testing.T) {
+	count := 0
+	err := retry.Connect(retry.RetryConfig{ConnectTries: 1, Notifier: retry.NoopNotifier{}}, "TGT",
+		func() error {
+			count++
+			return errors.New("permanent")
+		})
+
+	expect.Error(err).ToHaveOccurred(t)
+	expect.Number(count).ToBe(t, 1)
+}
@@ -14,23 +14,71 @@ import (
 type RetryConfig struct {
 	ConnectTimeout time.Duration // give up after this
 	ConnectTries   int
+
+	// RetryStatuses lists the response status codes that cause a request to be retried,
+	// e.g. DefaultRetryStatuses. By default, no responses are retried.
+	RetryStatuses []int
+
+	// MaxRetryAfter limits the delay that a server can request using the Retry-After header.
+	// If the server asks for a longer delay, its response is returned without retrying.
+	// Zero means DefaultMaxRetryAfter.
+	MaxRetryAfter time.Duration
+
+	// Notifier receives an event for each unsuccessful attempt, e.g. for logging or metrics.
+	// If nil, events are discarded.
+	Notifier Notifier
+}
+
+// DefaultRetryStatuses lists the status codes that are usually worth retrying:
+// 429 Too Many Requests, 502 Bad Gateway, 503 Service Unavailable and 504 Gateway Timeout.
+var DefaultRetryStatuses = []int{429, 502, 503, 504}
+
+// DefaultMaxRetryAfter is the default limit on delays requested using Retry-After.
+const DefaultMaxRetryAfter = 2 * time.Minute
+
+func (cfg RetryConfig) retryStatus(code int) bool {
+	for _, s := range cfg.RetryStatuses {
+		if s == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg RetryConfig) notifier() Notifier {
+	if cfg.Notifier != nil {
+		return cfg.Notifier
+	}
+	return NoopNotifier{}
+}
+
+func (cfg RetryConfig) maxRetryAfter() time.Duration {
+	if cfg.MaxRetryAfter > 0 {
+		return cfg.MaxRetryAfter
+	}
+	return DefaultMaxRetryAfter
 }
 
 // NewExponentialBackOff runs some connect function repeatedly until it returns without
 // error or returns with an error considered permanent (i.e. not a network error).
+// Retries and failures are logged using lgr, which replaces any Notifier in the config.
 //
 // It is based on github.com/cenkalti/backoff/v4 backoff.ExponentialBackOff
 func NewExponentialBackOff(cfg RetryConfig, target string, lgr zerolog.Logger, connect func() error) error {
-	exponentialBackOff := backoff.NewExponentialBackOff()
-	exponentialBackOff.MaxElapsedTime = cfg.ConnectTimeout
+	cfg.Notifier = ZerologNotifier(lgr)
+	return Connect(cfg, target, connect)
+}
 
-	var boff backoff.BackOff = exponentialBackOff
-	if cfg.ConnectTries > 0 {
-		boff = backoff.WithMaxRetries(exponentialBackOff, uint64(cfg.ConnectTries))
-	}
+// Connect runs some connect function repeatedly until it returns without error or returns
+// with an error considered permanent (i.e. not a network error). Each unsuccessful attempt
+// is reported to the Notifier in the config.
+func Connect(cfg RetryConfig, target string, connect func() error) error {
+	notifier := cfg.notifier()
+	attempt := 0
 
 	err := backoff.RetryNotify(
 		func() error {
+			attempt++
 			e2 := connect()
 			if e2 != nil {
 				if opError, is := NetworkConnectionError(e2); is {
@@ -43,17 +91,14 @@ func NewExponentialBackOff(cfg RetryConfig, target string, lgr zerolog.Logger, c
 			}
 			return nil
 		},
-		boff,
+		newBackOff(cfg),
 		func(err error, next time.Duration) {
-			lgr.Warn().Str("target", target).
-				Stringer("next_retry", next.Truncate(time.Millisecond)).
-				AnErr("error", err).
-				Msg("Failed to open connection")
+			notifier.Retrying(Event{Target: target, Attempt: attempt, Err: err, Next: next})
 		},
 	)
 
 	if err != nil {
-		lgr.Error().Str("target", target).Err(err).Msg("Connection failed")
+		notifier.GaveUp(Event{Target: target, Attempt: attempt, Err: err})
 		return err
 	}
 
@@ -66,3 +111,32 @@ func NetworkConnectionError(err error) (opError *net.OpError, matched bool) {
 	}
 	return nil, false
 }
+
+//-------------------------------------------------------------------------------------------------
+
+func newBackOff(cfg RetryConfig) *minimumDelay {
+	exponentialBackOff := backoff.NewExponentialBackOff()
+	exponentialBackOff.MaxElapsedTime = cfg.ConnectTimeout
+
+	var boff backoff.BackOff = exponentialBackOff
+	if cfg.ConnectTries > 0 {
+		boff = backoff.WithMaxRetries(exponentialBackOff, uint64(cfg.ConnectTries))
+	}
+
+	return &minimumDelay{BackOff: boff}
+}
+
+// minimumDelay allows the next back-off to be lengthened, e.g. as requested by Retry-After.
+type minimumDelay struct {
+	backoff.BackOff
+	delay time.Duration
+}
+
+func (md *minimumDelay) NextBackOff() time.Duration {
+	next := md.BackOff.NextBackOff()
+	if next != backoff.Stop && md.delay > next {
+		next = md.delay
+	}
+	md.delay = 0
+	return next
+}
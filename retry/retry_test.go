@@ -1,15 +1,19 @@
 package retry_test
 
 import (
+	"context"
 	"errors"
 	"github.com/rickb777/expect"
 	"github.com/rickb777/httpclient/retry"
 	"github.com/rickb777/httpclient/testhttpclient"
 	"github.com/rs/zerolog"
+	"io"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"net/http/httptest"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -70,3 +74,151 @@ func TestNewExponentialBackOff(t *testing.T) {
 	expect.String(msg).ToContain(t, `"error":"dial: bang"`)
 	expect.String(msg).ToContain(t, `"message":"Failed to open connection"`)
 }
+
+func TestRetry_Get_503_with_Retry_After_then_200(t *testing.T) {
+	lgrBuf := &strings.Builder{}
+	lgr := zerolog.New(lgrBuf)
+	busy := testhttpclient.MockResponse(503, []byte("busy"), "text/plain")
+	busy.Header.Set("Retry-After", "0")
+
+	stub := testhttpclient.New(t).
+		AddResponse("GET", "http://localhost/foo", busy).
+		AddResponse("GET", "http://localhost/foo", testhttpclient.MockResponse(200, []byte("OK"), ""))
+
+	r := retry.New(stub, retry.RetryConfig{RetryStatuses: retry.DefaultRetryStatuses}, lgr)
+
+	res, err := r.Do(httptest.NewRequest("GET", "http://localhost/foo", nil))
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, 200)
+	expect.Slice(stub.RemainingOutcomes()).ToBeEmpty(t)
+	expect.String(lgrBuf.String()).ToContain(t, `"message":"Retrying request"`)
+}
+
+func TestRetry_Get_503_retries_exhausted(t *testing.T) {
+	lgr := zerolog.New(ioutil.Discard)
+	stub := testhttpclient.New(t).
+		AddResponse("GET", "http://localhost/foo", testhttpclient.MockResponse(503, []byte("busy"), "")).
+		AddResponse("GET", "http://localhost/foo", testhttpclient.MockResponse(503, []byte("still busy"), ""))
+
+	r := retry.New(stub, retry.RetryConfig{ConnectTries: 1, RetryStatuses: []int{503}}, lgr)
+
+	res, err := r.Do(httptest.NewRequest("GET", "http://localhost/foo", nil))
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, 503)
+	b, _ := io.ReadAll(res.Body)
+	expect.String(string(b)).ToContain(t, "still busy")
+	expect.Slice(stub.RemainingOutcomes()).ToBeEmpty(t)
+}
+
+func TestRetry_Retry_After_too_long(t *testing.T) {
+	lgr := zerolog.New(ioutil.Discard)
+	busy := testhttpclient.MockResponse(429, nil, "")
+	busy.Header.Set("Retry-After", "3600")
+
+	stub := testhttpclient.New(t).
+		AddResponse("GET", "http://localhost/foo", busy)
+
+	r := retry.New(stub, retry.RetryConfig{RetryStatuses: retry.DefaultRetryStatuses}, lgr)
+
+	res, err := r.Do(httptest.NewRequest("GET", "http://localhost/foo", nil))
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, 429)
+}
+
+func TestRetry_Post_not_retried_unless_idempotent(t *testing.T) {
+	lgr := zerolog.New(ioutil.Discard)
+	stub := testhttpclient.New(t).
+		AddResponse("POST", "http://localhost/foo", testhttpclient.MockResponse(503, nil, "")).
+		AddError("POST", "http://localhost/foo", syscall.ECONNRESET)
+
+	r := retry.New(stub, retry.RetryConfig{RetryStatuses: []int{503}}, lgr)
+
+	res, err := r.Do(httptest.NewRequest("POST", "http://localhost/foo", nil))
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, 503)
+
+	_, err = r.Do(httptest.NewRequest("POST", "http://localhost/foo", nil))
+	expect.Any(err).ToBe(t, syscall.ECONNRESET)
+
+	req := httptest.NewRequest("POST", "http://localhost/foo", nil)
+	req.Header.Set("Idempotency-Key", "abc123")
+	stub.AddError("POST", "http://localhost/foo", syscall.ECONNRESET)
+	stub.AddResponse("POST", "http://localhost/foo", testhttpclient.MockResponse(201, nil, ""))
+	res, err = r.Do(req)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, 201)
+	expect.Slice(stub.RemainingOutcomes()).ToBeEmpty(t)
+}
+
+func TestRetry_Put_rewinds_body(t *testing.T) {
+	var bodies []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(503)
+			return
+		}
+		w.WriteHeader(204)
+	}))
+	defer svr.Close()
+
+	r := retry.New(http.DefaultClient, retry.RetryConfig{RetryStatuses: []int{503}}, zerolog.Nop())
+
+	req, _ := http.NewRequest("PUT", svr.URL, strings.NewReader("hello"))
+	res, err := r.Do(req)
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, 204)
+	expect.Slice(bodies).ToBe(t, "hello", "hello")
+}
+
+func TestRetry_connect_error_not_retried_unless_rewindable(t *testing.T) {
+	e1 := &net.OpError{Op: "dial", Err: errors.New("bang")}
+	stub := testhttpclient.New(t).
+		AddError("PUT", "http://localhost/foo", e1)
+
+	r := retry.New(stub, retry.RetryConfig{}, zerolog.Nop())
+
+	req, _ := http.NewRequest("PUT", "http://localhost/foo", io.MultiReader(strings.NewReader("hello")))
+	_, err := r.Do(req)
+	expect.Any(err).ToBe(t, e1)
+	expect.Slice(stub.RemainingOutcomes()).ToBeEmpty(t)
+}
+
+func TestRetry_context_cancelled_during_back_off(t *testing.T) {
+	busy := testhttpclient.MockResponse(503, nil, "")
+	busy.Header.Set("Retry-After", "10")
+
+	stub := testhttpclient.New(t).
+		AddResponse("GET", "http://localhost/foo", busy)
+
+	r := retry.New(stub, retry.RetryConfig{RetryStatuses: []int{503}}, zerolog.Nop())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "http://localhost/foo", nil).WithContext(ctx)
+
+	start := time.Now()
+	_, err := r.Do(req)
+	expect.Bool(errors.Is(err, context.DeadlineExceeded)).ToBeTrue(t)
+	expect.Number(time.Since(start)).ToBeLessThan(t, 5*time.Second)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"120":                           2 * time.Minute,
+		"Wed, 01 Jan 2025 12:00:30 GMT": 30 * time.Second,
+		"Wed, 01 Jan 2025 11:00:00 GMT": 0,
+	}
+
+	for value, expected := range cases {
+		d, ok := retry.RetryAfter(http.Header{"Retry-After": {value}}, now)
+		expect.Bool(ok).I(value).ToBeTrue(t)
+		expect.Number(d).I(value).ToBe(t, expected)
+	}
+
+	_, ok := retry.RetryAfter(http.Header{"Retry-After": {"soon"}}, now)
+	expect.Bool(ok).ToBeFalse(t)
+}
@@ -0,0 +1,204 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/rickb777/httpclient"
+	"github.com/rickb777/httpclient/logging"
+	"github.com/rickb777/httpclient/rest"
+)
+
+type tracingClient struct {
+	upstream httpclient.HttpClient
+	tracer   Tracer
+}
+
+// New wraps an upstream client and traces all requests made to it. Each request has a span
+// whose parent is the span in the request context, if any; the "traceparent" and "tracestate"
+// request headers are set accordingly.
+//
+// Requests that repeat earlier requests, such as those that meet authentication challenges
+// (see rest.Client) or retry after failures (see package retry), have a link to the span of the
+// earlier request and the "http.request.resend_count" attribute. Redirects followed by
+// http.Client are not visible at this level; use [NewTransport] to trace them.
+func New(upstream httpclient.HttpClient, tracer Tracer) httpclient.HttpClient {
+	if upstream == nil || tracer == nil {
+		panic("tracing: incorrect setup")
+	}
+	return &tracingClient{upstream: upstream, tracer: tracer}
+}
+
+// SetCheckRedirect provides access to the http.Client.CheckRedirect field.
+func (tc *tracingClient) SetCheckRedirect(fn func(req *http.Request, via []*http.Request) error) {
+	if hc, ok := tc.upstream.(*http.Client); ok {
+		hc.CheckRedirect = fn
+	} else if cr, ok := tc.upstream.(httpclient.ControlledRedirectClient); ok {
+		cr.SetCheckRedirect(fn)
+	}
+}
+
+func (tc *tracingClient) Do(req *http.Request) (*http.Response, error) {
+	return trace(tc.tracer, req, func(ctx context.Context) *http.Request {
+		out := req.WithContext(ctx)
+		out.Header = req.Header.Clone() // the caller's request is not altered
+		return out
+	}, tc.upstream.Do)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+type tracingTransport struct {
+	upstream http.RoundTripper
+	tracer   Tracer
+}
+
+// WrapTransport wraps the transport of a client so that all requests are traced,
+// including each redirect. See [NewTransport].
+func WrapTransport(client *http.Client, tracer Tracer) *http.Client {
+	upstream := http.DefaultTransport
+	if client.Transport != nil {
+		upstream = client.Transport
+	}
+
+	client.Transport = NewTransport(upstream, tracer)
+	return client
+}
+
+// NewTransport wraps an upstream transport and traces all requests made. This is similar
+// to [New], except that each redirect followed by http.Client also has its own span,
+// linked to the span of the request that was redirected.
+func NewTransport(upstream http.RoundTripper, tracer Tracer) http.RoundTripper {
+	if upstream == nil || tracer == nil {
+		panic("tracing: incorrect setup")
+	}
+	return &tracingTransport{upstream: upstream, tracer: tracer}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (tt *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return trace(tt.tracer, req, func(ctx context.Context) *http.Request {
+		return req.Clone(ctx) // a RoundTripper must not alter the request
+	}, tt.upstream.RoundTrip)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func trace(tracer Tracer, req *http.Request, outbound func(context.Context) *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	previous, resends := httpclient.Previous(req)
+
+	var links []SpanContext
+	if previous != nil {
+		if sc, err := ParseTraceParent(previous.Header.Get("traceparent")); err == nil {
+			sc.TraceState = previous.Header.Get("tracestate")
+			links = append(links, sc)
+		}
+	}
+
+	ctx, span := tracer.Start(req.Context(), req.Method, links...)
+	defer span.End()
+
+	span.SetAttributes(
+		Attribute{Key: "http.request.method", Value: req.Method},
+		Attribute{Key: "url.full", Value: fullURL(req.URL)},
+		Attribute{Key: "server.address", Value: req.URL.Hostname()},
+		Attribute{Key: "server.port", Value: port(req.URL)},
+	)
+	if req.ContentLength > 0 {
+		span.SetAttributes(Attribute{Key: "http.request.body.size", Value: req.ContentLength})
+	}
+	if resends > 0 {
+		span.SetAttributes(Attribute{Key: "http.request.resend_count", Value: resends})
+	}
+
+	out := outbound(ctx)
+	if sc := span.SpanContext(); sc.IsValid() {
+		out.Header.Set("traceparent", sc.TraceParent())
+		if sc.TraceState != "" {
+			out.Header.Set("tracestate", sc.TraceState)
+		} else {
+			out.Header.Del("tracestate")
+		}
+	}
+
+	res, err := send(out)
+	if err != nil {
+		recordError(span, err)
+		return res, err
+	}
+
+	span.SetAttributes(Attribute{Key: "http.response.status_code", Value: res.StatusCode})
+	if res.ContentLength >= 0 {
+		span.SetAttributes(Attribute{Key: "http.response.body.size", Value: res.ContentLength})
+	}
+	if res.StatusCode >= 400 {
+		span.SetAttributes(Attribute{Key: "error.type", Value: strconv.Itoa(res.StatusCode)})
+		span.SetStatus(Error, "")
+	}
+
+	return res, nil
+}
+
+// recordError records an error, which may be a network failure or a *rest.RestError from
+// an upstream client.
+func recordError(span Span, err error) {
+	span.RecordError(err)
+
+	var re *rest.RestError
+	if errors.As(err, &re) && re.StatusCode > 0 {
+		span.SetAttributes(Attribute{Key: "http.response.status_code", Value: re.StatusCode})
+	}
+
+	span.SetAttributes(Attribute{Key: "error.type", Value: errorType(err)})
+	span.SetStatus(Error, err.Error())
+}
+
+// errorType describes an error in the form required by the "error.type" attribute:
+// the status code for a RestError, "timeout" or "cancelled", otherwise the type of the
+// underlying error, e.g. "*net.OpError".
+func errorType(err error) string {
+	var re *rest.RestError
+	var ne net.Error
+	switch {
+	case errors.As(err, &re) && re.StatusCode > 0:
+		return strconv.Itoa(re.StatusCode)
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return "timeout"
+	}
+
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = inner
+	}
+}
+
+// fullURL gets the URL without any user information and with secrets in the query hidden
+// (see logging.Redact).
+func fullURL(u *url.URL) string {
+	item := &logging.LogItem{URL: u}
+	logging.Redact.Apply(item)
+
+	u2 := *item.URL
+	u2.User = nil
+	return u2.String()
+}
+
+func port(u *url.URL) int {
+	if p, err := strconv.Atoi(u.Port()); err == nil {
+		return p
+	}
+	if u.Scheme == "https" {
+		return 443
+	}
+	return 80
+}
@@ -0,0 +1,221 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+	"github.com/rickb777/httpclient/auth"
+	"github.com/rickb777/httpclient/internal/mytesting"
+	"github.com/rickb777/httpclient/rest"
+	"github.com/rickb777/httpclient/retry"
+)
+
+func TestNew_propagates_trace_context(t *testing.T) {
+	var traceparent, tracestate string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent, tracestate = r.Header.Get("traceparent"), r.Header.Get("tracestate")
+		w.Header().Set("Content-Length", "5")
+		w.Write([]byte("hello"))
+	}))
+	defer svr.Close()
+
+	parent, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	parent.TraceState = "congo=t61rcWkgMzE"
+	ctx := ContextWithSpanContext(context.Background(), parent)
+
+	rec := NewRecorder()
+	client := New(&http.Client{}, rec)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, svr.URL+"/a/b?api_key=secret", strings.NewReader("input"))
+	res, err := client.Do(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusOK)
+
+	spans := rec.Ended()
+	expect.Slice(spans).ToHaveLength(t, 1)
+	span := spans[0]
+	expect.String(span.Name).ToBe(t, "POST")
+	expect.Any(span.Parent).ToBe(t, parent)
+	expect.Slice(span.Links).ToHaveLength(t, 0)
+	expect.Any(span.Status).ToBe(t, Unset)
+	expect.String(traceparent).ToBe(t, span.Context.TraceParent())
+	expect.String(tracestate).ToBe(t, "congo=t61rcWkgMzE")
+	expect.String(req.Header.Get("traceparent")).ToBe(t, "") // the caller's request is unchanged
+
+	expect.Any(span.Attributes["http.request.method"]).ToBe(t, "POST")
+	expect.Any(span.Attributes["url.full"]).ToBe(t, svr.URL+"/a/b?api_key=%5BREDACTED%3A6%5D")
+	expect.Any(span.Attributes["server.address"]).ToBe(t, "127.0.0.1")
+	expect.Any(span.Attributes["http.request.body.size"]).ToBe(t, int64(5))
+	expect.Any(span.Attributes["http.response.status_code"]).ToBe(t, http.StatusOK)
+	expect.Any(span.Attributes["http.response.body.size"]).ToBe(t, int64(5))
+	expect.Any(span.Attributes["http.request.resend_count"]).ToBeNil(t)
+}
+
+func TestNew_error_status(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer svr.Close()
+
+	rec := NewRecorder()
+	client := New(&http.Client{}, rec)
+
+	req, _ := http.NewRequest(http.MethodGet, svr.URL, nil)
+	_, err := client.Do(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	span := rec.Ended()[0]
+	expect.Any(span.Status).ToBe(t, Error)
+	expect.Any(span.Attributes["error.type"]).ToBe(t, "404")
+	expect.Any(span.Attributes["http.response.status_code"]).ToBe(t, http.StatusNotFound)
+}
+
+func TestNew_network_failure(t *testing.T) {
+	svr := httptest.NewServer(http.NotFoundHandler())
+	svr.Close() // nothing is listening
+
+	rec := NewRecorder()
+	client := New(&http.Client{}, rec)
+
+	req, _ := http.NewRequest(http.MethodGet, svr.URL, nil)
+	_, err := client.Do(req)
+
+	expect.Error(err).ToHaveOccurred(t)
+	span := rec.Ended()[0]
+	expect.Any(span.Status).ToBe(t, Error)
+	expect.String(span.Description).ToBe(t, err.Error())
+	expect.Slice(span.Errors).ToHaveLength(t, 1)
+	expect.Any(span.Attributes["error.type"]).ToBe(t, "syscall.Errno")
+	expect.Any(span.Attributes["http.response.status_code"]).ToBeNil(t)
+}
+
+func TestNew_rest_error(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.test/", nil)
+	re := &rest.RestError{Response: rest.Response{StatusCode: http.StatusServiceUnavailable, Request: req}}
+
+	rec := NewRecorder()
+	client := New(failingClient{err: re}, rec)
+	_, err := client.Do(req)
+
+	expect.Error(err).ToHaveOccurred(t)
+	span := rec.Ended()[0]
+	expect.Any(span.Status).ToBe(t, Error)
+	expect.Any(span.Attributes["error.type"]).ToBe(t, "503")
+	expect.Any(span.Attributes["http.response.status_code"]).ToBe(t, http.StatusServiceUnavailable)
+	expect.Any(span.Attributes["server.port"]).ToBe(t, 80)
+}
+
+func TestNew_cancelled(t *testing.T) {
+	rec := NewRecorder()
+	client := New(failingClient{err: context.Canceled}, rec)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.test/", nil)
+	_, err := client.Do(req)
+
+	expect.Error(err).ToHaveOccurred(t)
+	span := rec.Ended()[0]
+	expect.Any(span.Attributes["error.type"]).ToBe(t, "cancelled")
+	expect.Any(span.Attributes["server.port"]).ToBe(t, 443)
+}
+
+func TestNew_links_authentication_retry(t *testing.T) {
+	ds := &mytesting.DigestServer{
+		Realm: "test@example.org",
+		Qop:   "auth",
+		Users: map[string]string{"fred": "password"},
+	}
+	svr := httptest.NewServer(ds)
+	defer svr.Close()
+
+	rec := NewRecorder()
+	cl := rest.NewClient(svr.URL,
+		rest.SetHttpClient(New(&http.Client{}, rec)),
+		rest.SetAuthentication(auth.Deferred("fred", "password")))
+
+	res, err := cl.Get(context.Background(), "/a")
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusNoContent)
+
+	spans := rec.Ended()
+	expect.Slice(spans).ToHaveLength(t, 2)
+	expect.Any(spans[0].Attributes["http.response.status_code"]).ToBe(t, http.StatusUnauthorized)
+	expect.Any(spans[1].Attributes["http.response.status_code"]).ToBe(t, http.StatusNoContent)
+	expect.Any(spans[1].Attributes["http.request.resend_count"]).ToBe(t, 1)
+	expect.Slice(spans[1].Links).ToHaveLength(t, 1)
+	expect.Any(spans[1].Links[0].SpanID).ToBe(t, spans[0].Context.SpanID)
+}
+
+func TestNew_links_retries(t *testing.T) {
+	attempts := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer svr.Close()
+
+	rec := NewRecorder()
+	client := retry.Wrap(New(&http.Client{}, rec), retry.RetryConfig{RetryStatuses: retry.DefaultRetryStatuses})
+
+	req, _ := http.NewRequest(http.MethodGet, svr.URL, nil)
+	res, err := client.Do(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusOK)
+
+	spans := rec.Ended()
+	expect.Slice(spans).ToHaveLength(t, 3)
+	expect.Slice(spans[0].Links).ToHaveLength(t, 0)
+	for i := 1; i < 3; i++ {
+		expect.Any(spans[i].Attributes["http.request.resend_count"]).Info(i).ToBe(t, i)
+		expect.Slice(spans[i].Links).Info(i).ToHaveLength(t, 1)
+		expect.Any(spans[i].Links[0].SpanID).Info(i).ToBe(t, spans[i-1].Context.SpanID)
+	}
+}
+
+func TestWrapTransport_links_redirects(t *testing.T) {
+	var traceparents []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		if r.URL.Path == "/a" {
+			http.Redirect(w, r, "/b", http.StatusFound)
+		}
+	}))
+	defer svr.Close()
+
+	rec := NewRecorder()
+	client := WrapTransport(&http.Client{}, rec)
+
+	req, _ := http.NewRequest(http.MethodGet, svr.URL+"/a", nil)
+	res, err := client.Do(req)
+
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Number(res.StatusCode).ToBe(t, http.StatusOK)
+	expect.String(req.Header.Get("traceparent")).ToBe(t, "") // the request is not altered
+
+	spans := rec.Ended()
+	expect.Slice(spans).ToHaveLength(t, 2)
+	expect.Any(spans[0].Attributes["http.response.status_code"]).ToBe(t, http.StatusFound)
+	expect.String(traceparents[0]).ToBe(t, spans[0].Context.TraceParent())
+	expect.String(traceparents[1]).ToBe(t, spans[1].Context.TraceParent())
+	expect.Any(spans[1].Attributes["url.full"]).ToBe(t, svr.URL+"/b")
+	expect.Any(spans[1].Attributes["http.request.resend_count"]).ToBe(t, 1)
+	expect.Slice(spans[1].Links).ToHaveLength(t, 1)
+	expect.Any(spans[1].Links[0].SpanID).ToBe(t, spans[0].Context.SpanID)
+}
+
+type failingClient struct {
+	err error
+}
+
+func (fc failingClient) Do(req *http.Request) (*http.Response, error) {
+	return nil, errors.Join(errors.New("failed"), fc.err)
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+var _ Tracer = &Recorder{}
+
+// Recorder is a [Tracer] that keeps the spans in memory, for use in tests. Spans are sampled
+// and the trace context is inherited from the parent in the context, if any.
+type Recorder struct {
+	mu    sync.Mutex // guards the following
+	ended []*RecordedSpan
+}
+
+// NewRecorder returns a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Start starts a span.
+func (r *Recorder) Start(ctx context.Context, name string, links ...SpanContext) (context.Context, Span) {
+	parent := SpanContextFromContext(ctx)
+
+	sc := SpanContext{Flags: 1}
+	if parent.IsValid() {
+		sc.TraceID, sc.Flags, sc.TraceState = parent.TraceID, parent.Flags, parent.TraceState
+	} else {
+		_, _ = rand.Read(sc.TraceID[:])
+	}
+	_, _ = rand.Read(sc.SpanID[:])
+
+	span := &RecordedSpan{
+		Name:       name,
+		Context:    sc,
+		Parent:     parent,
+		Links:      links,
+		Attributes: make(map[string]any),
+		StartTime:  time.Now(),
+		recorder:   r,
+	}
+	return ContextWithSpanContext(ctx, sc), span
+}
+
+// Ended gets the spans that have ended, in the order they ended.
+func (r *Recorder) Ended() []*RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*RecordedSpan(nil), r.ended...)
+}
+
+// Reset discards the spans that have ended.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ended = nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// RecordedSpan is a span recorded by a [Recorder]. Its fields should not be accessed until the
+// span has ended.
+type RecordedSpan struct {
+	Name        string
+	Context     SpanContext
+	Parent      SpanContext // invalid for a root span
+	Links       []SpanContext
+	Attributes  map[string]any
+	Errors      []error
+	Status      Code
+	Description string
+	StartTime   time.Time
+	EndTime     time.Time
+
+	recorder *Recorder
+}
+
+func (s *RecordedSpan) SpanContext() SpanContext {
+	return s.Context
+}
+
+func (s *RecordedSpan) SetAttributes(attributes ...Attribute) {
+	for _, a := range attributes {
+		s.Attributes[a.Key] = a.Value
+	}
+}
+
+func (s *RecordedSpan) RecordError(err error) {
+	s.Errors = append(s.Errors, err)
+}
+
+func (s *RecordedSpan) SetStatus(code Code, description string) {
+	s.Status, s.Description = code, description
+}
+
+func (s *RecordedSpan) End() {
+	s.EndTime = time.Now()
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.recorder.ended = append(s.recorder.ended, s)
+}
@@ -0,0 +1,129 @@
+// Package tracing provides an HttpClient decorator and a http.RoundTripper decorator that trace
+// outbound requests, creating a client span for each request and propagating the trace context
+// to the server using the W3C "traceparent" and "tracestate" headers
+// (https://www.w3.org/TR/trace-context/).
+//
+// Spans are created by a [Tracer], which is a small interface so that it can easily be adapted
+// to OpenTelemetry or another tracing library. [Recorder] is an in-memory Tracer for tests.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Tracer starts client spans. The parent span, if any, is the one in the context
+// (see [ContextWithSpanContext]). The links relate the span to other spans, such as the
+// span of a request that was redirected. The returned context holds the new span.
+type Tracer interface {
+	Start(ctx context.Context, name string, links ...SpanContext) (context.Context, Span)
+}
+
+// Span is a span that has been started by a [Tracer]. Attributes use the OpenTelemetry semantic
+// conventions for HTTP clients, e.g. "http.request.method".
+type Span interface {
+	SpanContext() SpanContext
+	SetAttributes(attributes ...Attribute)
+	RecordError(err error)
+	SetStatus(code Code, description string)
+	End()
+}
+
+// Attribute is a key-value pair that describes a span.
+type Attribute struct {
+	Key   string
+	Value any // string, int or int64
+}
+
+// Code is the status of a span.
+type Code int
+
+const (
+	Unset Code = iota
+	Error
+	Ok
+)
+
+func (c Code) String() string {
+	switch c {
+	case Error:
+		return "Error"
+	case Ok:
+		return "Ok"
+	}
+	return "Unset"
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// SpanContext identifies a span and carries the W3C trace context.
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Flags      byte   // 1 means sampled
+	TraceState string // vendor-specific, e.g. "congo=t61rcWkgMzE"
+}
+
+var ErrInvalidTraceParent = errors.New("tracing: invalid traceparent")
+
+// IsValid is true if neither the trace ID nor the span ID is all zeros.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// IsSampled is true if the sampled flag is set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&1 != 0
+}
+
+// TraceParent formats the "traceparent" header value, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%x-%x-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceParent parses a "traceparent" header value. Versions other than "00" are accepted
+// provided that they start with the version 00 fields.
+func ParseTraceParent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, ErrInvalidTraceParent
+	}
+
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil || strings.ToLower(parts[1]) != parts[1] {
+		return sc, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil || strings.ToLower(parts[2]) != parts[2] {
+		return sc, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a context that holds the span context, which will be the
+// parent of spans started from it, e.g. the context of an incoming server request.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext gets the span context held by a context, if any.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	sc, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Bool(sc.IsValid()).ToBeTrue(t)
+	expect.Bool(sc.IsSampled()).ToBeTrue(t)
+	expect.String(sc.TraceParent()).ToBe(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// a future version may have more fields
+	sc, err = ParseTraceParent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-what-the-future-holds")
+	expect.Error(err).Not().ToHaveOccurred(t)
+	expect.Bool(sc.IsSampled()).ToBeFalse(t)
+}
+
+func TestParseTraceParent_invalid(t *testing.T) {
+	cases := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-x1",
+	}
+
+	for _, c := range cases {
+		_, err := ParseTraceParent(c)
+		expect.Any(err).Info(c).ToBe(t, ErrInvalidTraceParent)
+	}
+}
+
+func TestRecorder_parent(t *testing.T) {
+	parent, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	parent.TraceState = "congo=t61rcWkgMzE"
+	rec := NewRecorder()
+
+	ctx, span := rec.Start(ContextWithSpanContext(context.Background(), parent), "GET")
+	span.End()
+
+	spans := rec.Ended()
+	expect.Slice(spans).ToHaveLength(t, 1)
+	expect.Any(spans[0].Parent).ToBe(t, parent)
+	expect.Any(spans[0].Context.TraceID).ToBe(t, parent.TraceID)
+	expect.String(spans[0].Context.TraceState).ToBe(t, "congo=t61rcWkgMzE")
+	expect.Any(SpanContextFromContext(ctx)).ToBe(t, spans[0].Context)
+}